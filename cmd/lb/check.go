@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+	configs "github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+)
+
+// check loads and validates the config, probes every backend once and
+// writes a table of the results to out. It returns the process exit code:
+// 0 when every backend is healthy, 1 otherwise.
+func check(out io.Writer, opts options) int {
+	config, err := configs.Load(opts.configPath)
+	if err != nil {
+		fmt.Fprintf(out, "failed to load config: %v\n", err)
+		return 1
+	}
+	opts.apply(config)
+
+	if err := config.Validate(); err != nil {
+		fmt.Fprintf(out, "invalid configuration: %v\n", err)
+		return 1
+	}
+
+	type namedPool struct {
+		name, group string
+		backends    []configs.BackendConfig
+	}
+	pools := []namedPool{{"default", "", config.Backends}}
+	for _, group := range config.BackendGroups {
+		pools = append(pools, namedPool{group.Name, group.Name, group.Backends})
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "POOL\tBACKEND\tSTATUS\tLATENCY\tERROR")
+
+	code := 0
+	for _, p := range pools {
+		pool, err := backend.NewServerPoolFor(p.backends, config)
+		if err != nil {
+			fmt.Fprintf(out, "failed to build backend pool %s: %v\n", p.name, err)
+			return 1
+		}
+
+		backend.NewDiscovery(pool, p.backends, config, nil).Refresh(context.Background())
+
+		hc := backend.NewHealthCheck(pool, config.GroupHealthCheck(p.group))
+		for _, res := range hc.ProbeAll(context.Background()) {
+			status, errText := "healthy", ""
+			if !res.Healthy {
+				status = "unhealthy"
+				code = 1
+			}
+			if res.Err != nil {
+				errText = res.Err.Error()
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", p.name, res.Backend.URL, status, res.Latency.Round(time.Microsecond), errText)
+		}
+		hc.Stop()
+	}
+
+	tw.Flush()
+	return code
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// baseConfig and healthCheck are the settings Validate requires. A test's
+// config goes between them and must end inside load_balancing, which
+// healthCheck completes.
+const baseConfig = `
+server:
+  port: 8080
+  read_timeout: 10s
+  write_timeout: 10s
+`
+
+const healthCheck = `
+  health_check:
+    interval: 15s
+    timeout: 1s
+    unhealthy_threshold: 3
+    healthy_threshold: 2
+`
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(baseConfig+body+healthCheck), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckHealthy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	path := writeConfig(t, fmt.Sprintf(`
+backends:
+  - url: %s
+    timeout: 1s
+load_balancing:
+  strategy: round_robin`, srv.URL))
+
+	var out bytes.Buffer
+	if code := check(&out, options{configPath: path}); code != 0 {
+		t.Fatalf("exit code %d, want 0:\n%s", code, out.String())
+	}
+	if !strings.Contains(out.String(), "healthy") {
+		t.Errorf("output is missing the backend's status:\n%s", out.String())
+	}
+}
+
+func TestCheckUnhealthy(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	path := writeConfig(t, fmt.Sprintf(`
+backends:
+  - url: %s
+    timeout: 1s
+load_balancing:
+  strategy: round_robin`, srv.URL))
+
+	var out bytes.Buffer
+	if code := check(&out, options{configPath: path}); code != 1 {
+		t.Fatalf("exit code %d, want 1:\n%s", code, out.String())
+	}
+	if !strings.Contains(out.String(), "unhealthy") {
+		t.Errorf("output does not report the backend unhealthy:\n%s", out.String())
+	}
+}
+
+func TestCheckInvalidConfig(t *testing.T) {
+	var out bytes.Buffer
+	if code := check(&out, options{configPath: filepath.Join(t.TempDir(), "missing.yml")}); code != 1 {
+		t.Fatalf("exit code %d, want 1", code)
+	}
+}
+
+// Groups behind tcp listeners are probed by TCP connect, not over HTTP.
+func TestCheckTCPGroup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	path := writeConfig(t, fmt.Sprintf(`  listeners:
+    - address: 127.0.0.1:18080
+    - address: 127.0.0.1:15432
+      mode: tcp
+      group: db
+backends:
+  - url: %s
+    timeout: 1s
+backend_groups:
+  - name: db
+    backends:
+      - url: tcp://%s
+        timeout: 1s
+load_balancing:
+  strategy: round_robin`, srv.URL, ln.Addr()))
+
+	var out bytes.Buffer
+	if code := check(&out, options{configPath: path}); code != 0 {
+		t.Fatalf("exit code %d, want 0:\n%s", code, out.String())
+	}
+}
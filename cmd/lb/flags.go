@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+
+	configs "github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+)
+
+const defaultConfigPath = "configs/config.yml"
+
+// options holds the command-line settings. Zero values mean "use the
+// config file".
+type options struct {
+	configPath string
+	port       uint16
+	strategy   string
+	// check probes every backend once and exits instead of serving.
+	check bool
+}
+
+// parseFlags parses args into options. fs decides what happens on a bad
+// flag; flag.CommandLine prints usage and exits.
+func parseFlags(fs *flag.FlagSet, args []string) options {
+	var opts options
+	fs.StringVar(&opts.configPath, "config", defaultConfigPath, "path to the YAML or JSON config file")
+	fs.Func("port", "listen port, overriding server.port", func(v string) error {
+		port, err := strconv.ParseUint(v, 10, 16)
+		if err != nil || port == 0 {
+			return fmt.Errorf("invalid port %q", v)
+		}
+		opts.port = uint16(port)
+		return nil
+	})
+	fs.StringVar(&opts.strategy, "strategy", "", "balancing strategy, overriding load_balancing.strategy")
+	fs.BoolVar(&opts.check, "check", false, "validate the config, probe every backend once and exit non-zero if any is unhealthy")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [flags]\n\nFlags:\n", fs.Name())
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	return opts
+}
+
+// apply writes the flags that were set over c, so they take precedence over
+// both the file and environment overrides.
+func (o options) apply(c *configs.Config) {
+	if o.port != 0 {
+		c.Server.Port = o.port
+	}
+	if o.strategy != "" {
+		c.LoadBalancing.Strategy = configs.Strategy(o.strategy)
+	}
+}
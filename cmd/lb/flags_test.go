@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+
+	configs "github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+)
+
+func testFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("lb", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+func TestParseFlags(t *testing.T) {
+	opts := parseFlags(testFlagSet(), []string{"-config", "/etc/lb.json", "-port", "9000", "-strategy", "least_conn", "-check"})
+	want := options{configPath: "/etc/lb.json", port: 9000, strategy: "least_conn", check: true}
+	if opts != want {
+		t.Errorf("options = %+v, want %+v", opts, want)
+	}
+
+	if opts := parseFlags(testFlagSet(), nil); opts != (options{configPath: defaultConfigPath}) {
+		t.Errorf("defaults = %+v", opts)
+	}
+}
+
+func TestParseFlagsInvalidPort(t *testing.T) {
+	for _, port := range []string{"0", "65536", "http"} {
+		fs := testFlagSet()
+		if opts := parseFlags(fs, []string{"-port", port}); opts.port != 0 {
+			t.Errorf("-port %s parsed as %d", port, opts.port)
+		}
+	}
+}
+
+func TestOptionsApply(t *testing.T) {
+	c := &configs.Config{}
+	c.Server.Port = 8080
+	c.LoadBalancing.Strategy = configs.RoundRobin
+
+	options{}.apply(c)
+	if c.Server.Port != 8080 || c.LoadBalancing.Strategy != configs.RoundRobin {
+		t.Fatalf("unset flags changed the config: port %d, strategy %s", c.Server.Port, c.LoadBalancing.Strategy)
+	}
+
+	options{port: 9000, strategy: "least_conn"}.apply(c)
+	if c.Server.Port != 9000 || c.LoadBalancing.Strategy != configs.LeastConnection {
+		t.Errorf("flags not applied: port %d, strategy %s", c.Server.Port, c.LoadBalancing.Strategy)
+	}
+}
+
+func TestParseFlagsUnknown(t *testing.T) {
+	opts := parseFlags(testFlagSet(), []string{"-bogus", "-port", "9000"})
+	if opts.port != 0 || opts.configPath != defaultConfigPath {
+		t.Errorf("options = %+v, want parsing to stop at the unknown flag", opts)
+	}
+}
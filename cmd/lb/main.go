@@ -2,104 +2,138 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"log"
 	"net/http"
-	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/algorithms"
-	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
 	configs "github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
-	ratelimiter "github.com/soham0w0sarkar/LoadBalancerGo.git/internal/middleware/rateLimiter"
-	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/proxy"
-	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/server"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/logger"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/loadbalancer"
 )
 
 func main() {
-	config, err := configs.Load("configs/config.yml")
+	opts := parseFlags(flag.CommandLine, os.Args[1:])
 
-	if err != nil {
-		log.Printf("Error: %v", err)
-		os.Exit(1)
+	if opts.check {
+		os.Exit(check(os.Stdout, opts))
 	}
 
-	err = config.Validate()
-	if err != nil {
-		log.Printf("Configuration validation error: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, opts); err != nil {
+		logger.Error("load balancer failed", "error", err)
+		stop()
 		os.Exit(1)
 	}
+}
 
-	serverPool := backend.NewServerPool(config)
-
-	balancer, _ := algorithms.SetAlgorithm(string(config.LoadBalancing.Strategy))
-
-	var handler http.Handler = proxy.NewProxy(serverPool, balancer)
+// run builds the load balancer from the config file and serves until ctx
+// is cancelled or a listener fails. The config watcher and its reload loop
+// are stopped first, so no reload races the shutdown that follows.
+func run(ctx context.Context, opts options) error {
+	config, err := configs.Load(opts.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	opts.apply(config)
 
-	if config.Middlewares.RateLimiter.Enabled {
-		capacity := config.Middlewares.RateLimiter.Size
-		refillRate := config.Middlewares.RateLimiter.Rate
-		handler = ratelimiter.NewRateLimiter(capacity, refillRate, handler)
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	healthChecker := backend.NewHealthCheck(serverPool, config.LoadBalancing.HealthCheck)
-	healthChecker.Start()
-	defer healthChecker.Stop()
+	if err := logger.Init(config.Logging.Level, config.Logging.Format); err != nil {
+		return fmt.Errorf("invalid logging configuration: %w", err)
+	}
 
-	srv := server.NewServer(&config.Server, handler)
+	lb, err := loadbalancer.NewLoadBalancer(config)
+	if err != nil {
+		return err
+	}
 
+	serveErr := make(chan error, 1)
 	go func() {
-		if err := srv.Start(int(config.Server.Port)); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server error: %v", err)
+		if err := lb.Start(); err != nil && err != http.ErrServerClosed {
+			serveErr <- err
 		}
 	}()
 
 	changeChan := make(chan configs.BackendChange)
-	watcher := configs.NewWatcher("configs/config.yml", config)
+	watcher := configs.NewWatcher(opts.configPath, config, config.Watcher.Debounce)
+	watcher.SetOverrides(opts.apply)
 	watcher.Start(changeChan)
-	defer watcher.Stop()
 
+	// SIGHUP reloads the config and TLS certificates on demand, for
+	// deploys that replace files in ways fsnotify does not see, such as
+	// Kubernetes projected volumes.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	reloadDone := make(chan struct{})
 	go func() {
-		for ev := range changeChan {
-			if len(ev.Added) == 0 && len(ev.Removed) == 0 {
-				continue
-			}
-			var backends []*backend.Backend
-
-			if len(ev.Added) > 0 {
-				for _, bUrl := range ev.Added {
-					backendUrl, _ := url.Parse(bUrl)
-					var timeout time.Duration = 30 * time.Second
-					for _, be := range config.Backends {
-						if be.Url == bUrl {
-							timeout = be.Timeout
-							break
-						}
-					}
-					backends = append(backends, backend.NewBackend(backendUrl, int(config.LoadBalancing.HealthCheck.UnhealthyThreshold), timeout))
+		defer close(reloadDone)
+		for {
+			select {
+			case ev, ok := <-changeChan:
+				if !ok {
+					return
 				}
-				serverPool.AddBackends(backends)
-			}
-			if len(ev.Removed) > 0 {
-				serverPool.RemoveBackends(ev.Removed)
+				applyReload(lb, watcher, ev)
+			case <-hup:
+				if err := lb.ReloadCertificates(); err != nil {
+					logger.Error("tls certificate reload failed, keeping current certificate", "error", err)
+				}
+				ev, changed, err := watcher.Reload()
+				if err != nil {
+					logger.Error("config reload failed, keeping current config", "path", opts.configPath, "error", err)
+					continue
+				}
+				if !changed {
+					logger.Info("config reloaded, nothing to apply", "path", opts.configPath)
+					continue
+				}
+				applyReload(lb, watcher, ev)
 			}
 		}
 	}()
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
-	<-quit
+	var runErr error
+	select {
+	case <-ctx.Done():
+	case err := <-serveErr:
+		runErr = fmt.Errorf("server error: %w", err)
+	}
+
+	logger.Info("shutting down")
 
-	fmt.Println("Shutting down...")
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	// The watcher no longer sends once Stop returns, so the reload loop can
+	// be drained and closed.
+	watcher.Stop()
+	close(changeChan)
+	<-reloadDone
 
-	if err := srv.Stop(ctx); err != nil {
-		fmt.Printf("Server shutdown error: %v", err)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := lb.Stop(shutdownCtx); err != nil {
+		logger.Error("shutdown error", "error", err)
 	}
 
-	fmt.Println("Server stopped")
+	logger.Info("server stopped")
+	return runErr
+}
+
+// applyReload applies a reloaded config to lb and commits it to the watcher,
+// so the next reload is diffed against it. A change that fails to apply is
+// rolled back by lb and left uncommitted.
+func applyReload(lb *loadbalancer.LoadBalancer, watcher *configs.Watcher, ev configs.BackendChange) {
+	if err := lb.Apply(ev); err != nil {
+		logger.Error("config reload rolled back", "error", err)
+		return
+	}
+	watcher.Commit(ev.Config)
 }
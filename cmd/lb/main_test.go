@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/algorithms"
+	configs "github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/loadbalancer"
+)
+
+const reloadBackends = `
+backends:
+  - url: http://10.0.0.1:80
+    timeout: 5s
+load_balancing:
+  strategy: round_robin
+`
+
+func TestReloadSwapsStrategy(t *testing.T) {
+	path := writeConfig(t, reloadBackends)
+	config, err := configs.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lb, err := loadbalancer.NewLoadBalancer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lb.Stop(context.Background())
+	watcher := configs.NewWatcher(path, config, 0)
+
+	rewrite := func(strategy string) {
+		t.Helper()
+		body := baseConfig + strings.Replace(reloadBackends, "round_robin", strategy, 1) + healthCheck
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rewrite("least_conn")
+	ev, changed, err := watcher.Reload()
+	if err != nil || !changed || !ev.StrategyChanged {
+		t.Fatalf("Reload = changed %v, strategy changed %v, err %v", changed, ev.StrategyChanged, err)
+	}
+	applyReload(lb, watcher, ev)
+	if _, ok := lb.Proxy().Balancer().(*algorithms.LeastConnection); !ok {
+		t.Fatalf("balancer = %T, want *algorithms.LeastConnection", lb.Proxy().Balancer())
+	}
+
+	rewrite("fastest")
+	if _, _, err := watcher.Reload(); err == nil {
+		t.Fatal("an unknown strategy was accepted on reload")
+	}
+	if _, ok := lb.Proxy().Balancer().(*algorithms.LeastConnection); !ok {
+		t.Errorf("balancer = %T after a rejected reload, want it kept", lb.Proxy().Balancer())
+	}
+}
+
+func TestReloadUpdatesPool(t *testing.T) {
+	path := writeConfig(t, reloadBackends)
+	config, err := configs.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lb, err := loadbalancer.NewLoadBalancer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lb.Stop(context.Background())
+	watcher := configs.NewWatcher(path, config, 0)
+
+	pool := func() []string {
+		var urls []string
+		for _, b := range lb.ServerPool().Snapshot() {
+			urls = append(urls, b.URL.String())
+		}
+		// Added backends join the pool in no particular order.
+		slices.Sort(urls)
+		return urls
+	}
+	rewrite := func(backends string) {
+		t.Helper()
+		body := baseConfig + "backends:\n" + backends + "load_balancing:\n  strategy: round_robin\n" + healthCheck
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rewrite("  - url: http://10.0.0.2:80\n    timeout: 5s\n  - url: http://10.0.0.3:80\n    timeout: 5s\n")
+	ev, changed, err := watcher.Reload()
+	if err != nil || !changed {
+		t.Fatalf("Reload = changed %v, err %v", changed, err)
+	}
+	applyReload(lb, watcher, ev)
+	if got := pool(); !slices.Equal(got, []string{"http://10.0.0.2:80", "http://10.0.0.3:80"}) {
+		t.Fatalf("pool = %v after the reload", got)
+	}
+	// The applied config is committed, so reading it again changes nothing.
+	if _, changed, err := watcher.Reload(); err != nil || changed {
+		t.Errorf("second Reload = changed %v, err %v, want nothing to apply", changed, err)
+	}
+
+	rewrite("  - url: http://10.0.0.4:80\n    timeout: -1s\n")
+	if _, _, err := watcher.Reload(); err == nil {
+		t.Fatal("a negative backend timeout was accepted on reload")
+	}
+	if got := pool(); !slices.Equal(got, []string{"http://10.0.0.2:80", "http://10.0.0.3:80"}) {
+		t.Errorf("pool = %v after a rejected reload, want it kept", got)
+	}
+}
+
+func TestRunShutsDownCleanly(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	path := writeConfig(t, fmt.Sprintf(`
+backends:
+  - url: %s
+    timeout: 1s
+middlewares:
+  rate_limiter:
+    enabled: true
+    rate: 100
+    size: 100
+load_balancing:
+  strategy: round_robin`, srv.URL))
+
+	// The signal package's loop outlives every Notify, so start it before
+	// counting goroutines.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	signal.Stop(hup)
+
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, options{configPath: path, port: uint16(port)}) }()
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	target := fmt.Sprintf("http://127.0.0.1:%d/", port)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		// The backend needs two probes to come up, so any answer will do.
+		resp, err := client.Get(target)
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("load balancer never served: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("run returned %v", err)
+		}
+	case <-time.After(15 * time.Second):
+		t.Fatal("run did not return after its context was cancelled")
+	}
+
+	deadline = time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("%d goroutines still running, want at most %d:\n%s", runtime.NumGoroutine(), before, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,124 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
+)
+
+type BackendStatus struct {
+	ID                string `json:"id"`
+	URL               string `json:"url"`
+	Alive             bool   `json:"alive"`
+	Draining          bool   `json:"draining"`
+	ActiveConnections int64  `json:"active_connections"`
+	SuccessCount      uint32 `json:"success_count"`
+	FailureCount      uint32 `json:"failure_count"`
+	// HealthCheckMs is how long the last health probe took.
+	HealthCheckMs       float64 `json:"health_check_ms"`
+	ConsecutiveFailures uint64  `json:"consecutive_failures"`
+}
+
+type Admin struct {
+	ServerPool *backend.ServerPool
+	mux        *http.ServeMux
+	config     *config.Config
+	// removing holds the URLs of backends DELETE is draining.
+	removing    map[string]bool
+	removingMux sync.Mutex
+}
+
+func NewAdmin(pool *backend.ServerPool) *Admin {
+	a := &Admin{ServerPool: pool, mux: http.NewServeMux(), removing: make(map[string]bool)}
+
+	a.mux.HandleFunc("GET /backends", a.listBackends)
+	a.mux.HandleFunc("POST /backends/drain", a.drainBackend)
+	a.mux.HandleFunc("POST /backends/enable", a.enableBackend)
+
+	return a
+}
+
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mux.ServeHTTP(w, r)
+}
+
+func (a *Admin) listBackends(w http.ResponseWriter, r *http.Request) {
+	backends := a.ServerPool.Snapshot()
+
+	statuses := make([]BackendStatus, 0, len(backends))
+	for _, b := range backends {
+		statuses = append(statuses, backendStatus(b))
+	}
+
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func backendStatus(b *backend.Backend) BackendStatus {
+	success, failure := b.Counts()
+	probes := b.ProbeMetrics()
+	return BackendStatus{
+		ID:                  util.StickyID(b.URL.String()),
+		URL:                 b.URL.String(),
+		Alive:               b.IsAlive(),
+		Draining:            b.IsDraining(),
+		ActiveConnections:   b.ActiveConnections(),
+		SuccessCount:        success,
+		FailureCount:        failure,
+		HealthCheckMs:       float64(probes.LastDuration.Microseconds()) / 1000,
+		ConsecutiveFailures: probes.ConsecutiveFailures,
+	}
+}
+
+func (a *Admin) drainBackend(w http.ResponseWriter, r *http.Request) {
+	b := a.lookup(w, r)
+	if b == nil {
+		return
+	}
+	b.Drain()
+	writeJSON(w, http.StatusOK, map[string]string{"url": b.URL.String(), "status": "draining"})
+}
+
+func (a *Admin) enableBackend(w http.ResponseWriter, r *http.Request) {
+	b := a.lookup(w, r)
+	if b == nil {
+		return
+	}
+	b.Enable()
+	writeJSON(w, http.StatusOK, map[string]string{"url": b.URL.String(), "status": "enabled"})
+}
+
+// lookup finds the backend named by the {id} path segment or, on the
+// routes without one, the id or url query parameter. Either form takes the
+// id listed by GET /backends or the backend's URL.
+func (a *Admin) lookup(w http.ResponseWriter, r *http.Request) *backend.Backend {
+	target := r.PathValue("id")
+	if target == "" {
+		target = r.URL.Query().Get("id")
+	}
+	if target == "" {
+		target = r.URL.Query().Get("url")
+	}
+	if target == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing id or url parameter"})
+		return nil
+	}
+
+	for _, b := range a.ServerPool.Snapshot() {
+		if url := b.URL.String(); url == target || util.StickyID(url) == target {
+			return b
+		}
+	}
+
+	writeJSON(w, http.StatusNotFound, map[string]string{"error": "backend not found"})
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,130 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
+)
+
+func testPool(t *testing.T, urls ...string) *backend.ServerPool {
+	t.Helper()
+	pool := &backend.ServerPool{}
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := backend.NewBackend(u, 3, time.Second)
+		b.SetAlive(true)
+		pool.Backends = append(pool.Backends, b)
+	}
+	return pool
+}
+
+// do sends method target to h and decodes the JSON response into v.
+func do(t *testing.T, h http.Handler, method, target string, v any) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	if v != nil {
+		if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+			t.Fatalf("%s %s: decoding %q: %v", method, target, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func TestListBackends(t *testing.T) {
+	pool := testPool(t, "http://10.0.0.1:80", "http://10.0.0.2:80")
+	first, second := pool.Backends[0], pool.Backends[1]
+	first.IncrementConnections()
+	first.IncrementConnections()
+	first.UpdateSuccessCount(5)
+	second.SetAlive(false)
+	second.UpdateFailureCount(5)
+	second.UpdateFailureCount(5)
+
+	var got []BackendStatus
+	if code := do(t, NewAdmin(pool), http.MethodGet, "/backends", &got); code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	want := []BackendStatus{
+		{ID: util.StickyID("http://10.0.0.1:80"), URL: "http://10.0.0.1:80", Alive: true, ActiveConnections: 2, SuccessCount: 1},
+		{ID: util.StickyID("http://10.0.0.2:80"), URL: "http://10.0.0.2:80", FailureCount: 2},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d backends, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("backend %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDrainAndEnable(t *testing.T) {
+	pool := testPool(t, "http://10.0.0.1:80", "http://10.0.0.2:80")
+	b := pool.Backends[1]
+	a := NewAdmin(pool)
+
+	var resp map[string]string
+	if code := do(t, a, http.MethodPost, "/backends/drain?url=http://10.0.0.2:80", &resp); code != http.StatusOK || resp["status"] != "draining" {
+		t.Fatalf("drain: %d %v", code, resp)
+	}
+	if !b.IsDraining() || pool.Backends[0].IsDraining() {
+		t.Fatal("drain did not take exactly the named backend out of rotation")
+	}
+
+	// Backends can also be named by the id GET /backends lists.
+	if code := do(t, a, http.MethodPost, "/backends/enable?id="+util.StickyID("http://10.0.0.2:80"), &resp); code != http.StatusOK || resp["status"] != "enabled" {
+		t.Fatalf("enable: %d %v", code, resp)
+	}
+	if b.IsDraining() {
+		t.Error("backend is still draining after enable")
+	}
+
+	for target, want := range map[string]int{
+		"/backends/drain":                        http.StatusBadRequest,
+		"/backends/drain?url=http://10.0.0.9:80": http.StatusNotFound,
+	} {
+		if code := do(t, a, http.MethodPost, target, &resp); code != want {
+			t.Errorf("%s: status %d, want %d", target, code, want)
+		}
+	}
+	if code := do(t, a, http.MethodGet, "/backends/drain?url=http://10.0.0.2:80", nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET drain: status %d, want 405", code)
+	}
+}
+
+type maintenanceSwitch struct{ on bool }
+
+func (m *maintenanceSwitch) SetMaintenance(on bool) { m.on = on }
+func (m *maintenanceSwitch) InMaintenance() bool    { return m.on }
+
+func TestMaintenance(t *testing.T) {
+	m := &maintenanceSwitch{}
+	a := NewAdmin(testPool(t))
+	a.EnableMaintenance(m)
+
+	var state map[string]bool
+	for _, on := range []bool{true, false} {
+		target := "/maintenance?on=" + strconv.FormatBool(on)
+		if code := do(t, a, http.MethodPost, target, &state); code != http.StatusOK || state["maintenance"] != on || m.on != on {
+			t.Errorf("POST %s: status %d, reported %v, switch %v", target, code, state["maintenance"], m.on)
+		}
+		if code := do(t, a, http.MethodGet, "/maintenance", &state); code != http.StatusOK || state["maintenance"] != on {
+			t.Errorf("GET /maintenance after %s: status %d, reported %v", target, code, state["maintenance"])
+		}
+	}
+
+	if code := do(t, a, http.MethodPost, "/maintenance?on=maybe", nil); code != http.StatusBadRequest || m.on {
+		t.Errorf("invalid toggle: status %d, switch %v; want 400 and unchanged", code, m.on)
+	}
+}
@@ -0,0 +1,18 @@
+package admin
+
+import (
+	"expvar"
+	"net/http/pprof"
+)
+
+// EnableDebug mounts the net/http/pprof profiles under /debug/pprof/ and
+// expvar under /debug/vars. They are only ever served on the admin
+// listener, never on the proxy port.
+func (a *Admin) EnableDebug() {
+	a.mux.HandleFunc("/debug/pprof/", pprof.Index)
+	a.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	a.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	a.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	a.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	a.mux.Handle("GET /debug/vars", expvar.Handler())
+}
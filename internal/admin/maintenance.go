@@ -0,0 +1,29 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// MaintenanceSwitch turns the load balancer's maintenance mode on and off.
+type MaintenanceSwitch interface {
+	SetMaintenance(on bool)
+	InMaintenance() bool
+}
+
+// EnableMaintenance mounts GET /maintenance, reporting whether maintenance
+// mode is on, and POST /maintenance?on=true|false, switching it.
+func (a *Admin) EnableMaintenance(m MaintenanceSwitch) {
+	a.mux.HandleFunc("GET /maintenance", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]bool{"maintenance": m.InMaintenance()})
+	})
+	a.mux.HandleFunc("POST /maintenance", func(w http.ResponseWriter, r *http.Request) {
+		on, err := strconv.ParseBool(r.URL.Query().Get("on"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "on must be true or false"})
+			return
+		}
+		m.SetMaintenance(on)
+		writeJSON(w, http.StatusOK, map[string]bool{"maintenance": on})
+	})
+}
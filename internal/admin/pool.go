@@ -0,0 +1,113 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+)
+
+// AddBackendRequest is the body of POST /backends. Timeout is a duration
+// string such as "15s"; Weight is 1 when omitted.
+type AddBackendRequest struct {
+	URL           string `json:"url"`
+	Timeout       string `json:"timeout"`
+	Weight        *uint  `json:"weight"`
+	MaxConcurrent int    `json:"max_concurrent"`
+	Priority      uint   `json:"priority"`
+}
+
+// EnablePoolManagement mounts the endpoints that change the pool at run
+// time:
+//
+//	POST   /backends            add the backend described by the body
+//	DELETE /backends/{id}       drain the backend and remove it once
+//	                            drained; answers 202 right away
+//	PUT    /backends/{id}/drain take the backend out of rotation, like
+//	                            POST /backends/drain?id=
+//
+// {id} is the id listed by GET /backends or the backend's URL, path
+// escaped. New backends are built from cfg like those in config.yml and
+// join rotation once health checks pass. Changes are not written back to
+// config.yml, so a restart forgets them.
+func (a *Admin) EnablePoolManagement(cfg *config.Config) {
+	a.config = cfg
+	a.mux.HandleFunc("POST /backends", a.addBackend)
+	a.mux.HandleFunc("DELETE /backends/{id}", a.removeBackend)
+	a.mux.HandleFunc("PUT /backends/{id}/drain", a.drainBackend)
+}
+
+func (a *Admin) addBackend(w http.ResponseWriter, r *http.Request) {
+	var req AddBackendRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid body: %v", err)})
+		return
+	}
+	timeout, err := time.ParseDuration(req.Timeout)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "timeout must be a duration such as 15s"})
+		return
+	}
+	bc := config.BackendConfig{
+		Url:           req.URL,
+		Timeout:       timeout,
+		Weight:        req.Weight,
+		MaxConcurrent: req.MaxConcurrent,
+		Priority:      req.Priority,
+	}
+	if bc.Discovered() {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "discovered backends can only be configured in the config file"})
+		return
+	}
+	if err := a.config.ValidateBackend(bc); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	key, _ := config.NormalizeURL(bc.Url)
+	for _, b := range a.ServerPool.Snapshot() {
+		if existing, _ := config.NormalizeURL(b.URL.String()); existing == key {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "backend already in the pool"})
+			return
+		}
+	}
+
+	b, err := backend.NewBackendFromConfig(bc, a.config)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	a.ServerPool.AddBackends([]*backend.Backend{b})
+	writeJSON(w, http.StatusCreated, backendStatus(b))
+}
+
+func (a *Admin) removeBackend(w http.ResponseWriter, r *http.Request) {
+	b := a.lookup(w, r)
+	if b == nil {
+		return
+	}
+	url := b.URL.String()
+	b.Drain()
+
+	// RemoveBackends returns only once in-flight requests have finished or
+	// the drain timeout has passed, so it runs after the response. A
+	// repeated DELETE while that is pending is answered the same way.
+	a.removingMux.Lock()
+	pending := a.removing[url]
+	if !pending {
+		a.removing[url] = true
+	}
+	a.removingMux.Unlock()
+	if !pending {
+		go func() {
+			a.ServerPool.RemoveBackends([]string{url})
+			a.removingMux.Lock()
+			delete(a.removing, url)
+			a.removingMux.Unlock()
+		}()
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"url": url, "status": "draining"})
+}
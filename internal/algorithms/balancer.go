@@ -2,6 +2,9 @@ package algorithms
 
 import (
 	"fmt"
+	"net/http"
+	"sort"
+	"sync"
 
 	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
 )
@@ -10,11 +13,67 @@ type Balancer interface {
 	Select([]*backend.Backend) (*backend.Backend, error)
 }
 
+// RequestBalancer is implemented by balancers that need to inspect the
+// request itself; the proxy prefers SelectFor over Select when available.
+type RequestBalancer interface {
+	Balancer
+	SelectFor(*http.Request, []*backend.Backend) (*backend.Backend, error)
+}
+
+// StableListBalancer is a RequestBalancer that maps requests onto positions
+// in the backend list, so the list must not shrink between requests. The
+// proxy hands it the whole active tier, saturated and already-tried
+// backends included, and the balancer skips those itself.
+type StableListBalancer interface {
+	RequestBalancer
+	StableList()
+}
+
+var (
+	registryMux sync.RWMutex
+	registry    = map[string]func() Balancer{
+		"round_robin":         func() Balancer { return &RoundRobin{} },
+		"least_conn":          func() Balancer { return &LeastConnection{} },
+		"consistent_hash":     func() Balancer { return NewConsistentHash(DefaultHashKey) },
+		"weighted":            func() Balancer { return NewWeighted() },
+		"random":              func() Balancer { return NewRandom() },
+		"p2c":                 func() Balancer { return NewP2C() },
+		"size_aware":          func() Balancer { return NewSizeAware(DefaultLargeRequestSize) },
+		"least_latency":       func() Balancer { return &LeastLatency{} },
+		"ip_hash":             func() Balancer { return &IPHash{} },
+		"weighted_least_conn": func() Balancer { return &WeightedLeastConnection{} },
+		"weighted_random":     func() Balancer { return NewWeightedRandom() },
+	}
+)
+
+// RegisterStrategy makes a custom balancer available to SetAlgorithm under
+// name, replacing any strategy already registered with that name.
+func RegisterStrategy(name string, f func() Balancer) {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	registry[name] = f
+}
+
+func Strategies() []string {
+	registryMux.RLock()
+	defer registryMux.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func SetAlgorithm(strategy string) (Balancer, error) {
-	switch strategy {
-	case "round_robin":
-		return &RoundRobin{}, nil
+	registryMux.RLock()
+	f, ok := registry[strategy]
+	registryMux.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy: %s", strategy)
 	}
 
-	return nil, fmt.Errorf("unkown strategy: %s", strategy)
+	return f(), nil
 }
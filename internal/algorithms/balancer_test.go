@@ -0,0 +1,71 @@
+package algorithms
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+)
+
+// testBackends returns n alive backends at distinct placeholder URLs.
+func testBackends(t *testing.T, n int) []*backend.Backend {
+	t.Helper()
+	backends := make([]*backend.Backend, n)
+	for i := range backends {
+		u, err := url.Parse(fmt.Sprintf("http://10.0.0.%d:80", i+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		backends[i] = backend.NewBackend(u, 3, time.Second)
+		backends[i].SetAlive(true)
+	}
+	return backends
+}
+
+// withConnections opens n connection slots on b.
+func withConnections(b *backend.Backend, n int) {
+	for range n {
+		b.IncrementConnections()
+	}
+}
+
+func TestSetAlgorithm(t *testing.T) {
+	for _, name := range Strategies() {
+		if _, err := SetAlgorithm(name); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := SetAlgorithm("no_such_strategy"); err == nil {
+		t.Error("an unknown strategy was accepted")
+	}
+}
+
+func TestSelectEmpty(t *testing.T) {
+	dead := testBackends(t, 2)
+	for _, b := range dead {
+		b.SetAlive(false)
+	}
+	for _, name := range Strategies() {
+		lb, err := SetAlgorithm(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// A reload can leave the pool empty, or with nothing alive, between
+		// a request arriving and its backend being chosen.
+		for _, backends := range [][]*backend.Backend{nil, {}, dead} {
+			if b, err := lb.Select(backends); err == nil {
+				t.Errorf("%s: selected %v from %d unusable backends", name, b, len(backends))
+			}
+			if rb, ok := lb.(RequestBalancer); ok {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				if b, err := rb.SelectFor(req, backends); err == nil {
+					t.Errorf("%s: SelectFor chose %v from %d unusable backends", name, b, len(backends))
+				}
+			}
+		}
+	}
+}
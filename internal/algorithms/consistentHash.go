@@ -0,0 +1,93 @@
+package algorithms
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
+)
+
+// DefaultHashKey is what ConsistentHash hashes when no key is configured.
+const DefaultHashKey = "path"
+
+// ConsistentHash sends every request with the same key to the same backend,
+// using rendezvous hashing: each backend scores hash(key, backend URL) and
+// the highest eligible score wins. A backend joining, leaving or going
+// down only moves the keys that scored highest on it.
+//
+// Key is one of path, uri (path and query), host, client_ip,
+// header:<name>, cookie:<name> or query:<name>. Requests whose key comes
+// out empty are spread round-robin instead.
+type ConsistentHash struct {
+	Key string
+	// TrustedProxies are the peers whose X-Forwarded-For is believed when
+	// the key is client_ip.
+	TrustedProxies []netip.Prefix
+	fallback       RoundRobin
+}
+
+func NewConsistentHash(key string) *ConsistentHash {
+	if key == "" {
+		key = DefaultHashKey
+	}
+	return &ConsistentHash{Key: key}
+}
+
+func (ch *ConsistentHash) Select(backends []*backend.Backend) (*backend.Backend, error) {
+	return ch.fallback.Select(backends)
+}
+
+func (ch *ConsistentHash) SelectFor(r *http.Request, backends []*backend.Backend) (*backend.Backend, error) {
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("no Backend found")
+	}
+
+	key := ch.keyOf(r)
+	if key == "" {
+		return ch.fallback.Select(backends)
+	}
+
+	var best *backend.Backend
+	var bestScore uint64
+	for _, b := range backend.Eligible(backends) {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(b.URL.String()))
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best, bestScore = b, score
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("no Backend found alive")
+	}
+	return best, nil
+}
+
+func (ch *ConsistentHash) keyOf(r *http.Request) string {
+	kind, name, _ := strings.Cut(ch.Key, ":")
+	switch kind {
+	case "path":
+		return r.URL.Path
+	case "uri":
+		return r.URL.RequestURI()
+	case "host":
+		return r.Host
+	case "client_ip":
+		return util.ClientIP(r, ch.TrustedProxies)
+	case "header":
+		return r.Header.Get(name)
+	case "cookie":
+		if c, err := r.Cookie(name); err == nil {
+			return c.Value
+		}
+	case "query":
+		return r.URL.Query().Get(name)
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package algorithms
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/netip"
+	"slices"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
+)
+
+type IPHash struct {
+	// TrustedProxies are the peers whose X-Forwarded-For is believed when
+	// working out the client IP.
+	TrustedProxies []netip.Prefix
+	fallback       RoundRobin
+}
+
+func (ih *IPHash) Select(backends []*backend.Backend) (*backend.Backend, error) {
+	return ih.fallback.Select(backends)
+}
+
+// StableList marks IPHash as hashing over the full tier.
+func (ih *IPHash) StableList() {}
+
+// SelectFor hashes the client IP onto the backend list. If that backend is
+// down, saturated or already tried for this request it walks forward to the
+// next eligible one, so a client only moves while its own backend can't
+// take it.
+func (ih *IPHash) SelectFor(r *http.Request, backends []*backend.Backend) (*backend.Backend, error) {
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("no Backend found")
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(util.ClientIP(r, ih.TrustedProxies)))
+	start := int(h.Sum32() % uint32(len(backends)))
+
+	tried := util.GetTriedFromContext(r)
+	for i := 0; i < len(backends); i++ {
+		b := backends[(start+i)%len(backends)]
+		if b.Eligible() && !slices.Contains(tried, b.URL.String()) {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("no Backend found alive")
+}
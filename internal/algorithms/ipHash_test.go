@@ -0,0 +1,72 @@
+package algorithms
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+)
+
+func fromIP(ip string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = ip + ":40000"
+	return r
+}
+
+func TestIPHashAffinity(t *testing.T) {
+	backends := testBackends(t, 3)
+	ih := &IPHash{}
+
+	used := map[*backend.Backend]bool{}
+	for i := range 50 {
+		r := fromIP(fmt.Sprintf("192.0.2.%d", i))
+		first, err := ih.SelectFor(r, backends)
+		if err != nil {
+			t.Fatal(err)
+		}
+		used[first] = true
+		for range 3 {
+			if b, _ := ih.SelectFor(r, backends); b != first {
+				t.Fatalf("%s moved from %s to %s", r.RemoteAddr, first.URL, b.URL)
+			}
+		}
+	}
+	if len(used) != 3 {
+		t.Errorf("50 clients hashed onto %d backends, want all 3", len(used))
+	}
+}
+
+func TestIPHashFailover(t *testing.T) {
+	backends := testBackends(t, 3)
+	ih := &IPHash{}
+
+	home := map[string]*backend.Backend{}
+	for i := range 30 {
+		ip := fmt.Sprintf("192.0.2.%d", i)
+		home[ip], _ = ih.SelectFor(fromIP(ip), backends)
+	}
+
+	down := backends[1]
+	down.SetAlive(false)
+	for ip, b := range home {
+		got, err := ih.SelectFor(fromIP(ip), backends)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case got == down:
+			t.Errorf("%s sent to the dead backend", ip)
+		case b != down && got != b:
+			t.Errorf("%s moved from %s although its backend is up", ip, b.URL)
+		}
+	}
+
+	down.SetAlive(true)
+	for ip, b := range home {
+		if got, _ := ih.SelectFor(fromIP(ip), backends); got != b {
+			t.Errorf("%s did not return to %s once it recovered", ip, b.URL)
+		}
+	}
+}
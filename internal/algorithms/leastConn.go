@@ -0,0 +1,35 @@
+package algorithms
+
+import (
+	"fmt"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+)
+
+// LeastConnection sends each request to the backend with the fewest in
+// flight. The counts are the connection slots the proxy takes when it
+// places a request and gives back when the request completes.
+type LeastConnection struct{}
+
+// Select picks the alive backend with the lowest ActiveConnections. A
+// backend in its slow-start window has its count scaled up by RampWeight.
+// Ties go to the earliest backend in the list.
+func (lc *LeastConnection) Select(backends []*backend.Backend) (*backend.Backend, error) {
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("no Backend found")
+	}
+
+	var best *backend.Backend
+	var bestScore float64
+	for _, b := range backend.Eligible(backends) {
+		score := float64(b.ActiveConnections()+1) / b.RampWeight()
+		if best == nil || score < bestScore {
+			best, bestScore = b, score
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("no Backend found alive")
+	}
+	return best, nil
+}
@@ -0,0 +1,29 @@
+package algorithms
+
+import (
+	"fmt"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+)
+
+type LeastLatency struct{}
+
+// Select picks the alive backend with the lowest latency EWMA. A backend
+// with no samples yet reports zero, so it is tried before the others.
+func (ll *LeastLatency) Select(backends []*backend.Backend) (*backend.Backend, error) {
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("no Backend found")
+	}
+
+	var best *backend.Backend
+	for _, b := range backend.Eligible(backends) {
+		if best == nil || b.LatencyEWMA() < best.LatencyEWMA() {
+			best = b
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("no Backend found alive")
+	}
+	return best, nil
+}
@@ -0,0 +1,47 @@
+package algorithms
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeastLatencyPicksFastest(t *testing.T) {
+	backends := testBackends(t, 3)
+	for i, samples := range [][]time.Duration{
+		{80 * time.Millisecond, 120 * time.Millisecond, 100 * time.Millisecond},
+		{10 * time.Millisecond, 30 * time.Millisecond, 20 * time.Millisecond},
+		{40 * time.Millisecond, 60 * time.Millisecond, 50 * time.Millisecond},
+	} {
+		for _, d := range samples {
+			backends[i].RecordLatency(d)
+		}
+	}
+
+	ll := &LeastLatency{}
+	b, err := ll.Select(backends)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b != backends[1] {
+		t.Errorf("selected %s (EWMA %v), want the fastest backend 1 (EWMA %v)", b.URL, b.LatencyEWMA(), backends[1].LatencyEWMA())
+	}
+
+	// One slow response raises the average enough to move traffic away.
+	backends[1].RecordLatency(500 * time.Millisecond)
+	if b, _ := ll.Select(backends); b != backends[2] {
+		t.Errorf("selected %s after backend 1 slowed down, want backend 2", b.URL)
+	}
+}
+
+func TestLeastLatencyTriesNewBackends(t *testing.T) {
+	backends := testBackends(t, 2)
+	backends[0].RecordLatency(time.Millisecond)
+
+	b, err := (&LeastLatency{}).Select(backends)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b != backends[1] {
+		t.Errorf("selected %s, want the backend with no samples yet", b.URL)
+	}
+}
@@ -0,0 +1,49 @@
+package algorithms
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+)
+
+type P2C struct {
+	rnd *rand.Rand
+	mux sync.Mutex
+}
+
+func NewP2C() *P2C {
+	return &P2C{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (p *P2C) Select(backends []*backend.Backend) (*backend.Backend, error) {
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("no Backend found")
+	}
+
+	alive := backend.Admitted(backend.Eligible(backends))
+
+	switch len(alive) {
+	case 0:
+		return nil, fmt.Errorf("no Backend found alive")
+	case 1:
+		return alive[0], nil
+	}
+
+	p.mux.Lock()
+	i := p.rnd.Intn(len(alive))
+	j := p.rnd.Intn(len(alive) - 1)
+	p.mux.Unlock()
+
+	// Shift j past i so the two picks are always distinct.
+	if j >= i {
+		j++
+	}
+
+	if alive[j].ActiveConnections() < alive[i].ActiveConnections() {
+		return alive[j], nil
+	}
+	return alive[i], nil
+}
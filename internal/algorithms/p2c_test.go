@@ -0,0 +1,51 @@
+package algorithms
+
+import "testing"
+
+func TestP2CAvoidsBusiest(t *testing.T) {
+	backends := testBackends(t, 4)
+	withConnections(backends[0], 50)
+	withConnections(backends[1], 2)
+	withConnections(backends[2], 3)
+	withConnections(backends[3], 4)
+
+	p := NewP2C()
+	const rounds = 1000
+	busiest := 0
+	for range rounds {
+		b, err := p.Select(backends)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b == backends[0] {
+			busiest++
+		}
+	}
+	// The busiest backend loses every comparison and the two picks are
+	// always distinct, so it should never be chosen.
+	if busiest != 0 {
+		t.Errorf("busiest backend chosen %d/%d times", busiest, rounds)
+	}
+}
+
+func TestP2CSingleAlive(t *testing.T) {
+	backends := testBackends(t, 3)
+	backends[0].SetAlive(false)
+	backends[2].SetAlive(false)
+
+	p := NewP2C()
+	for range 10 {
+		b, err := p.Select(backends)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b != backends[1] {
+			t.Fatalf("selected %s, want the only alive backend", b.URL)
+		}
+	}
+
+	backends[1].SetAlive(false)
+	if _, err := p.Select(backends); err == nil {
+		t.Error("selected a backend with none alive")
+	}
+}
@@ -0,0 +1,37 @@
+package algorithms
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+)
+
+type Random struct {
+	rnd *rand.Rand
+	mux sync.Mutex
+}
+
+func NewRandom() *Random {
+	return &Random{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (r *Random) Select(backends []*backend.Backend) (*backend.Backend, error) {
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("no Backend found")
+	}
+
+	alive := backend.Admitted(backend.Eligible(backends))
+
+	if len(alive) == 0 {
+		return nil, fmt.Errorf("no Backend found alive")
+	}
+
+	r.mux.Lock()
+	idx := r.rnd.Intn(len(alive))
+	r.mux.Unlock()
+
+	return alive[idx], nil
+}
@@ -8,30 +8,34 @@ import (
 )
 
 type RoundRobin struct {
+	// current is the position the next selection starts from. It only ever
+	// grows and is reduced modulo the length of each call's slice, so a
+	// pool that changes size between calls cannot push it out of range.
 	current uint64
 }
 
+// NextIndex claims the next position in backends, starting at 0 on the
+// first call. It returns -1 for an empty slice without moving the rotation.
 func (rr *RoundRobin) NextIndex(backends []*backend.Backend) int {
-	return int(atomic.AddUint64(&rr.current, uint64(1)) % uint64(len(backends)))
+	if len(backends) == 0 {
+		return -1
+	}
+	return int((atomic.AddUint64(&rr.current, 1) - 1) % uint64(len(backends)))
 }
 
+// Select rotates through the eligible backends, with slow-start admission
+// applied.
 func (rr *RoundRobin) Select(backends []*backend.Backend) (*backend.Backend, error) {
 	if len(backends) == 0 {
 		return nil, fmt.Errorf("no Backend found")
 	}
 
-	next := rr.NextIndex(backends)
-	l := len(backends) + next
-
-	for i := next; i < l; i++ {
-		idx := i % len(backends)
-
-		if backends[idx].IsAlive() {
-			if i != next {
-				atomic.StoreUint64(&rr.current, uint64(idx))
-			}
-			return backends[idx], nil
-		}
+	// Balancers only ever see a snapshot of the pool, so a reload removing
+	// backends cannot shrink the slice between these lines.
+	eligible := backend.Admitted(backend.Eligible(backends))
+	i := rr.NextIndex(eligible)
+	if i < 0 {
+		return nil, fmt.Errorf("no Backend found alive")
 	}
-	return nil, fmt.Errorf("no Backend found alive")
+	return eligible[i], nil
 }
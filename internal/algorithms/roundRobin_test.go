@@ -0,0 +1,49 @@
+package algorithms
+
+import (
+	"testing"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+)
+
+func TestRoundRobinOrder(t *testing.T) {
+	backends := testBackends(t, 3)
+	rr := &RoundRobin{}
+	for i := range 6 {
+		b, err := rr.Select(backends)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := backends[i%3]; b != want {
+			t.Fatalf("selection %d went to %s, want %s", i, b.URL, want.URL)
+		}
+	}
+}
+
+func TestRoundRobinPoolChange(t *testing.T) {
+	backends := testBackends(t, 4)
+	rr := &RoundRobin{}
+	for i := range 20 {
+		// Grow and shrink the pool between calls, as a reload would.
+		pool := backends[:1+i%4]
+		if _, err := rr.Select(pool); err != nil {
+			t.Fatal(err)
+		}
+		if i := rr.NextIndex(pool); i < 0 || i >= len(pool) {
+			t.Fatalf("index %d out of range for %d backends", i, len(pool))
+		}
+	}
+}
+
+func TestRoundRobinEmpty(t *testing.T) {
+	rr := &RoundRobin{}
+	for _, backends := range [][]*backend.Backend{nil, {}} {
+		if i := rr.NextIndex(backends); i != -1 {
+			t.Errorf("NextIndex(%v) = %d, want -1", backends, i)
+		}
+	}
+	// An empty call must not move the rotation.
+	if i := rr.NextIndex(testBackends(t, 3)); i != 0 {
+		t.Errorf("first index after empty calls = %d, want 0", i)
+	}
+}
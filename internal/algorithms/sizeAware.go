@@ -0,0 +1,48 @@
+package algorithms
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+)
+
+const DefaultLargeRequestSize int64 = 1 << 20
+
+type SizeAware struct {
+	largeRequestSize int64
+	fallback         RoundRobin
+}
+
+func NewSizeAware(largeRequestSize int64) *SizeAware {
+	return &SizeAware{largeRequestSize: largeRequestSize}
+}
+
+func (sa *SizeAware) Select(backends []*backend.Backend) (*backend.Backend, error) {
+	return sa.fallback.Select(backends)
+}
+
+// SelectFor routes requests of at least largeRequestSize bytes to the alive
+// backend with the least request data in flight. Smaller requests, and those
+// of unknown length, fall back to round robin.
+func (sa *SizeAware) SelectFor(r *http.Request, backends []*backend.Backend) (*backend.Backend, error) {
+	if r.ContentLength < sa.largeRequestSize {
+		return sa.fallback.Select(backends)
+	}
+
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("no Backend found")
+	}
+
+	var best *backend.Backend
+	for _, b := range backend.Eligible(backends) {
+		if best == nil || b.BytesInFlight() < best.BytesInFlight() {
+			best = b
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("no Backend found alive")
+	}
+	return best, nil
+}
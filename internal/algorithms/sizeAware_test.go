@@ -0,0 +1,41 @@
+package algorithms
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSizeAwareAvoidsSaturatedBackend(t *testing.T) {
+	backends := testBackends(t, 3)
+	backends[0].AddBytesInFlight(64 << 20)
+	backends[1].AddBytesInFlight(8 << 20)
+	sa := NewSizeAware(DefaultLargeRequestSize)
+
+	upload := httptest.NewRequest(http.MethodPost, "/upload", nil)
+	upload.ContentLength = 4 << 20
+	for range 5 {
+		b, err := sa.SelectFor(upload, backends)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b != backends[2] {
+			t.Fatalf("large upload went to %s, want the backend with no bytes in flight", b.URL)
+		}
+	}
+
+	// Small requests ignore bytes in flight and rotate over every backend.
+	small := httptest.NewRequest(http.MethodPost, "/upload", nil)
+	small.ContentLength = 10
+	seen := map[string]bool{}
+	for range 3 {
+		b, err := sa.SelectFor(small, backends)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[b.URL.String()] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("small requests reached %d backends, want 3", len(seen))
+	}
+}
@@ -0,0 +1,43 @@
+package algorithms
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlowStartReducesShare(t *testing.T) {
+	for _, lb := range []Balancer{&RoundRobin{}, NewWeighted()} {
+		backends := testBackends(t, 2)
+		recovered := backends[1]
+		recovered.SlowStart = time.Hour
+		recovered.SetAlive(false)
+		recovered.SetAlive(true)
+
+		picks := 0
+		for range 1000 {
+			b, err := lb.Select(backends)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b == recovered {
+				picks++
+			}
+		}
+		// At the start of its window the recovered backend is admitted with
+		// the minimum ramp weight of 5%, against 50% once fully up.
+		if picks == 0 || picks > 150 {
+			t.Errorf("%T: recovered backend got %d of 1000 requests, want a small but nonzero share", lb, picks)
+		}
+
+		recovered.HealthyAt = time.Now().Add(-time.Hour)
+		picks = 0
+		for range 1000 {
+			if b, _ := lb.Select(backends); b == recovered {
+				picks++
+			}
+		}
+		if picks < 400 || picks > 600 {
+			t.Errorf("%T: backend past its window got %d of 1000 requests, want about half", lb, picks)
+		}
+	}
+}
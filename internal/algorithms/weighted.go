@@ -0,0 +1,64 @@
+package algorithms
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+)
+
+// Weighted is smooth weighted round robin, as in nginx: each backend gets
+// a share of requests proportional to its Weight, and heavier backends are
+// interleaved with lighter ones rather than picked in bursts. Weights are
+// scaled by RampWeight during slow start.
+type Weighted struct {
+	// current is each backend's running score. Backends that leave the
+	// pool are dropped from it on a later call.
+	current map[*backend.Backend]float64
+	mux     sync.Mutex
+}
+
+func NewWeighted() *Weighted {
+	return &Weighted{current: make(map[*backend.Backend]float64)}
+}
+
+// Select adds every eligible backend's weight to its score, picks the
+// highest score and takes the total weight off the winner. Backends with
+// weight 0 are never picked. Ties go to the earliest backend in the list.
+func (wb *Weighted) Select(backends []*backend.Backend) (*backend.Backend, error) {
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("no Backend found")
+	}
+
+	wb.mux.Lock()
+	defer wb.mux.Unlock()
+
+	var best *backend.Backend
+	var total float64
+	for _, b := range backend.Eligible(backends) {
+		if b.Weight() <= 0 {
+			continue
+		}
+		w := float64(b.Weight()) * b.RampWeight()
+		total += w
+		wb.current[b] += w
+		if best == nil || wb.current[b] > wb.current[best] {
+			best = b
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("no Backend found alive")
+	}
+	wb.current[best] -= total
+
+	if len(wb.current) > len(backends) {
+		for b := range wb.current {
+			if !slices.Contains(backends, b) {
+				delete(wb.current, b)
+			}
+		}
+	}
+	return best, nil
+}
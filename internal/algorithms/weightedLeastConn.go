@@ -0,0 +1,38 @@
+package algorithms
+
+import (
+	"fmt"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+)
+
+type WeightedLeastConnection struct{}
+
+// Select picks the alive backend with the lowest ActiveConnections/Weight,
+// skipping backends with weight 0. A backend in its slow-start window has
+// its weight scaled by RampWeight. Ties go to the earliest backend in the
+// list.
+func (wl *WeightedLeastConnection) Select(backends []*backend.Backend) (*backend.Backend, error) {
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("no Backend found")
+	}
+
+	var best *backend.Backend
+	var bestScore float64
+	for _, b := range backend.Eligible(backends) {
+		if b.Weight() <= 0 {
+			continue
+		}
+		// Counting the request being placed keeps an idle ramping backend
+		// from winning every tie at zero connections.
+		score := float64(b.ActiveConnections()+1) / (float64(b.Weight()) * b.RampWeight())
+		if best == nil || score < bestScore {
+			best, bestScore = b, score
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("no Backend found alive")
+	}
+	return best, nil
+}
@@ -0,0 +1,45 @@
+package algorithms
+
+import "testing"
+
+func TestWeightedLeastConnDistribution(t *testing.T) {
+	backends := testBackends(t, 2)
+	backends[0].SetWeight(3)
+	backends[1].SetWeight(1)
+
+	// Every selection opens a connection that stays open, so each choice
+	// sees the load the previous ones left behind.
+	wl := &WeightedLeastConnection{}
+	counts := map[int]int{}
+	for range 400 {
+		b, err := wl.Select(backends)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.IncrementConnections()
+		if b == backends[0] {
+			counts[0]++
+		} else {
+			counts[1]++
+		}
+	}
+	if counts[0] != 300 || counts[1] != 100 {
+		t.Errorf("connections = %d/%d, want 300/100 for weights 3/1", counts[0], counts[1])
+	}
+}
+
+func TestWeightedLeastConnSkipsZeroWeight(t *testing.T) {
+	backends := testBackends(t, 2)
+	backends[0].SetWeight(0)
+	backends[1].SetWeight(1)
+	withConnections(backends[1], 10)
+
+	wl := &WeightedLeastConnection{}
+	if b, err := wl.Select(backends); err != nil || b != backends[1] {
+		t.Fatalf("selected %v (%v), want the only backend with a weight", b, err)
+	}
+	backends[1].SetWeight(0)
+	if _, err := wl.Select(backends); err == nil {
+		t.Error("selected a backend with every weight 0")
+	}
+}
@@ -0,0 +1,57 @@
+package algorithms
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+)
+
+// WeightedRandom picks an eligible backend with probability proportional
+// to its Weight, scaled by RampWeight during slow start. Unlike weighted
+// round robin it has no fixed order, so separate load balancer instances
+// don't fall into step with each other.
+type WeightedRandom struct {
+	rnd *rand.Rand
+	mux sync.Mutex
+}
+
+func NewWeightedRandom() *WeightedRandom {
+	return &WeightedRandom{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (wr *WeightedRandom) Select(backends []*backend.Backend) (*backend.Backend, error) {
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("no Backend found")
+	}
+
+	candidates := make([]*backend.Backend, 0, len(backends))
+	cumulative := make([]float64, 0, len(backends))
+	var total float64
+	for _, b := range backend.Eligible(backends) {
+		if b.Weight() <= 0 {
+			continue
+		}
+		total += float64(b.Weight()) * b.RampWeight()
+		candidates = append(candidates, b)
+		cumulative = append(cumulative, total)
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no Backend found alive")
+	}
+
+	wr.mux.Lock()
+	target := wr.rnd.Float64() * total
+	wr.mux.Unlock()
+
+	// The first backend whose cumulative weight exceeds target owns it.
+	idx := sort.Search(len(cumulative), func(i int) bool { return cumulative[i] > target })
+	if idx == len(candidates) {
+		idx--
+	}
+	return candidates[idx], nil
+}
@@ -0,0 +1,43 @@
+package algorithms
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestWeightedRandomDistribution(t *testing.T) {
+	backends := testBackends(t, 4)
+	weights := []int{1, 2, 5, 0}
+	for i, w := range weights {
+		backends[i].SetWeight(w)
+	}
+	wr := NewWeightedRandom()
+	// A fixed seed keeps the test deterministic.
+	wr.rnd = rand.New(rand.NewSource(1))
+
+	const n = 80000
+	counts := make(map[int]int)
+	for range n {
+		b, err := wr.Select(backends)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range backends {
+			if backends[i] == b {
+				counts[i]++
+			}
+		}
+	}
+
+	for i, w := range weights {
+		want := float64(w) / 8
+		got := float64(counts[i]) / n
+		if math.Abs(got-want) > 0.01 {
+			t.Errorf("backend %d with weight %d got %.3f of requests, want %.3f", i, w, got, want)
+		}
+	}
+	if counts[3] != 0 {
+		t.Errorf("weight-0 backend was picked %d times", counts[3])
+	}
+}
@@ -2,14 +2,20 @@ package backend
 
 import (
 	"context"
-	"fmt"
+	"crypto/tls"
+	"errors"
+	"math"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/logger"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/metrics"
 	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
 )
 
@@ -19,98 +25,551 @@ type Backend struct {
 	mux          sync.RWMutex
 	ReverseProxy *httputil.ReverseProxy
 	Timeout      time.Duration
-	SuccessCount uint8
-	FailureCount uint8
+	SuccessCount uint32
+	FailureCount uint32
+
+	// ForwardedHeaders adds X-Forwarded-For, X-Real-IP, X-Forwarded-Proto
+	// and X-Forwarded-Host to proxied requests.
+	ForwardedHeaders bool
+	// PreserveHost sends the client's Host header upstream instead of the
+	// backend's host.
+	PreserveHost bool
+	// HostOverride, when set, is sent as the Host header upstream.
+	HostOverride string
+	// Classifier overrides DefaultClassifier for this backend's responses.
+	Classifier Classifier
+	// MaxConcurrent caps ActiveConnections; 0 means unlimited.
+	MaxConcurrent int64
+	// SuccessThreshold is how many consecutive successful responses on live
+	// traffic mark the backend healthy again.
+	SuccessThreshold int
+	// SlowStart is the ramp-up window after the backend becomes healthy;
+	// see RampWeight.
+	SlowStart time.Duration
+	// HealthyAt is when the backend last went from unhealthy to healthy.
+	HealthyAt time.Time
+	// Outlier ejects the backend after repeated live-traffic errors; nil
+	// disables outlier detection.
+	Outlier *OutlierPolicy
+	// ErrorPage is written when the backend fails and no failover is
+	// possible; nil means a plain-text message.
+	ErrorPage *util.ErrorPage
+	// Backoff is how long a failed request waits before it is retried
+	// against this backend.
+	Backoff Backoff
+	// Priority is the backend's failover tier; see ActiveTier.
+	Priority uint
+	// HealthCheck overrides the health checker's timing and thresholds
+	// for this backend; nil uses them as configured.
+	HealthCheck *config.HealthCheckOverride
+
+	failureThreshold int
+	removed          context.Context
+	remove           context.CancelCauseFunc
+	weight           atomic.Int64
+	draining         atomic.Bool
+	probes           probeStats
+	activeConns      atomic.Int64
+	bytesInFlight    atomic.Int64
+	latencyEWMA      atomic.Int64
+	outliers         outlierState
+	transport        *http.Transport
 }
 
 func NewBackend(url *url.URL, failureThreshold int, timeout time.Duration) *Backend {
-    backend := &Backend{
-        URL:     url,
-        Alive:   false,
-        Timeout: timeout,
-    }
-
-    proxy := httputil.NewSingleHostReverseProxy(url)
-    
-    proxy.Transport = &http.Transport{
-        MaxIdleConns:        1000,   
-        MaxIdleConnsPerHost: 200,  
-        MaxConnsPerHost:     0,    
-        IdleConnTimeout:     90 * time.Second,
-        
-        DisableKeepAlives:   false,
-        
-        DialContext: (&net.Dialer{
-            Timeout:   5 * time.Second,   
-            KeepAlive: 30 * time.Second, 
-        }).DialContext,
-        
-        TLSHandshakeTimeout:   10 * time.Second,
-        ResponseHeaderTimeout: timeout,
-        ExpectContinueTimeout: 1 * time.Second,
-      
-        ForceAttemptHTTP2: true,
-    }
-
-    proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-        fmt.Printf("[%s] %s\n", url, err.Error())
-
-        retries := util.GetRetryFromContext(r)
-        if retries < failureThreshold {
-            time.Sleep(10 * time.Millisecond)
-            ctx := context.WithValue(r.Context(), util.CtxRetryKey, retries+1)
-            backend.UpdateFailureCount(failureThreshold)
-            proxy.ServeHTTP(w, r.WithContext(ctx))
-            return
-        }
-
-        http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
-    }
-
-    backend.ReverseProxy = proxy
-    return backend
+	backend := &Backend{
+		URL:              url,
+		Alive:            false,
+		Timeout:          timeout,
+		failureThreshold: failureThreshold,
+	}
+
+	backend.removed, backend.remove = context.WithCancelCause(context.Background())
+	backend.Backoff = DefaultBackoff
+	backend.weight.Store(1)
+
+	proxy := httputil.NewSingleHostReverseProxy(url)
+
+	backend.transport = &http.Transport{
+		MaxIdleConns:        1000,
+		MaxIdleConnsPerHost: 200,
+		MaxConnsPerHost:     0,
+		IdleConnTimeout:     90 * time.Second,
+
+		DisableKeepAlives: false,
+
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: timeout,
+		ExpectContinueTimeout: 1 * time.Second,
+
+		ForceAttemptHTTP2: true,
+	}
+	proxy.Transport = backend.transport
+
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		originalHost := req.Host
+		director(req)
+
+		switch {
+		case backend.HostOverride != "":
+			req.Host = backend.HostOverride
+		case !backend.PreserveHost:
+			// An empty Host makes net/http send req.URL.Host, the backend.
+			req.Host = ""
+		}
+
+		if !backend.ForwardedHeaders {
+			// A nil entry stops ReverseProxy from appending the client IP.
+			req.Header["X-Forwarded-For"] = nil
+			return
+		}
+		setForwardedHeaders(req, originalHost)
+	}
+
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		backend.recordOutcome(backend.classifier().Classify(resp))
+		return nil
+	}
+
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		logger.Warn("proxy error", "request_id", util.GetRequestIDFromContext(r), "backend", url.String(), "path", r.URL.Path, "client", r.RemoteAddr, "error", err)
+		a, _ := r.Context().Value(attemptKey{}).(*attempt)
+		if a == nil {
+			a = &attempt{}
+		}
+
+		// The backend was forcibly removed; its own health is not in
+		// question, and failover can still place the request elsewhere.
+		removed := context.Cause(r.Context()) == ErrBackendRemoved
+
+		// The client went away; that says nothing about the backend.
+		if r.Context().Err() == context.Canceled && !removed {
+			return
+		}
+
+		// The body outgrew the proxy's limit, which is the client's fault.
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			util.WriteError(w, backend.ErrorPage, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		// A timed-out request has used up its deadline, so retrying the same
+		// backend under it is pointless; hand it straight to failover.
+		timedOut := r.Context().Err() == context.DeadlineExceeded
+
+		retries := util.GetRetryFromContext(r)
+		if !timedOut && !removed && replayable(r) && retries < failureThreshold {
+			backend.UpdateFailureCount(failureThreshold)
+			metrics.IncBackendRetries(url.String())
+			a.retry = true
+			return
+		}
+
+		if !removed {
+			backend.recordOutcome(OutcomeError)
+		}
+		backend.giveUp(w, a)
+	}
+
+	backend.ReverseProxy = proxy
+	return backend
+}
+
+// attempt carries what the error handler decided back out of
+// ReverseProxy.ServeHTTP, so retries and failover start from the inbound
+// request rather than the one the director already rewrote.
+type attempt struct {
+	// canFailover is set by the caller when another backend may take the
+	// request.
+	canFailover bool
+	// retry asks Serve to send the request to this backend again.
+	retry bool
+	// failed means nothing was written and the caller should fail over.
+	failed bool
+}
+
+type attemptKey struct{}
+
+// Serve proxies r, the request as received from the client, to the backend
+// and retries it there while it is replayable and the backend keeps
+// failing. It reports whether the backend failed to answer: that only
+// happens when canFailover is set, with nothing written to w so the caller
+// can send r elsewhere. Otherwise a backend that can't answer gets the
+// client a 502.
+func (b *Backend) Serve(w http.ResponseWriter, r *http.Request, canFailover bool) (failed bool) {
+	for retries := 0; ; retries++ {
+		a := &attempt{canFailover: canFailover}
+		ctx := context.WithValue(r.Context(), attemptKey{}, a)
+		ctx = context.WithValue(ctx, util.CtxRetryKey, retries)
+		b.ReverseProxy.ServeHTTP(w, r.WithContext(ctx))
+		if !a.retry {
+			return a.failed
+		}
+
+		err := b.Backoff.Wait(r.Context(), retries)
+		if err == context.Canceled {
+			return false
+		}
+		// A deadline that runs out while backing off falls through to
+		// failover like any other timeout.
+		if err != nil {
+			b.recordOutcome(OutcomeError)
+			b.giveUp(w, a)
+			return a.failed
+		}
+		if r.GetBody != nil {
+			if body, err := r.GetBody(); err == nil {
+				r = r.WithContext(r.Context())
+				r.Body = body
+			}
+		}
+	}
+}
+
+// giveUp ends an attempt the backend couldn't answer: it is left to the
+// caller to fail over when it can, and is answered with a 502 otherwise.
+func (b *Backend) giveUp(w http.ResponseWriter, a *attempt) {
+	a.retry = false
+	if a.canFailover {
+		a.failed = true
+		return
+	}
+	// The backend was selected but did not answer, which is a gateway
+	// failure rather than a lack of capacity.
+	util.WriteError(w, b.ErrorPage, "Bad Gateway", http.StatusBadGateway)
+}
+
+// replayable reports whether r may be sent again: the proxy must allow it,
+// and a body must have been buffered since the first attempt consumed it.
+func replayable(r *http.Request) bool {
+	return util.IsReplayable(r) && (!util.HasBody(r) || r.GetBody != nil)
+}
+
+// SetTLSConfig sets the TLS settings used to reach the backend, for both
+// proxied requests and health probes.
+func (b *Backend) SetTLSConfig(cfg *tls.Config) {
+	b.transport.TLSClientConfig = cfg
+}
+
+// SetTransportLimits sizes the backend's connection pool. Like
+// SetTLSConfig it must be called before the backend serves requests.
+func (b *Backend) SetTransportLimits(uc config.UpstreamConfig) {
+	b.transport.MaxIdleConns = uc.MaxIdleConns
+	b.transport.MaxIdleConnsPerHost = uc.MaxIdleConnsPerHost
+	b.transport.MaxConnsPerHost = uc.MaxConnsPerHost
+	b.transport.IdleConnTimeout = uc.IdleConnTimeout
+	if uc.DisableHTTP2 {
+		b.transport.ForceAttemptHTTP2 = false
+		// A non-nil, empty map stops the transport from negotiating h2.
+		b.transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+}
+
+// SetProtocol pins the HTTP version spoken to the backend, overriding
+// DisableHTTP2 from SetTransportLimits. An empty protocol leaves the
+// transport to negotiate.
+func (b *Backend) SetProtocol(p config.BackendProtocol) {
+	protocols := new(http.Protocols)
+	switch p {
+	case config.HTTP1:
+		protocols.SetHTTP1(true)
+	case config.H2:
+		protocols.SetHTTP2(true)
+	case config.H2C:
+		protocols.SetUnencryptedHTTP2(true)
+	default:
+		return
+	}
+	b.transport.Protocols = protocols
+	b.transport.TLSNextProto = nil
+}
+
+// Transport returns the transport requests to the backend are sent over.
+func (b *Backend) Transport() *http.Transport {
+	return b.transport
+}
+
+// setForwardedHeaders sets X-Real-IP, X-Forwarded-Proto and, from the Host
+// the client asked for, X-Forwarded-Host. X-Forwarded-For is left to
+// ReverseProxy, which appends the client IP to any existing chain once the
+// director returns.
+func setForwardedHeaders(req *http.Request, originalHost string) {
+	clientIP, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		clientIP = req.RemoteAddr
+	}
+	req.Header.Set("X-Real-IP", clientIP)
+
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+	req.Header.Set("X-Forwarded-Proto", proto)
+	if originalHost != "" {
+		req.Header.Set("X-Forwarded-Host", originalHost)
+	}
+}
+
+func (b *Backend) classifier() Classifier {
+	if b.Classifier != nil {
+		return b.Classifier
+	}
+	return DefaultClassifier
+}
+
+// recordOutcome feeds a live response into the backend's metrics and into the
+// same success/failure counters the active health check uses, so a backend
+// failing real traffic is taken out without waiting for the next probe.
+func (b *Backend) recordOutcome(o Outcome) {
+	wasAlive := b.IsAlive()
+	b.recordOutlier(o)
+
+	switch o {
+	case OutcomeError:
+		metrics.IncBackendErrors(b.URL.String())
+		b.UpdateFailureCount(b.failureThreshold)
+	case OutcomeDegraded:
+		metrics.IncBackendDegraded(b.URL.String())
+	case OutcomeSuccess:
+		b.UpdateSuccessCount(b.SuccessThreshold)
+	}
+
+	if b.IsAlive() != wasAlive {
+		metrics.IncHealthTransitions(b.URL.String())
+	}
 }
 
 func (b *Backend) IsAlive() (alive bool) {
 	b.mux.RLock()
 	alive = b.Alive
 	b.mux.RUnlock()
-	return
+	return alive && !b.draining.Load() && !b.Ejected()
+}
+
+// Drain takes the backend out of selection while letting requests already
+// in flight run to completion. Health checks cannot revive it until Enable.
+func (b *Backend) Drain() {
+	b.draining.Store(true)
+}
+
+// Enable undoes Drain; the backend rejoins selection as soon as it is healthy.
+func (b *Backend) Enable() {
+	b.draining.Store(false)
+}
+
+func (b *Backend) IsDraining() bool {
+	return b.draining.Load()
+}
+
+// ErrBackendRemoved is the cancellation cause of requests still in flight
+// to a backend when it is closed.
+var ErrBackendRemoved = errors.New("backend removed from the pool")
+
+// Bind ties ctx to the backend's lifetime: the returned context is also
+// cancelled, with cause ErrBackendRemoved, once the backend is closed.
+func (b *Backend) Bind(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(b.removed, func() { cancel(ErrBackendRemoved) })
+	return ctx, func() {
+		stop()
+		cancel(context.Canceled)
+	}
+}
+
+// Close aborts the requests still in flight to the backend, through the
+// contexts given out by Bind, and closes its idle connections. The pool
+// calls it once a removed backend has drained or its drain timeout has run
+// out.
+func (b *Backend) Close() {
+	b.remove(ErrBackendRemoved)
+	b.transport.CloseIdleConnections()
+}
+
+// WaitDrained blocks until the backend has no active connections or timeout
+// elapses, reporting whether it fully drained.
+func (b *Backend) WaitDrained(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for b.ActiveConnections() > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return true
 }
 
 func (b *Backend) SetAlive(alive bool) {
 	b.mux.Lock()
-	b.Alive = alive
+	b.setAliveLocked(alive)
 	b.mux.Unlock()
 }
 
+func (b *Backend) setAliveLocked(alive bool) {
+	if alive && !b.Alive {
+		b.HealthyAt = time.Now()
+	}
+	b.Alive = alive
+}
+
+// Weight is the backend's relative capacity for weighted strategies. A
+// weight of 0 takes it out of weighted selection.
+func (b *Backend) Weight() int {
+	return int(b.weight.Load())
+}
+
+// SetWeight changes the backend's weight; reloads use it to reweight a
+// backend without replacing it.
+func (b *Backend) SetWeight(weight int) {
+	b.weight.Store(int64(weight))
+}
+
+// minRampWeight keeps a just-recovered backend from being starved entirely
+// at the very start of its slow-start window.
+const minRampWeight = 0.05
+
+// RampWeight is the fraction of its normal share of traffic the backend
+// should get: it rises linearly from near 0 to 1 over SlowStart after the
+// backend became healthy, and is 1 outside that window.
+func (b *Backend) RampWeight() float64 {
+	if b.SlowStart <= 0 {
+		return 1
+	}
+
+	b.mux.RLock()
+	healthyAt := b.HealthyAt
+	b.mux.RUnlock()
+
+	elapsed := time.Since(healthyAt)
+	if healthyAt.IsZero() || elapsed >= b.SlowStart {
+		return 1
+	}
+	return max(float64(elapsed)/float64(b.SlowStart), minRampWeight)
+}
+
+// UpdateSuccessCount counts a successful probe or response and marks the
+// backend alive once threshold are seen in a row. The comparison happens
+// under the same lock as the increment, so concurrent callers cause at most
+// one transition.
 func (b *Backend) UpdateSuccessCount(threshold int) {
 	b.mux.Lock()
-	b.SuccessCount++
+	defer b.mux.Unlock()
+
+	if b.SuccessCount < math.MaxUint32 {
+		b.SuccessCount++
+	}
 	b.FailureCount = 0
-	b.mux.Unlock()
 
-	if b.SuccessCount >= uint8(threshold) {
-		b.SetAlive(true)
-		b.ResetCounts()
+	if int64(b.SuccessCount) >= int64(threshold) {
+		b.setAliveLocked(true)
+		b.SuccessCount = 0
 	}
 }
 
+// UpdateFailureCount is the counterpart of UpdateSuccessCount, marking the
+// backend dead after threshold failures in a row.
 func (b *Backend) UpdateFailureCount(threshold int) {
 	b.mux.Lock()
-	b.FailureCount++
+	defer b.mux.Unlock()
+
+	if b.FailureCount < math.MaxUint32 {
+		b.FailureCount++
+	}
 	b.SuccessCount = 0
-	b.mux.Unlock()
 
-	if b.FailureCount >= uint8(threshold) {
-		b.SetAlive(false)
-		b.ResetCounts()
+	if int64(b.FailureCount) >= int64(threshold) {
+		b.setAliveLocked(false)
+		b.FailureCount = 0
 	}
 }
 
+func (b *Backend) Counts() (success, failure uint32) {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.SuccessCount, b.FailureCount
+}
+
 func (b *Backend) ResetCounts() {
 	b.mux.Lock()
 	b.SuccessCount = 0
 	b.FailureCount = 0
 	b.mux.Unlock()
 }
+
+func (b *Backend) RecordProbe(duration time.Duration, success bool) {
+	b.probes.record(duration, success)
+}
+
+func (b *Backend) ProbeMetrics() ProbeMetrics {
+	return b.probes.snapshot()
+}
+
+func (b *Backend) IncrementConnections() {
+	b.activeConns.Add(1)
+}
+
+// TryAcquire takes a connection slot unless the backend is already at
+// MaxConcurrent. A successful call must be paired with DecrementConnections.
+func (b *Backend) TryAcquire() bool {
+	if b.MaxConcurrent <= 0 {
+		b.activeConns.Add(1)
+		return true
+	}
+	for {
+		n := b.activeConns.Load()
+		if n >= b.MaxConcurrent {
+			return false
+		}
+		if b.activeConns.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+func (b *Backend) Saturated() bool {
+	return b.MaxConcurrent > 0 && b.activeConns.Load() >= b.MaxConcurrent
+}
+
+func (b *Backend) DecrementConnections() {
+	b.activeConns.Add(-1)
+}
+
+func (b *Backend) ActiveConnections() int64 {
+	return b.activeConns.Load()
+}
+
+func (b *Backend) AddBytesInFlight(n int64) {
+	b.bytesInFlight.Add(n)
+}
+
+func (b *Backend) BytesInFlight() int64 {
+	return b.bytesInFlight.Load()
+}
+
+// latencyAlpha weights each new sample against the running average.
+const latencyAlpha = 0.3
+
+func (b *Backend) RecordLatency(d time.Duration) {
+	for {
+		prev := b.latencyEWMA.Load()
+		next := int64(d)
+		if prev != 0 {
+			next = int64(latencyAlpha*float64(d) + (1-latencyAlpha)*float64(prev))
+		}
+		if b.latencyEWMA.CompareAndSwap(prev, next) {
+			return
+		}
+	}
+}
+
+// LatencyEWMA is the moving average response time, or 0 before the first
+// request completes.
+func (b *Backend) LatencyEWMA() time.Duration {
+	return time.Duration(b.latencyEWMA.Load())
+}
@@ -0,0 +1,228 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestBackend(t *testing.T, h http.Handler) *Backend {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBackend(u, 3, time.Second)
+	b.SetAlive(true)
+	return b
+}
+
+func TestForwardedHeaders(t *testing.T) {
+	var got http.Header
+	b := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	b.ForwardedHeaders = true
+
+	req := httptest.NewRequest(http.MethodGet, "http://lb.example/x", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	b.Serve(httptest.NewRecorder(), req, false)
+
+	want := map[string]string{
+		"X-Forwarded-For":   "198.51.100.7, 192.0.2.1",
+		"X-Real-Ip":         "192.0.2.1",
+		"X-Forwarded-Proto": "http",
+		"X-Forwarded-Host":  "lb.example",
+	}
+	for k, v := range want {
+		if got.Get(k) != v {
+			t.Errorf("%s = %q, want %q", k, got.Get(k), v)
+		}
+	}
+}
+
+func TestForwardedHeadersDisabled(t *testing.T) {
+	var got http.Header
+	b := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "http://lb.example/x", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	b.Serve(httptest.NewRecorder(), req, false)
+
+	for _, k := range []string{"X-Forwarded-For", "X-Real-Ip", "X-Forwarded-Proto"} {
+		if v := got.Get(k); v != "" {
+			t.Errorf("%s = %q, want it unset", k, v)
+		}
+	}
+}
+
+func TestHostHeaderModes(t *testing.T) {
+	var host, forwardedHost string
+	b := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, forwardedHost = r.Host, r.Header.Get("X-Forwarded-Host")
+	}))
+	b.ForwardedHeaders = true
+
+	for _, tc := range []struct {
+		name     string
+		preserve bool
+		override string
+		want     string
+	}{
+		{"rewritten to the backend", false, "", b.URL.Host},
+		{"preserved", true, "", "lb.example"},
+		{"overridden", false, "api.internal", "api.internal"},
+	} {
+		b.PreserveHost, b.HostOverride = tc.preserve, tc.override
+		host, forwardedHost = "", ""
+		b.Serve(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://lb.example/x", nil), false)
+		if host != tc.want {
+			t.Errorf("%s: upstream Host = %q, want %q", tc.name, host, tc.want)
+		}
+		if forwardedHost != "lb.example" {
+			t.Errorf("%s: X-Forwarded-Host = %q, want the client's lb.example", tc.name, forwardedHost)
+		}
+	}
+}
+
+// A retry must go through the director once, not once per attempt.
+func TestRetryRewritesOnce(t *testing.T) {
+	var calls atomic.Int32
+	var xff, path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		xff, path = r.Header.Get("X-Forwarded-For"), r.URL.Path
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL + "/api")
+	b := NewBackend(u, 3, time.Second)
+	b.SetAlive(true)
+	b.ForwardedHeaders = true
+	b.Backoff = Backoff{}
+
+	req := httptest.NewRequest(http.MethodGet, "http://lb.example/x", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	rec := httptest.NewRecorder()
+	if b.Serve(rec, req, false) {
+		t.Fatal("Serve reported a failure after a successful retry")
+	}
+
+	if calls.Load() != 2 {
+		t.Fatalf("backend saw %d requests, want 2", calls.Load())
+	}
+	if xff != "192.0.2.1" {
+		t.Errorf("X-Forwarded-For = %q, want the client IP once", xff)
+	}
+	if path != "/api/x" {
+		t.Errorf("path = %q, want /api/x", path)
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+}
+
+func TestServeFailsOverOnlyWhenAllowed(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	u, _ := url.Parse(srv.URL)
+	srv.Close()
+
+	for _, canFailover := range []bool{true, false} {
+		b := NewBackend(u, 1, time.Second)
+		b.SetAlive(true)
+		b.Backoff = Backoff{}
+
+		rec := httptest.NewRecorder()
+		failed := b.Serve(rec, httptest.NewRequest(http.MethodGet, "/", nil), canFailover)
+		if failed != canFailover {
+			t.Errorf("canFailover=%v: failed = %v", canFailover, failed)
+		}
+		if !canFailover && rec.Code != http.StatusBadGateway {
+			t.Errorf("status = %d, want 502", rec.Code)
+		}
+		if canFailover && rec.Body.Len() != 0 {
+			t.Errorf("wrote %q before failover", rec.Body.String())
+		}
+	}
+}
+
+func TestPassiveHealth(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	b := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	b.SuccessThreshold = 2
+
+	serve := func() int {
+		rec := httptest.NewRecorder()
+		b.Serve(rec, httptest.NewRequest(http.MethodGet, "/", nil), false)
+		return rec.Code
+	}
+
+	// No health check exists; only live responses move the backend.
+	for i := range 3 {
+		if code := serve(); code != http.StatusBadGateway {
+			t.Fatalf("request %d: status %d, want the backend's 502", i, code)
+		}
+		if alive := b.IsAlive(); alive != (i < 2) {
+			t.Fatalf("after %d 502s alive = %v", i+1, alive)
+		}
+	}
+
+	failing.Store(false)
+	serve()
+	if b.IsAlive() {
+		t.Fatal("one success brought the backend back, want 2")
+	}
+	serve()
+	if !b.IsAlive() {
+		t.Error("backend is still down after 2 successful responses")
+	}
+}
+
+func TestConcurrentFailureThreshold(t *testing.T) {
+	// Above 255, so an 8-bit counter would wrap before reaching it.
+	const threshold = 300
+	b := newTestBackend(t, http.NotFoundHandler())
+
+	hammer := func(n int) {
+		var wg sync.WaitGroup
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				b.UpdateFailureCount(threshold)
+			}()
+		}
+		wg.Wait()
+	}
+
+	hammer(threshold - 1)
+	if _, failures := b.Counts(); !b.IsAlive() || failures != threshold-1 {
+		t.Fatalf("after %d failures: alive %v, count %d; want alive with every failure counted", threshold-1, b.IsAlive(), failures)
+	}
+
+	// The 300th failure takes the backend down and resets the count once;
+	// the other 149 start the count over.
+	hammer(150)
+	if _, failures := b.Counts(); b.IsAlive() || failures != 149 {
+		t.Errorf("after %d failures: alive %v, count %d; want down after exactly one transition, count 149", threshold+149, b.IsAlive(), failures)
+	}
+}
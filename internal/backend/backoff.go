@@ -0,0 +1,61 @@
+package backend
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff spaces out retries of a failed request against the same backend.
+// The delay starts at Base and doubles with every attempt, capped at Max;
+// with Jitter a random delay up to that value is used instead, so clients
+// retrying together don't hit the backend in lockstep.
+type Backoff struct {
+	Base   time.Duration
+	Max    time.Duration
+	Jitter bool
+}
+
+// DefaultBackoff is the fixed 10ms pause retries used before backoff became
+// configurable.
+var DefaultBackoff = Backoff{Base: 10 * time.Millisecond, Max: 10 * time.Millisecond}
+
+// Delay returns how long to wait before retry number attempt, counting from 0.
+func (b Backoff) Delay(attempt int) time.Duration {
+	if b.Base <= 0 {
+		return 0
+	}
+
+	d := b.Base
+	for i := 0; i < attempt && (b.Max <= 0 || d < b.Max) && d <= math.MaxInt64/2; i++ {
+		d *= 2
+	}
+	if b.Max > 0 && d > b.Max {
+		d = b.Max
+	}
+
+	if b.Jitter {
+		d = time.Duration(rand.Int63n(int64(d) + 1))
+	}
+	return d
+}
+
+// Wait sleeps for Delay(attempt), returning early with the context's error
+// if ctx is done first.
+func (b Backoff) Wait(ctx context.Context, attempt int) error {
+	d := b.Delay(attempt)
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Base: 10 * time.Millisecond, Max: 100 * time.Millisecond}
+	for attempt, want := range []time.Duration{10, 20, 40, 80, 100, 100} {
+		if got := b.Delay(attempt); got != want*time.Millisecond {
+			t.Errorf("attempt %d: delay %v, want %v", attempt, got, want*time.Millisecond)
+		}
+	}
+
+	uncapped := Backoff{Base: time.Second}
+	if got := uncapped.Delay(100); got < uncapped.Delay(99) || got < time.Duration(math.MaxInt64/2) {
+		t.Errorf("uncapped delay after 100 attempts = %v, want it to saturate rather than overflow", got)
+	}
+
+	jittered := Backoff{Base: 10 * time.Millisecond, Max: 40 * time.Millisecond, Jitter: true}
+	for range 100 {
+		if d := jittered.Delay(5); d < 0 || d > 40*time.Millisecond {
+			t.Fatalf("jittered delay %v outside [0, 40ms]", d)
+		}
+	}
+
+	if d := (Backoff{}).Delay(3); d != 0 {
+		t.Errorf("zero backoff delay = %v, want 0", d)
+	}
+}
+
+func TestBackoffWaitCancelled(t *testing.T) {
+	b := Backoff{Base: time.Minute, Max: time.Minute}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	if err := b.Wait(ctx, 0); err != context.Canceled {
+		t.Errorf("Wait = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Wait took %v after the context was cancelled", elapsed)
+	}
+
+	if err := b.Wait(ctx, 0); err != context.Canceled {
+		t.Errorf("Wait on a cancelled context = %v, want context.Canceled", err)
+	}
+}
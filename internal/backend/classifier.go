@@ -0,0 +1,52 @@
+package backend
+
+import "net/http"
+
+type Outcome int
+
+const (
+	OutcomeSuccess Outcome = iota
+	OutcomeDegraded
+	OutcomeError
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeSuccess:
+		return "success"
+	case OutcomeDegraded:
+		return "degraded"
+	case OutcomeError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// Classifier decides how an upstream response counts towards a backend's
+// metrics and health. Implementations may inspect the body but must leave
+// resp.Body readable for the client.
+type Classifier interface {
+	Classify(resp *http.Response) Outcome
+}
+
+type ClassifierFunc func(resp *http.Response) Outcome
+
+func (f ClassifierFunc) Classify(resp *http.Response) Outcome {
+	return f(resp)
+}
+
+// StatusClassifier treats 5xx as errors and 429 as degraded.
+type StatusClassifier struct{}
+
+func (StatusClassifier) Classify(resp *http.Response) Outcome {
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return OutcomeError
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return OutcomeDegraded
+	}
+	return OutcomeSuccess
+}
+
+// DefaultClassifier is used by backends that don't set their own.
+var DefaultClassifier Classifier = StatusClassifier{}
@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/metrics"
+)
+
+// errorEnvelope treats a 200 whose body reports an error as an error.
+var errorEnvelope = ClassifierFunc(func(resp *http.Response) Outcome {
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if bytes.Contains(body, []byte(`"error"`)) {
+		return OutcomeError
+	}
+	return StatusClassifier{}.Classify(resp)
+})
+
+func TestCustomClassifier(t *testing.T) {
+	b := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error": "upstream exploded"}`))
+	}))
+	b.Classifier = errorEnvelope
+
+	for range 3 {
+		rec := httptest.NewRecorder()
+		b.Serve(rec, httptest.NewRequest(http.MethodGet, "/", nil), false)
+		if rec.Code != http.StatusOK || rec.Body.String() != `{"error": "upstream exploded"}` {
+			t.Fatalf("client got %d %q, want the response untouched", rec.Code, rec.Body.String())
+		}
+	}
+
+	if _, errs := metrics.BackendTotals(b.URL.String()); errs != 3 {
+		t.Errorf("backend errors = %d, want 3", errs)
+	}
+	if b.IsAlive() {
+		t.Error("backend is still alive after 3 classified errors")
+	}
+}
+
+func TestStatusClassifier(t *testing.T) {
+	for status, want := range map[int]Outcome{
+		http.StatusOK:                  OutcomeSuccess,
+		http.StatusNotFound:            OutcomeSuccess,
+		http.StatusTooManyRequests:     OutcomeDegraded,
+		http.StatusInternalServerError: OutcomeError,
+		http.StatusBadGateway:          OutcomeError,
+	} {
+		if got := (StatusClassifier{}).Classify(&http.Response{StatusCode: status}); got != want {
+			t.Errorf("%d: %v, want %v", status, got, want)
+		}
+	}
+}
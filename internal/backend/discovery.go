@@ -0,0 +1,242 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/logger"
+)
+
+// Resolver is the subset of *net.Resolver discovery uses, so tests can
+// stand in their own records.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+const defaultDiscoveryInterval = 30 * time.Second
+
+// Discovery keeps a pool in line with DNS for backend entries that name a
+// fleet rather than a single server. Every refresh resolves each entry and
+// adds a backend per new address, while backends whose address is gone are
+// drained and removed.
+type Discovery struct {
+	pool     *ServerPool
+	entries  []config.BackendConfig
+	config   *config.Config
+	resolver Resolver
+	interval time.Duration
+
+	// owned holds, per entry, the URLs of the backends discovery added, so
+	// statically configured backends are never removed by it.
+	owned map[string]map[string]bool
+
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// NewDiscovery resolves the discovered entries among bcs into pool. A nil
+// resolver uses net.DefaultResolver.
+func NewDiscovery(pool *ServerPool, bcs []config.BackendConfig, cb *config.Config, resolver Resolver) *Discovery {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	var entries []config.BackendConfig
+	for _, bc := range bcs {
+		if bc.Discovered() {
+			entries = append(entries, bc)
+		}
+	}
+	interval := cb.LoadBalancing.DiscoveryInterval
+	if interval <= 0 {
+		interval = defaultDiscoveryInterval
+	}
+	return &Discovery{
+		pool:     pool,
+		entries:  entries,
+		config:   cb,
+		resolver: resolver,
+		interval: interval,
+		owned:    make(map[string]map[string]bool),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Empty reports whether there is nothing to discover.
+func (d *Discovery) Empty() bool {
+	return len(d.entries) == 0
+}
+
+// Start refreshes immediately and then every interval until Stop.
+func (d *Discovery) Start() {
+	d.startOnce.Do(func() {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+
+			ticker := time.NewTicker(d.interval)
+			defer ticker.Stop()
+
+			for {
+				d.Refresh(context.Background())
+				select {
+				case <-ticker.C:
+				case <-d.stopChan:
+					return
+				}
+			}
+		}()
+	})
+}
+
+// Stop ends the refresh loop and waits for it to exit.
+func (d *Discovery) Stop() {
+	d.stopOnce.Do(func() { close(d.stopChan) })
+	d.wg.Wait()
+}
+
+// Refresh resolves every entry once and applies the difference to the
+// pool. An entry that fails to resolve keeps its current backends, so a DNS
+// outage doesn't empty the pool.
+func (d *Discovery) Refresh(ctx context.Context) {
+	present := make(map[string]bool)
+	for _, b := range d.pool.Snapshot() {
+		present[b.URL.String()] = true
+	}
+
+	var added []*Backend
+	var removed []string
+	for _, entry := range d.entries {
+		urls, err := d.resolve(ctx, entry.Url)
+		if err != nil {
+			logger.Warn("backend discovery failed, keeping current backends", "entry", entry.Url, "error", err)
+			continue
+		}
+
+		owned := d.owned[entry.Url]
+		if owned == nil {
+			owned = make(map[string]bool)
+			d.owned[entry.Url] = owned
+		}
+
+		for _, u := range urls {
+			if present[u] {
+				continue
+			}
+			bc := d.discovered(entry, u)
+			b, err := NewBackendFromConfig(bc, d.config)
+			if err != nil {
+				logger.Warn("discovered backend rejected", "backend", u, "error", err)
+				continue
+			}
+			added = append(added, b)
+			present[u] = true
+			owned[u] = true
+		}
+
+		for u := range owned {
+			if !slices.Contains(urls, u) {
+				removed = append(removed, u)
+				delete(owned, u)
+			}
+		}
+	}
+
+	if len(added) > 0 {
+		d.pool.AddBackends(added)
+		for _, b := range added {
+			logger.Info("backend discovered", "backend", b.URL.String())
+		}
+	}
+	if len(removed) > 0 {
+		slices.Sort(removed)
+		logger.Info("discovered backends gone", "backends", removed)
+		d.pool.RemoveBackends(removed)
+	}
+}
+
+// discovered is the config of the backend found at u for entry. An A/AAAA
+// entry's URLs carry an IP, so the entry's own host is kept as the Host
+// header, unless preserve_host is set, and as the TLS server name, unless
+// one is configured. SRV targets are host names already.
+func (d *Discovery) discovered(entry config.BackendConfig, u string) config.BackendConfig {
+	bc := entry
+	bc.Url = u
+	eu, err := url.Parse(entry.Url)
+	if err != nil || strings.HasPrefix(eu.Scheme, "dns+srv") {
+		return bc
+	}
+	if bc.Host == "" && !bc.PreserveHost {
+		bc.Host = eu.Host
+	}
+	if eu.Scheme == "https" {
+		tc := d.config.Proxy.UpstreamTLS
+		if entry.TLS != nil {
+			tc = *entry.TLS
+		}
+		if tc.ServerName == "" {
+			tc.ServerName = eu.Hostname()
+		}
+		bc.TLS = &tc
+	}
+	return bc
+}
+
+// resolve turns one entry into backend URLs. dns+srv://name (or
+// dns+srv+https://name) resolves the SRV record name to one http (or https)
+// URL per target; any other URL resolves its host to every A and AAAA
+// record, keeping the scheme, port and path.
+func (d *Discovery) resolve(ctx context.Context, raw string) ([]string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if scheme, ok := strings.CutPrefix(u.Scheme, "dns+srv"); ok {
+		scheme = strings.TrimPrefix(scheme, "+")
+		if scheme == "" {
+			scheme = "http"
+		}
+		_, records, err := d.resolver.LookupSRV(ctx, "", "", u.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		urls := make([]string, 0, len(records))
+		for _, srv := range records {
+			target := strings.TrimSuffix(srv.Target, ".")
+			out := url.URL{Scheme: scheme, Host: net.JoinHostPort(target, strconv.Itoa(int(srv.Port))), Path: u.Path}
+			urls = append(urls, out.String())
+		}
+		return urls, nil
+	}
+
+	addrs, err := d.resolver.LookupHost(ctx, u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("%s has no addresses", u.Hostname())
+	}
+	urls := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		out := *u
+		out.Host = addr
+		if port := u.Port(); port != "" {
+			out.Host = net.JoinHostPort(addr, port)
+		} else if strings.Contains(addr, ":") {
+			out.Host = "[" + addr + "]"
+		}
+		urls = append(urls, out.String())
+	}
+	return urls, nil
+}
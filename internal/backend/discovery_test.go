@@ -0,0 +1,115 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+)
+
+// stubResolver answers from records set by the test; a missing name fails
+// to resolve.
+type stubResolver struct {
+	mux   sync.Mutex
+	hosts map[string][]string
+	srv   map[string][]*net.SRV
+}
+
+func (r *stubResolver) set(host string, addrs ...string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if addrs == nil {
+		delete(r.hosts, host)
+		return
+	}
+	r.hosts[host] = addrs
+}
+
+func (r *stubResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	addrs, ok := r.hosts[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return addrs, nil
+}
+
+func (r *stubResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	records, ok := r.srv[name]
+	if !ok {
+		return "", nil, errors.New("no such host")
+	}
+	return name, records, nil
+}
+
+func poolURLs(sp *ServerPool) []string {
+	var out []string
+	for _, b := range sp.Snapshot() {
+		out = append(out, b.URL.String())
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestDiscoveryFollowsDNS(t *testing.T) {
+	static := mustBackend(t, "http://10.0.9.1:80")
+	sp := &ServerPool{Backends: []*Backend{static}, drainTimeout: time.Second}
+	resolver := &stubResolver{hosts: map[string][]string{}}
+	entries := []config.BackendConfig{
+		{Url: static.URL.String(), Timeout: time.Second},
+		{Url: "http://fleet.internal:8080", Timeout: time.Second, Discover: true},
+	}
+	d := NewDiscovery(sp, entries, &config.Config{}, resolver)
+
+	refresh := func(want ...string) {
+		t.Helper()
+		d.Refresh(context.Background())
+		if got := poolURLs(sp); !slices.Equal(got, want) {
+			t.Fatalf("pool = %v, want %v", got, want)
+		}
+	}
+
+	resolver.set("fleet.internal", "10.0.0.1", "10.0.0.2")
+	refresh("http://10.0.0.1:8080", "http://10.0.0.2:8080", "http://10.0.9.1:80")
+
+	resolver.set("fleet.internal", "10.0.0.2", "10.0.0.3")
+	refresh("http://10.0.0.2:8080", "http://10.0.0.3:8080", "http://10.0.9.1:80")
+
+	// A failed lookup keeps what was last resolved.
+	resolver.set("fleet.internal")
+	refresh("http://10.0.0.2:8080", "http://10.0.0.3:8080", "http://10.0.9.1:80")
+
+	// The static backend isn't discovery's to remove, even when the fleet
+	// resolves to nothing it owns.
+	resolver.set("fleet.internal", "10.0.0.4")
+	refresh("http://10.0.0.4:8080", "http://10.0.9.1:80")
+}
+
+func TestDiscoverySRV(t *testing.T) {
+	sp := &ServerPool{drainTimeout: time.Second}
+	resolver := &stubResolver{srv: map[string][]*net.SRV{
+		"_api._tcp.example.internal": {
+			{Target: "a.example.internal.", Port: 9000},
+			{Target: "b.example.internal.", Port: 9001},
+		},
+	}}
+	entries := []config.BackendConfig{{Url: "dns+srv+https://_api._tcp.example.internal", Timeout: time.Second}}
+	d := NewDiscovery(sp, entries, &config.Config{}, resolver)
+	if d.Empty() {
+		t.Fatal("a dns+srv entry was not picked up for discovery")
+	}
+
+	d.Refresh(context.Background())
+	want := []string{"https://a.example.internal:9000", "https://b.example.internal:9001"}
+	if got := poolURLs(sp); !slices.Equal(got, want) {
+		t.Errorf("pool = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,69 @@
+package backend
+
+import "math/rand/v2"
+
+// Eligible reports whether b may be given a new request: it is alive, not
+// draining or ejected, and under its concurrency limit.
+func (b *Backend) Eligible() bool {
+	return b.IsAlive() && !b.Saturated()
+}
+
+// Eligible returns the backends that may be given a new request, in their
+// original order. Balancers choose among these rather than re-checking
+// each condition themselves.
+func Eligible(backends []*Backend) []*Backend {
+	eligible := make([]*Backend, 0, len(backends))
+	for _, b := range backends {
+		if b.Eligible() {
+			eligible = append(eligible, b)
+		}
+	}
+	return eligible
+}
+
+// ActiveTier returns the backends of the highest-priority tier (the lowest
+// Priority) that has an alive backend, in their original order. Backup
+// tiers are reached only once every backend ahead of them is down; when
+// none is alive, backends is returned unchanged.
+func ActiveTier(backends []*Backend) []*Backend {
+	tier, found := uint(0), false
+	for _, b := range backends {
+		if b.IsAlive() && (!found || b.Priority < tier) {
+			tier, found = b.Priority, true
+		}
+	}
+	if !found {
+		return backends
+	}
+	active := make([]*Backend, 0, len(backends))
+	for _, b := range backends {
+		if b.Priority == tier {
+			active = append(active, b)
+		}
+	}
+	return active
+}
+
+// Admitted applies slow-start admission to eligible backends: a backend
+// still ramping up is kept with probability RampWeight. When the draw keeps
+// none, all of backends are returned so a pool that is entirely ramping up
+// still serves. Balancers that weigh backends by RampWeight themselves
+// should use Eligible instead.
+func Admitted(backends []*Backend) []*Backend {
+	admitted := make([]*Backend, 0, len(backends))
+	for _, b := range backends {
+		if w := b.RampWeight(); w < 1 && rand.Float64() >= w {
+			continue
+		}
+		admitted = append(admitted, b)
+	}
+	if len(admitted) == 0 {
+		return backends
+	}
+	return admitted
+}
+
+// EligibleBackends returns a snapshot of the pool's eligible backends.
+func (sp *ServerPool) EligibleBackends() []*Backend {
+	return Eligible(sp.Snapshot())
+}
@@ -0,0 +1,98 @@
+package backend
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEligible(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		setup func(b *Backend)
+		want  bool
+	}{
+		{"alive", func(b *Backend) {}, true},
+		{"down", func(b *Backend) { b.SetAlive(false) }, false},
+		{"draining", func(b *Backend) { b.Drain() }, false},
+		{"ejected", func(b *Backend) {
+			b.Outlier = &OutlierPolicy{ConsecutiveErrors: 1, BaseEjectionTime: time.Hour}
+			b.recordOutlier(OutcomeError)
+		}, false},
+		{"saturated", func(b *Backend) {
+			b.MaxConcurrent = 2
+			b.IncrementConnections()
+			b.IncrementConnections()
+		}, false},
+		{"under its limit", func(b *Backend) {
+			b.MaxConcurrent = 2
+			b.IncrementConnections()
+		}, true},
+	} {
+		b := mustBackend(t, "http://10.0.0.1:80")
+		tc.setup(b)
+		if got := b.Eligible(); got != tc.want {
+			t.Errorf("%s: Eligible = %v, want %v", tc.name, got, tc.want)
+		}
+		if got := len(Eligible([]*Backend{b})) == 1; got != tc.want {
+			t.Errorf("%s: kept by Eligible = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestAdmitted(t *testing.T) {
+	full := mustBackend(t, "http://10.0.0.1:80")
+	ramping := mustBackend(t, "http://10.0.0.2:80")
+	ramping.SlowStart = time.Hour
+	ramping.SetAlive(false)
+	ramping.SetAlive(true)
+
+	kept := 0
+	for range 1000 {
+		admitted := Admitted([]*Backend{full, ramping})
+		if admitted[0] != full {
+			t.Fatal("a backend outside slow start was not admitted")
+		}
+		if len(admitted) == 2 {
+			kept++
+		}
+	}
+	if kept > 150 {
+		t.Errorf("ramping backend admitted %d of 1000 times, want about 5%%", kept)
+	}
+
+	// A pool that is entirely ramping up still serves.
+	if got := Admitted([]*Backend{ramping}); len(got) != 1 {
+		t.Errorf("Admitted = %v, want the lone ramping backend", got)
+	}
+}
+
+func TestActiveTier(t *testing.T) {
+	primary := mustBackend(t, "http://10.0.0.1:80")
+	backup := mustBackend(t, "http://10.0.0.2:80")
+	backup.Priority = 1
+	last := mustBackend(t, "http://10.0.0.3:80")
+	last.Priority = 2
+	// Tiers are picked by Priority, not by position in the pool.
+	backends := []*Backend{last, backup, primary}
+
+	tier := func() []*Backend { return ActiveTier(backends) }
+	if got := tier(); len(got) != 1 || got[0] != primary {
+		t.Errorf("tier with everything up = %v, want the primary", got)
+	}
+	primary.SetAlive(false)
+	if got := tier(); len(got) != 1 || got[0] != backup {
+		t.Errorf("tier with the primary down = %v, want the backup", got)
+	}
+	backup.SetAlive(false)
+	if got := tier(); len(got) != 1 || got[0] != last {
+		t.Errorf("tier with tiers 0 and 1 down = %v, want tier 2", got)
+	}
+	last.SetAlive(false)
+	if got := tier(); len(got) != 3 {
+		t.Errorf("tier with everything down = %v, want every backend", got)
+	}
+	primary.SetAlive(true)
+	if got := tier(); len(got) != 1 || got[0] != primary {
+		t.Errorf("tier after the primary recovered = %v, want the primary", got)
+	}
+}
@@ -0,0 +1,144 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// grpcHealthPath is the grpc.health.v1.Health/Check method.
+const grpcHealthPath = "/grpc.health.v1.Health/Check"
+
+// grpcServing is HealthCheckResponse.ServingStatus SERVING.
+const grpcServing = 1
+
+// newGRPCClient returns a client that speaks HTTP/2 only: with prior
+// knowledge (h2c) to http backends and over TLS to https ones, as gRPC
+// requires.
+func newGRPCClient(timeout time.Duration) *http.Client {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{Protocols: protocols},
+	}
+}
+
+// probeGRPC calls Health/Check for service on the backend's host:port and
+// reports healthy only on SERVING. An empty service asks about the server
+// as a whole. decorate, if set, adjusts the request before it is sent.
+func probeGRPC(ctx context.Context, client *http.Client, backend *Backend, service string, decorate func(*http.Request)) (bool, error) {
+	target := url.URL{Scheme: backend.URL.Scheme, Host: backend.URL.Host, Path: grpcHealthPath}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(grpcFrame(healthCheckRequest(service))))
+	if err != nil {
+		return false, err
+	}
+	if decorate != nil {
+		decorate(req)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("grpc health check: http status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return false, err
+	}
+
+	// Errors may come back as a trailers-only response, in the headers.
+	status := resp.Trailer.Get("Grpc-Status")
+	if status == "" {
+		status = resp.Header.Get("Grpc-Status")
+	}
+	if status != "0" {
+		return false, fmt.Errorf("grpc health check: grpc-status %s", status)
+	}
+
+	msg, err := grpcMessage(body)
+	if err != nil {
+		return false, err
+	}
+	return servingStatus(msg) == grpcServing, nil
+}
+
+// healthCheckRequest encodes HealthCheckRequest{service}: field 1, length
+// delimited.
+func healthCheckRequest(service string) []byte {
+	if service == "" {
+		return nil
+	}
+	msg := []byte{0x0a}
+	msg = binary.AppendUvarint(msg, uint64(len(service)))
+	return append(msg, service...)
+}
+
+// grpcFrame wraps msg in the uncompressed gRPC length-prefixed framing.
+func grpcFrame(msg []byte) []byte {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
+
+func grpcMessage(body []byte) ([]byte, error) {
+	if len(body) < 5 {
+		return nil, fmt.Errorf("grpc health check: short response")
+	}
+	if body[0] != 0 {
+		return nil, fmt.Errorf("grpc health check: compressed response not supported")
+	}
+	n := binary.BigEndian.Uint32(body[1:5])
+	if uint64(len(body)-5) < uint64(n) {
+		return nil, fmt.Errorf("grpc health check: truncated response")
+	}
+	return body[5 : 5+n], nil
+}
+
+// servingStatus decodes HealthCheckResponse.status (field 1, varint),
+// skipping any other fields. A missing field is UNKNOWN (0).
+func servingStatus(msg []byte) uint64 {
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return 0
+		}
+		msg = msg[n:]
+
+		switch key & 7 {
+		case 0:
+			v, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return 0
+			}
+			if key>>3 == 1 {
+				return v
+			}
+			msg = msg[n:]
+		case 2:
+			l, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < l {
+				return 0
+			}
+			msg = msg[n+int(l):]
+		default:
+			return 0
+		}
+	}
+	return 0
+}
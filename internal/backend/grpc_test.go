@@ -0,0 +1,64 @@
+package backend
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+)
+
+// grpcHealthServer is an in-process grpc.health.v1 server over h2c whose
+// serving status is set through status.
+func grpcHealthServer(t *testing.T, status *atomic.Uint64) *Backend {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.URL.Path != grpcHealthPath || r.Header.Get("Content-Type") != "application/grpc" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if _, err := io.ReadAll(r.Body); err != nil {
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write(grpcFrame([]byte{0x08, byte(status.Load())}))
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return mustBackend(t, srv.URL)
+}
+
+func TestGRPCHealthCheck(t *testing.T) {
+	var status atomic.Uint64
+	status.Store(grpcServing)
+	b := grpcHealthServer(t, &status)
+
+	cfg := testHealthConfig()
+	cfg.Type = config.GRPCHealthCheck
+	cfg.UnhealthyThreshold = 1
+	hc := NewHealthCheck(&ServerPool{Backends: []*Backend{b}}, cfg)
+	defer hc.Stop()
+
+	probeOnce(hc, b)
+	if !b.IsAlive() {
+		t.Fatal("backend reporting SERVING is down")
+	}
+
+	status.Store(2) // NOT_SERVING
+	probeOnce(hc, b)
+	if b.IsAlive() {
+		t.Fatal("backend reporting NOT_SERVING is still up")
+	}
+
+	status.Store(grpcServing)
+	probeOnce(hc, b)
+	if !b.IsAlive() {
+		t.Error("backend did not recover once it reported SERVING again")
+	}
+}
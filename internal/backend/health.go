@@ -1,69 +1,221 @@
 package backend
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"regexp"
 	"sync"
 	"time"
 
 	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/logger"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/metrics"
 )
 
 type HealthCheck struct {
-	ServerPool *ServerPool
-	config     config.HealthCheckConfig
-	stopChan   chan struct{}
-	client     *http.Client
-	ctx        context.Context
-	cancel     context.CancelFunc
-	wg         sync.WaitGroup
+	ServerPool  *ServerPool
+	config      config.HealthCheckConfig
+	bodyPattern *regexp.Regexp
+	stopChan    chan struct{}
+	clients     map[time.Duration]*probeClients
+	clientsMux  sync.Mutex
+	nextProbe   map[*Backend]time.Time
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	startOnce   sync.Once
+	stopOnce    sync.Once
+	stopMux     sync.Mutex
+	stopped     bool
+	sem         chan struct{}
+}
+
+// newProbeClient keeps connections to backends alive between rounds and
+// never follows redirects: a 3xx is itself the probe result, so a backend
+// bouncing /health to a login page isn't judged by the login page.
+func newProbeClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout:   timeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   2,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// probeClients are the clients used for probes with one timeout.
+type probeClients struct {
+	http   *http.Client
+	grpc   *http.Client
+	dialer *net.Dialer
 }
 
 func NewHealthCheck(pool *ServerPool, cfg config.HealthCheckConfig) *HealthCheck {
 	ctx, cancel := context.WithCancel(context.Background())
+	var bodyPattern *regexp.Regexp
+	if cfg.ExpectedBodyRegex != "" {
+		// Validate has already compiled it.
+		bodyPattern, _ = regexp.Compile(cfg.ExpectedBodyRegex)
+	}
 	return &HealthCheck{
-		ServerPool: pool,
-		config:     cfg,
-		client: &http.Client{
-			Timeout: cfg.Timeout,
-		},
-		ctx:    ctx,
-		cancel: cancel,
+		ServerPool:  pool,
+		config:      cfg,
+		bodyPattern: bodyPattern,
+		clients:     make(map[time.Duration]*probeClients),
+		nextProbe:   make(map[*Backend]time.Time),
+		stopChan:    make(chan struct{}),
+		sem:         make(chan struct{}, max(cfg.MaxConcurrent, 1)),
+		ctx:         ctx,
+		cancel:      cancel,
 	}
 }
 
+// Start launches the probe loop. Only the first call has any effect, and a
+// health check that has been stopped cannot be started again.
 func (hc *HealthCheck) Start() {
-	hc.stopChan = make(chan struct{})
-	go hc.run()
+	hc.startOnce.Do(func() {
+		// Held like in CheckNow, so the loop is either tracked before Stop
+		// reaches Wait or never started.
+		hc.stopMux.Lock()
+		defer hc.stopMux.Unlock()
+		if hc.stopped {
+			return
+		}
+
+		// The loop itself is tracked so that Stop never waits on a zero
+		// counter while the loop may still add probe goroutines.
+		hc.wg.Add(1)
+		go hc.run()
+	})
 }
 
 func (hc *HealthCheck) run() {
-	ticker := time.NewTicker(hc.config.Interval)
-	defer ticker.Stop()
+	defer hc.wg.Done()
 
-	hc.checkAll()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
-			hc.checkAll()
+		case <-timer.C:
+			timer.Reset(hc.checkAll())
 
 		case <-hc.stopChan:
-			fmt.Println("Health checker stopped")
+			logger.Info("health checker stopped")
 			return
 		}
 	}
 }
 
-func (hc *HealthCheck) checkAll() {
-	// Fix race condition: Use GetBackends() which returns a safe copy
-	backends := hc.ServerPool.GetBackends()
+// settingsFor returns the health check settings for backend, with its
+// override applied.
+func (hc *HealthCheck) settingsFor(backend *Backend) config.HealthCheckConfig {
+	return backend.HealthCheck.Apply(hc.config)
+}
+
+// clientsFor returns the probe clients for timeout, creating them the first
+// time a backend asks for that timeout.
+func (hc *HealthCheck) clientsFor(timeout time.Duration) *probeClients {
+	hc.clientsMux.Lock()
+	defer hc.clientsMux.Unlock()
 
+	c, ok := hc.clients[timeout]
+	if !ok {
+		c = &probeClients{
+			http:   newProbeClient(timeout),
+			grpc:   newGRPCClient(timeout),
+			dialer: &net.Dialer{Timeout: timeout},
+		}
+		hc.clients[timeout] = c
+	}
+	return c
+}
+
+// checkAll probes every backend that is due, with at most MaxConcurrent
+// probes in flight, and returns how long until the next one is due. New
+// backends are due at once; after that each is probed every interval of its
+// own settings. The whole round finishes before checkAll returns, so a slow
+// round delays the next instead of overlapping with it.
+func (hc *HealthCheck) checkAll() time.Duration {
+	// Snapshot returns a copy, so reloads cannot race with the loop below
+	backends := hc.ServerPool.Snapshot()
+
+	now := time.Now()
+	next := make(map[*Backend]time.Time, len(backends))
+	var round sync.WaitGroup
 	for _, backend := range backends {
+		due, ok := hc.nextProbe[backend]
+		if ok && now.Before(due) {
+			next[backend] = due
+			continue
+		}
+		next[backend] = now.Add(hc.settingsFor(backend).Interval)
+
+		select {
+		case hc.sem <- struct{}{}:
+		case <-hc.ctx.Done():
+			round.Wait()
+			return hc.config.Interval
+		}
+
 		// Track goroutine to prevent leaks
 		hc.wg.Add(1)
-		go hc.check(backend)
+		round.Add(1)
+		go func() {
+			defer func() { <-hc.sem }()
+			defer round.Done()
+			hc.check(backend)
+		}()
+	}
+	round.Wait()
+
+	// Removed backends drop out of the schedule here.
+	hc.nextProbe = next
+
+	wait := hc.config.Interval
+	for _, due := range next {
+		wait = min(wait, time.Until(due))
+	}
+	return max(wait, 0)
+}
+
+// CheckNow probes backends right away, outside the regular schedule, so
+// backends added by a reload can take traffic without waiting for the next
+// round. It returns without waiting for the probes.
+func (hc *HealthCheck) CheckNow(backends []*Backend) {
+	hc.stopMux.Lock()
+	defer hc.stopMux.Unlock()
+	if hc.stopped {
+		return
+	}
+
+	for _, backend := range backends {
+		hc.wg.Add(1)
+		go func() {
+			select {
+			case hc.sem <- struct{}{}:
+			case <-hc.ctx.Done():
+				hc.wg.Done()
+				return
+			}
+			defer func() { <-hc.sem }()
+			hc.check(backend)
+		}()
 	}
 }
 
@@ -71,7 +223,7 @@ func (hc *HealthCheck) check(backend *Backend) {
 	defer hc.wg.Done()
 
 	// Fix goroutine leak: Use context that can be cancelled
-	ctx, cancel := context.WithTimeout(hc.ctx, hc.config.Timeout)
+	ctx, cancel := context.WithTimeout(hc.ctx, hc.settingsFor(backend).Timeout)
 	defer cancel()
 
 	// Check if context was cancelled before starting
@@ -81,43 +233,189 @@ func (hc *HealthCheck) check(backend *Backend) {
 	default:
 	}
 
-	healthURL := backend.URL.String() + "/health"
+	res := hc.Probe(ctx, backend)
 
-	req, err := http.NewRequestWithContext(ctx, "GET", healthURL, nil)
-	if err != nil {
-		backend.UpdateFailureCount(int(hc.config.UnhealthyThreshold))
+	// Don't update failure count if context was cancelled (backend removed)
+	if res.Err != nil && ctx.Err() == context.Canceled {
 		return
 	}
 
-	resp, err := hc.client.Do(req)
+	hc.record(backend, res.Latency, res.Healthy)
+}
+
+// ProbeResult is the outcome of probing one backend once.
+type ProbeResult struct {
+	Backend *Backend
+	Healthy bool
+	Latency time.Duration
+	Err     error
+}
+
+// Probe runs the configured health check against backend once without
+// touching its health state.
+func (hc *HealthCheck) Probe(ctx context.Context, backend *Backend) ProbeResult {
+	start := time.Now()
+	clients := hc.clientsFor(hc.settingsFor(backend).Timeout)
+
+	var healthy bool
+	var err error
+	switch hc.config.Type {
+	case config.TCPHealthCheck:
+		healthy, err = probeTCP(ctx, clients.dialer, backend)
+	case config.GRPCHealthCheck:
+		healthy, err = probeGRPC(ctx, clients.grpc, backend, hc.config.GRPCService, hc.setHeaders)
+	default:
+		healthy, err = hc.probeHTTP(ctx, clients.http, backend)
+	}
+
+	latency := time.Since(start)
+	if healthy && hc.config.MaxLatency > 0 && latency > hc.config.MaxLatency {
+		healthy = false
+		err = fmt.Errorf("probe took %s, over the %s max latency", latency.Round(time.Millisecond), hc.config.MaxLatency)
+	}
+
+	return ProbeResult{Backend: backend, Healthy: healthy, Latency: latency, Err: err}
+}
+
+// ProbeAll probes every backend in the pool once, at most MaxConcurrent at
+// a time, and returns the results in pool order. Like Probe it leaves the
+// backends' health state alone.
+func (hc *HealthCheck) ProbeAll(ctx context.Context) []ProbeResult {
+	backends := hc.ServerPool.Snapshot()
+	results := make([]ProbeResult, len(backends))
+
+	var wg sync.WaitGroup
+	for i, backend := range backends {
+		hc.sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-hc.sem }()
+
+			probeCtx, cancel := context.WithTimeout(ctx, hc.settingsFor(backend).Timeout)
+			defer cancel()
+			results[i] = hc.Probe(probeCtx, backend)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func (hc *HealthCheck) probeHTTP(ctx context.Context, client *http.Client, backend *Backend) (bool, error) {
+	healthURL := backend.URL.String() + cmp.Or(hc.config.Path, "/health")
+
+	req, err := http.NewRequestWithContext(ctx, cmp.Or(hc.config.Method, http.MethodGet), healthURL, nil)
 	if err != nil {
-		// Don't update failure count if context was cancelled (backend removed)
-		if ctx.Err() == context.Canceled {
-			return
+		return false, err
+	}
+	if backend.HostOverride != "" {
+		req.Host = backend.HostOverride
+	}
+	hc.setHeaders(req)
+
+	if backend.Transport().TLSClientConfig != nil {
+		// Probe over the backend's own transport so its CA and SNI
+		// settings apply to the health check too.
+		client = &http.Client{
+			Timeout:       client.Timeout,
+			Transport:     backend.Transport(),
+			CheckRedirect: client.CheckRedirect,
 		}
-		backend.UpdateFailureCount(int(hc.config.UnhealthyThreshold))
-		return
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK {
-		backend.UpdateSuccessCount(int(hc.config.HealthyThreshold))
+	// Reading the body also lets the connection go back to the idle pool.
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+
+	if !hc.config.AcceptsStatus(resp.StatusCode) {
+		return false, nil
+	}
+	if hc.config.ExpectedBody != "" && !bytes.Contains(body, []byte(hc.config.ExpectedBody)) {
+		return false, nil
+	}
+	if hc.bodyPattern != nil && !hc.bodyPattern.Match(body) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// setHeaders applies the configured probe headers and Host override. Host
+// has to go on req.Host; net/http ignores a "Host" entry in the header map.
+func (hc *HealthCheck) setHeaders(req *http.Request) {
+	for k, v := range hc.config.Headers {
+		if http.CanonicalHeaderKey(k) == "Host" {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	if hc.config.Host != "" {
+		req.Host = hc.config.Host
+	}
+}
+
+func probeTCP(ctx context.Context, dialer *net.Dialer, backend *Backend) (bool, error) {
+	conn, err := dialer.DialContext(ctx, "tcp", backend.URL.Host)
+	if err != nil {
+		return false, err
+	}
+	conn.Close()
+
+	return true, nil
+}
+
+func (hc *HealthCheck) record(backend *Backend, duration time.Duration, healthy bool) {
+	wasAlive := backend.IsAlive()
+	backend.RecordProbe(duration, healthy)
+	metrics.IncHealthChecks(backend.URL.String(), healthy)
+	settings := hc.settingsFor(backend)
+	if healthy {
+		backend.UpdateSuccessCount(int(settings.HealthyThreshold))
 	} else {
-		backend.UpdateFailureCount(int(hc.config.UnhealthyThreshold))
+		backend.UpdateFailureCount(int(settings.UnhealthyThreshold))
+	}
+	if backend.IsAlive() != wasAlive {
+		metrics.IncHealthTransitions(backend.URL.String())
 	}
 }
 
+// Stop ends the probe loop and waits for in-flight probes to return. It is
+// safe to call more than once.
 func (hc *HealthCheck) Stop() {
-	// Cancel all health check contexts to stop running goroutines
-	if hc.cancel != nil {
+	hc.stopOnce.Do(func() {
+		// Neither Start nor CheckNow may add to wg once Wait below can be
+		// reached.
+		hc.stopMux.Lock()
+		hc.stopped = true
+		hc.stopMux.Unlock()
+
+		// Cancel all health check contexts to stop running goroutines
 		hc.cancel()
-	}
 
-	// Stop the main health check loop
-	if hc.stopChan != nil {
+		// Stop the main health check loop
 		close(hc.stopChan)
-	}
+	})
 
 	// Wait for all health check goroutines to finish
 	hc.wg.Wait()
+
+	// No probe can run any more, so nothing will reuse the pooled
+	// connections; closing them ends their read and write loops too.
+	hc.clientsMux.Lock()
+	for _, c := range hc.clients {
+		c.http.CloseIdleConnections()
+		c.grpc.CloseIdleConnections()
+	}
+	hc.clientsMux.Unlock()
+}
+
+// Close implements io.Closer by calling Stop.
+func (hc *HealthCheck) Close() error {
+	hc.Stop()
+	return nil
 }
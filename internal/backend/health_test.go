@@ -0,0 +1,411 @@
+package backend
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+)
+
+func testHealthConfig() config.HealthCheckConfig {
+	return config.HealthCheckConfig{
+		Interval:           time.Hour,
+		Timeout:            time.Second,
+		UnhealthyThreshold: 2,
+		HealthyThreshold:   1,
+		MaxConcurrent:      4,
+	}
+}
+
+// probeOnce runs one scheduled probe of b and records its outcome.
+func probeOnce(hc *HealthCheck, b *Backend) {
+	hc.wg.Add(1)
+	hc.check(b)
+}
+
+func TestProbeMetrics(t *testing.T) {
+	fast := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	slow := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	failing := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	hc := NewHealthCheck(&ServerPool{Backends: []*Backend{fast, slow, failing}}, testHealthConfig())
+	defer hc.Stop()
+
+	for range 3 {
+		probeOnce(hc, fast)
+		probeOnce(hc, slow)
+		probeOnce(hc, failing)
+	}
+
+	f, s, x := fast.ProbeMetrics(), slow.ProbeMetrics(), failing.ProbeMetrics()
+	if s.LastDuration < 50*time.Millisecond || s.LastDuration <= f.LastDuration {
+		t.Errorf("last probe took %v slow vs %v fast", s.LastDuration, f.LastDuration)
+	}
+	if s.AverageDuration() <= f.AverageDuration() {
+		t.Errorf("average probe took %v slow vs %v fast", s.AverageDuration(), f.AverageDuration())
+	}
+	if f.Successes != 3 || f.Failures != 0 || f.ConsecutiveFailures != 0 {
+		t.Errorf("fast backend metrics = %+v", f)
+	}
+	if x.Successes != 0 || x.Failures != 3 || x.ConsecutiveFailures != 3 {
+		t.Errorf("failing backend metrics = %+v", x)
+	}
+	if failing.IsAlive() {
+		t.Error("failing backend is still alive")
+	}
+}
+
+func TestConsecutiveFailuresReset(t *testing.T) {
+	var down bool
+	b := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	hc := NewHealthCheck(&ServerPool{Backends: []*Backend{b}}, testHealthConfig())
+	defer hc.Stop()
+
+	down = true
+	probeOnce(hc, b)
+	probeOnce(hc, b)
+	if got := b.ProbeMetrics().ConsecutiveFailures; got != 2 {
+		t.Fatalf("consecutive failures = %d, want 2", got)
+	}
+	down = false
+	probeOnce(hc, b)
+	if m := b.ProbeMetrics(); m.ConsecutiveFailures != 0 || m.Failures != 2 || m.Successes != 1 {
+		t.Errorf("metrics after recovering = %+v", m)
+	}
+}
+
+func TestMaxLatency(t *testing.T) {
+	fast := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// Slower than MaxLatency but well within Timeout, so the probe gets its
+	// 200 and fails only on the latency limit.
+	slow := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	cfg := testHealthConfig()
+	cfg.MaxLatency = 50 * time.Millisecond
+	hc := NewHealthCheck(&ServerPool{Backends: []*Backend{fast, slow}}, cfg)
+	defer hc.Stop()
+
+	for range 2 {
+		probeOnce(hc, fast)
+		probeOnce(hc, slow)
+	}
+	if !fast.IsAlive() {
+		t.Error("fast backend was marked down")
+	}
+	if slow.IsAlive() {
+		t.Error("backend answering 200 past the max latency is still alive")
+	}
+	if m := slow.ProbeMetrics(); m.Failures != 2 || m.Successes != 0 {
+		t.Errorf("slow backend metrics = %+v, want 2 failures", m)
+	}
+}
+
+func TestPerBackendThresholds(t *testing.T) {
+	failing := func() *Backend {
+		return newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+	}
+	strict, tolerant := failing(), failing()
+	tolerant.HealthCheck = &config.HealthCheckOverride{UnhealthyThreshold: 4}
+	hc := NewHealthCheck(&ServerPool{Backends: []*Backend{strict, tolerant}}, testHealthConfig())
+	defer hc.Stop()
+
+	for failures := 1; failures <= 4; failures++ {
+		probeOnce(hc, strict)
+		probeOnce(hc, tolerant)
+		if want := failures < 2; strict.IsAlive() != want {
+			t.Errorf("after %d failures: strict backend alive %v, want %v", failures, strict.IsAlive(), want)
+		}
+		if want := failures < 4; tolerant.IsAlive() != want {
+			t.Errorf("after %d failures: tolerant backend alive %v, want %v", failures, tolerant.IsAlive(), want)
+		}
+	}
+}
+
+func TestTCPHealthCheck(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	b := mustBackend(t, "tcp://"+addr)
+	b.SetAlive(false)
+
+	cfg := testHealthConfig()
+	cfg.Type = config.TCPHealthCheck
+	cfg.UnhealthyThreshold = 1
+	hc := NewHealthCheck(&ServerPool{Backends: []*Backend{b}}, cfg)
+	defer hc.Stop()
+
+	probeOnce(hc, b)
+	if !b.IsAlive() {
+		t.Fatal("backend is down with its listener open")
+	}
+
+	ln.Close()
+	probeOnce(hc, b)
+	if b.IsAlive() {
+		t.Fatal("backend is still up with its listener closed")
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("could not reopen %s: %v", addr, err)
+	}
+	defer ln.Close()
+	probeOnce(hc, b)
+	if !b.IsAlive() {
+		t.Error("backend did not recover once its listener reopened")
+	}
+}
+
+// deadBackends returns n backends whose addresses refuse connections, so
+// probes fail fast and leave no connections behind.
+func deadBackends(t *testing.T, n int) []*Backend {
+	t.Helper()
+	backends := make([]*Backend, n)
+	for i := range backends {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		srv.Close()
+		backends[i] = mustBackend(t, srv.URL)
+	}
+	return backends
+}
+
+// waitGoroutines waits for the goroutine count to fall back to at most n.
+func waitGoroutines(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines still running, want at most %d", runtime.NumGoroutine(), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHealthCheckLifecycle(t *testing.T) {
+	backends := deadBackends(t, 3)
+	before := runtime.NumGoroutine()
+
+	cfg := testHealthConfig()
+	cfg.Interval = 10 * time.Millisecond
+	hc := NewHealthCheck(&ServerPool{Backends: backends}, cfg)
+	hc.Start()
+	hc.Start()
+	time.Sleep(50 * time.Millisecond)
+	hc.Stop()
+	hc.Stop()
+	waitGoroutines(t, before)
+
+	// A stopped health check stays stopped.
+	hc.Start()
+	hc.CheckNow(backends)
+	waitGoroutines(t, before)
+}
+
+func TestHealthCheckConcurrentLifecycle(t *testing.T) {
+	backends := deadBackends(t, 2)
+	before := runtime.NumGoroutine()
+
+	for range 50 {
+		hc := NewHealthCheck(&ServerPool{Backends: backends}, testHealthConfig())
+		var wg sync.WaitGroup
+		for _, f := range []func(){hc.Start, hc.Stop, func() { hc.CheckNow(backends) }, hc.Start, hc.Stop} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				f()
+			}()
+		}
+		wg.Wait()
+		hc.Stop()
+	}
+	waitGoroutines(t, before)
+}
+
+func TestStopCancelsCheckNow(t *testing.T) {
+	var probes atomic.Int32
+	b := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		<-r.Context().Done()
+	}))
+	cfg := testHealthConfig()
+	cfg.Timeout = time.Minute
+	hc := NewHealthCheck(&ServerPool{Backends: []*Backend{b}}, cfg)
+
+	hc.CheckNow([]*Backend{b, b, b})
+	deadline := time.Now().Add(2 * time.Second)
+	for probes.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	start := time.Now()
+	hc.Stop()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Stop waited %v for blocked probes", elapsed)
+	}
+	if !b.IsAlive() {
+		t.Error("probes cut short by Stop were counted as failures")
+	}
+
+	hc.CheckNow([]*Backend{b})
+	time.Sleep(20 * time.Millisecond)
+	if n := probes.Load(); n != 3 {
+		t.Errorf("%d probes ran, want CheckNow after Stop to add none", n)
+	}
+}
+
+func TestProbeConcurrencyLimit(t *testing.T) {
+	var inFlight, peak, probes atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		probes.Add(1)
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	backends := make([]*Backend, 20)
+	for i := range backends {
+		backends[i] = mustBackend(t, srv.URL)
+	}
+	cfg := testHealthConfig()
+	cfg.MaxConcurrent = 4
+	hc := NewHealthCheck(&ServerPool{Backends: backends}, cfg)
+	defer hc.Stop()
+
+	hc.checkAll()
+	if n := probes.Load(); n != 20 {
+		t.Errorf("round returned after %d of 20 probes", n)
+	}
+	if p := peak.Load(); p > 4 {
+		t.Errorf("%d probes ran at once, over the limit of 4", p)
+	} else if p < 2 {
+		t.Errorf("at most %d probe ran at once; probes were not concurrent", p)
+	}
+}
+
+func TestProbeHeaders(t *testing.T) {
+	b := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "health.internal" || r.Header.Get("Authorization") != "Bearer probe" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+
+	for _, tc := range []struct {
+		name    string
+		headers map[string]string
+		host    string
+		want    bool
+	}{
+		{"none", nil, "", false},
+		{"header only", map[string]string{"Authorization": "Bearer probe"}, "", false},
+		{"host only", nil, "health.internal", false},
+		{"header and host", map[string]string{"Authorization": "Bearer probe"}, "health.internal", true},
+	} {
+		b.SetAlive(true)
+		cfg := testHealthConfig()
+		cfg.UnhealthyThreshold = 1
+		cfg.Headers = tc.headers
+		cfg.Host = tc.host
+		hc := NewHealthCheck(&ServerPool{Backends: []*Backend{b}}, cfg)
+		probeOnce(hc, b)
+		hc.Stop()
+		if b.IsAlive() != tc.want {
+			t.Errorf("%s: alive = %v, want %v", tc.name, b.IsAlive(), tc.want)
+		}
+	}
+}
+
+func TestRedirectIsNotFollowed(t *testing.T) {
+	var followed atomic.Bool
+	b := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			followed.Store(true)
+			return
+		}
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}))
+
+	for _, tc := range []struct {
+		name     string
+		expected []int
+		want     bool
+	}{
+		{"default statuses", nil, false},
+		{"302 expected", []int{http.StatusFound}, true},
+	} {
+		b.SetAlive(true)
+		cfg := testHealthConfig()
+		cfg.UnhealthyThreshold = 1
+		cfg.ExpectedStatus = tc.expected
+		hc := NewHealthCheck(&ServerPool{Backends: []*Backend{b}}, cfg)
+		probeOnce(hc, b)
+		hc.Stop()
+		if b.IsAlive() != tc.want {
+			t.Errorf("%s: alive = %v, want %v", tc.name, b.IsAlive(), tc.want)
+		}
+	}
+	if followed.Load() {
+		t.Error("probe followed the redirect to /login")
+	}
+}
+
+func TestProbeAll(t *testing.T) {
+	healthy := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	failing := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	dead := deadBackends(t, 1)[0]
+	backends := []*Backend{healthy, failing, dead}
+	hc := NewHealthCheck(&ServerPool{Backends: backends}, testHealthConfig())
+	defer hc.Stop()
+
+	results := hc.ProbeAll(context.Background())
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	for i, want := range []bool{true, false, false} {
+		r := results[i]
+		if r.Backend != backends[i] {
+			t.Errorf("result %d is for %s, want pool order", i, r.Backend.URL)
+		}
+		if r.Healthy != want {
+			t.Errorf("%s: healthy = %v, want %v", r.Backend.URL, r.Healthy, want)
+		}
+		if r.Latency <= 0 {
+			t.Errorf("%s: no latency recorded", r.Backend.URL)
+		}
+	}
+	if results[2].Err == nil {
+		t.Error("unreachable backend reported no error")
+	}
+	for _, b := range backends {
+		if !b.IsAlive() {
+			t.Errorf("%s: probing changed its health state", b.URL)
+		}
+	}
+}
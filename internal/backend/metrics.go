@@ -0,0 +1,48 @@
+package backend
+
+import (
+	"sync"
+	"time"
+)
+
+type ProbeMetrics struct {
+	LastDuration        time.Duration
+	TotalDuration       time.Duration
+	Successes           uint64
+	Failures            uint64
+	ConsecutiveFailures uint64
+}
+
+type probeStats struct {
+	mux     sync.RWMutex
+	metrics ProbeMetrics
+}
+
+func (ps *probeStats) record(duration time.Duration, success bool) {
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+
+	ps.metrics.LastDuration = duration
+	ps.metrics.TotalDuration += duration
+	if success {
+		ps.metrics.Successes++
+		ps.metrics.ConsecutiveFailures = 0
+		return
+	}
+	ps.metrics.Failures++
+	ps.metrics.ConsecutiveFailures++
+}
+
+func (ps *probeStats) snapshot() ProbeMetrics {
+	ps.mux.RLock()
+	defer ps.mux.RUnlock()
+	return ps.metrics
+}
+
+func (m ProbeMetrics) AverageDuration() time.Duration {
+	total := m.Successes + m.Failures
+	if total == 0 {
+		return 0
+	}
+	return m.TotalDuration / time.Duration(total)
+}
@@ -0,0 +1,97 @@
+package backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/logger"
+)
+
+// OutlierPolicy ejects a backend that keeps failing live traffic for a
+// while, independently of the health check thresholds.
+type OutlierPolicy struct {
+	// ConsecutiveErrors within Window trigger an ejection.
+	ConsecutiveErrors int
+	Window            time.Duration
+	// BaseEjectionTime is the length of the first ejection; each ejection
+	// after that lasts one BaseEjectionTime longer, up to MaxMultiplier.
+	BaseEjectionTime time.Duration
+	MaxMultiplier    int
+	// NonConsecutive counts every error within Window towards
+	// ConsecutiveErrors instead of starting over on each success.
+	NonConsecutive bool
+}
+
+type outlierState struct {
+	mux          sync.Mutex
+	errors       int
+	firstError   time.Time
+	ejections    int
+	ejectedUntil time.Time
+}
+
+// recordError counts a failed response and reports the end of the new
+// ejection, if this error triggered one.
+func (s *outlierState) recordError(p *OutlierPolicy, now time.Time) (time.Time, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if now.Before(s.ejectedUntil) {
+		return time.Time{}, false
+	}
+
+	if s.errors == 0 || (p.Window > 0 && now.Sub(s.firstError) > p.Window) {
+		s.errors = 0
+		s.firstError = now
+	}
+	s.errors++
+	if s.errors < p.ConsecutiveErrors {
+		return time.Time{}, false
+	}
+
+	// A backend that stayed in for as long as the longest ejection has
+	// earned its multiplier back.
+	maxMultiplier := max(p.MaxMultiplier, 1)
+	if !s.ejectedUntil.IsZero() && now.Sub(s.ejectedUntil) > p.BaseEjectionTime*time.Duration(maxMultiplier) {
+		s.ejections = 0
+	}
+	s.ejections = min(s.ejections+1, maxMultiplier)
+	s.errors = 0
+	s.ejectedUntil = now.Add(p.BaseEjectionTime * time.Duration(s.ejections))
+	return s.ejectedUntil, true
+}
+
+func (s *outlierState) recordSuccess() {
+	s.mux.Lock()
+	s.errors = 0
+	s.mux.Unlock()
+}
+
+func (s *outlierState) ejected(now time.Time) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return now.Before(s.ejectedUntil)
+}
+
+func (b *Backend) recordOutlier(o Outcome) {
+	if b.Outlier == nil || b.Outlier.ConsecutiveErrors <= 0 {
+		return
+	}
+
+	switch o {
+	case OutcomeError:
+		if until, ok := b.outliers.recordError(b.Outlier, time.Now()); ok {
+			logger.Warn("backend ejected as outlier", "backend", b.URL.String(), "until", until)
+		}
+	case OutcomeSuccess:
+		if !b.Outlier.NonConsecutive {
+			b.outliers.recordSuccess()
+		}
+	}
+}
+
+// Ejected reports whether outlier detection currently holds the backend out
+// of selection. It is re-admitted automatically once the ejection expires.
+func (b *Backend) Ejected() bool {
+	return b.Outlier != nil && b.outliers.ejected(time.Now())
+}
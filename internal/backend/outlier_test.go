@@ -0,0 +1,59 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOutlierEjection(t *testing.T) {
+	b := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	b.Outlier = &OutlierPolicy{ConsecutiveErrors: 2, BaseEjectionTime: 100 * time.Millisecond, MaxMultiplier: 3}
+
+	// POSTs aren't retried, so each request is one error.
+	serve := func() {
+		b.Serve(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil), false)
+	}
+	serve()
+	if b.Ejected() {
+		t.Fatal("ejected after one error, want 2")
+	}
+	serve()
+	if !b.Ejected() || b.Eligible() {
+		t.Fatal("backend is still selectable after 2 consecutive errors")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if b.Ejected() || !b.IsAlive() {
+		t.Error("backend was not re-admitted once its ejection expired")
+	}
+}
+
+func TestOutlierEjectionGrows(t *testing.T) {
+	p := &OutlierPolicy{ConsecutiveErrors: 1, BaseEjectionTime: time.Minute, MaxMultiplier: 2}
+	var s outlierState
+	now := time.Now()
+
+	for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 2 * time.Minute} {
+		until, ok := s.recordError(p, now)
+		if !ok {
+			t.Fatalf("error %d did not eject", i+1)
+		}
+		if got := until.Sub(now); got != want {
+			t.Errorf("ejection %d lasts %v, want %v", i+1, got, want)
+		}
+		if _, ok := s.recordError(p, now.Add(time.Second)); ok {
+			t.Errorf("ejection %d was extended while it was in force", i+1)
+		}
+		now = until
+	}
+
+	// Staying in for longer than the longest ejection resets the multiplier.
+	now = now.Add(3 * time.Minute)
+	if until, _ := s.recordError(p, now); until.Sub(now) != time.Minute {
+		t.Errorf("ejection after a clean spell lasts %v, want the base minute", until.Sub(now))
+	}
+}
@@ -1,29 +1,171 @@
 package backend
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"net/url"
+	"os"
 	"slices"
 	"sync"
 	"time"
 
 	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/logger"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
 )
 
 type ServerPool struct {
 	Backends           []*Backend
 	mux                sync.RWMutex
 	unhealthyThreshold int
+	drainTimeout       time.Duration
 }
 
-func NewServerPool(cb *config.Config) *ServerPool {
+func NewServerPool(cb *config.Config) (*ServerPool, error) {
+	return NewServerPoolFor(cb.Backends, cb)
+}
+
+// NewServerPoolFor builds a pool from an explicit backend list, such as one
+// of the config's backend groups, using cb for the shared settings.
+func NewServerPoolFor(bcs []config.BackendConfig, cb *config.Config) (*ServerPool, error) {
 	var backends []*Backend
 
-	for _, b := range cb.Backends {
-		backendUrl, _ := url.Parse(b.Url)
-		backends = append(backends, NewBackend(backendUrl, int(cb.LoadBalancing.HealthCheck.UnhealthyThreshold), b.Timeout))
+	for _, b := range bcs {
+		// Discovery adds these once they resolve.
+		if b.Discovered() {
+			continue
+		}
+		be, err := NewBackendFromConfig(b, cb)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, be)
+	}
+
+	return &ServerPool{
+		Backends:           backends,
+		unhealthyThreshold: int(cb.LoadBalancing.HealthCheck.UnhealthyThreshold),
+		drainTimeout:       cb.LoadBalancing.DrainTimeout,
+	}, nil
+}
+
+func NewBackendFromConfig(bc config.BackendConfig, cb *config.Config) (*Backend, error) {
+	backendUrl, err := url.Parse(bc.Url)
+	if err != nil {
+		return nil, fmt.Errorf("backend %s: invalid URL: %w", bc.Url, err)
+	}
+	if backendUrl.Scheme == "" || backendUrl.Host == "" {
+		return nil, fmt.Errorf("backend %s: URL must include scheme and host", bc.Url)
+	}
+
+	hc := bc.HealthCheck.Apply(cb.LoadBalancing.HealthCheck)
+	b := NewBackend(backendUrl, int(hc.UnhealthyThreshold), bc.Timeout)
+	b.SetTransportLimits(cb.Proxy.Upstream)
+	b.SetProtocol(bc.Protocol)
+	if backendUrl.Scheme == "https" {
+		tc := cb.Proxy.UpstreamTLS
+		if bc.TLS != nil {
+			tc = *bc.TLS
+		}
+		tlsConfig, err := upstreamTLS(tc)
+		if err != nil {
+			return nil, fmt.Errorf("backend %s: %w", bc.Url, err)
+		}
+		b.SetTLSConfig(tlsConfig)
+	}
+	b.ForwardedHeaders = cb.Proxy.ForwardedHeaders
+	b.PreserveHost = bc.PreserveHost
+	b.HostOverride = bc.Host
+	b.MaxConcurrent = int64(bc.MaxConcurrent)
+	b.SetWeight(bc.EffectiveWeight())
+	b.Priority = bc.Priority
+	b.SuccessThreshold = int(hc.HealthyThreshold)
+	b.HealthCheck = bc.HealthCheck
+	b.SlowStart = cb.LoadBalancing.SlowStart
+	if od := cb.LoadBalancing.OutlierDetection; od.Enabled {
+		b.Outlier = &OutlierPolicy{
+			ConsecutiveErrors: od.ConsecutiveErrors,
+			Window:            od.Window,
+			BaseEjectionTime:  od.BaseEjectionTime,
+			MaxMultiplier:     od.MaxEjectionMultiplier,
+		}
+	}
+	if ph := cb.LoadBalancing.PassiveHealthCheck; ph.Enabled {
+		b.Outlier = &OutlierPolicy{
+			ConsecutiveErrors: ph.FailureThreshold,
+			Window:            ph.Window,
+			BaseEjectionTime:  ph.EjectionDuration,
+			MaxMultiplier:     1,
+			NonConsecutive:    true,
+		}
+	}
+	if rb := cb.Proxy.RetryBackoff; rb.Base > 0 {
+		b.Backoff = Backoff{Base: rb.Base, Max: rb.Max, Jitter: rb.Jitter}
+	}
+	if page := cb.Proxy.ErrorPage; page.Body != "" {
+		b.ErrorPage = &util.ErrorPage{Body: page.Body, ContentType: page.ContentType}
+	}
+	return b, nil
+}
+
+// upstreamTLS builds the client TLS settings for an https backend.
+func upstreamTLS(tc config.UpstreamTLSConfig) (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         tc.ServerName,
+		InsecureSkipVerify: tc.InsecureSkipVerify,
+	}
+	if tc.CAFile == "" {
+		return cfg, nil
+	}
+
+	pem, err := os.ReadFile(tc.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading ca_file: %w", err)
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("ca_file %s: no certificates found", tc.CAFile)
 	}
+	cfg.RootCAs = roots
+	return cfg, nil
+}
 
-	return &ServerPool{Backends: backends, unhealthyThreshold: int(cb.LoadBalancing.HealthCheck.UnhealthyThreshold)}
+// BuildBackends constructs a backend for every URL in urls from its entry in
+// cb. Nothing is returned unless all of them could be built, so a caller can
+// apply the result without risk of a half-applied reload.
+func BuildBackends(urls []string, cb *config.Config) ([]*Backend, error) {
+	return BuildBackendsFrom(urls, cb.Backends, cb)
+}
+
+// BuildBackendsFrom is BuildBackends for URLs listed in bcs, such as a
+// backend group, rather than in the top-level backends.
+func BuildBackendsFrom(urls []string, bcs []config.BackendConfig, cb *config.Config) ([]*Backend, error) {
+	var backends []*Backend
+
+	for _, u := range urls {
+		idx := slices.IndexFunc(bcs, func(bc config.BackendConfig) bool {
+			return bc.Url == u
+		})
+		if idx == -1 {
+			return nil, fmt.Errorf("backend %s: not found in config", u)
+		}
+		if bcs[idx].Discovered() {
+			logger.Warn("discovered backend entries are only picked up on restart", "backend", u)
+			continue
+		}
+
+		b, err := NewBackendFromConfig(bcs[idx], cb)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, b)
+	}
+
+	return backends, nil
 }
 
 func (sp *ServerPool) AddBackends(b []*Backend) {
@@ -33,50 +175,123 @@ func (sp *ServerPool) AddBackends(b []*Backend) {
 	sp.Backends = append(sp.Backends, b...)
 }
 
-func (sp *ServerPool) RemoveBackends(urls []string) {
+// ApplyChange adds the given backends and takes the removed ones out of the
+// pool in a single step, so selection never observes a partial reload. The
+// removed backends then drain and are closed in the background, as in
+// RemoveBackends, so the reload doesn't wait for them.
+func (sp *ServerPool) ApplyChange(added []*Backend, removed []string) {
 	sp.mux.Lock()
-	defer sp.mux.Unlock()
+	remaining := make([]*Backend, 0, len(sp.Backends)+len(added))
+	var targets []*Backend
+	for _, b := range sp.Backends {
+		if slices.Contains(removed, b.URL.String()) {
+			b.Drain()
+			targets = append(targets, b)
+			continue
+		}
+		remaining = append(remaining, b)
+	}
+	sp.Backends = append(remaining, added...)
+	sp.mux.Unlock()
 
-	var targetsToRemove []string
-	var maxTimeout time.Duration
+	if len(targets) > 0 {
+		go sp.closeDrained(targets)
+	}
+}
 
-	for _, target := range urls {
-		index := slices.IndexFunc(sp.Backends, func(existing *Backend) bool {
-			return existing.URL.String() == target
-		})
-		if index == -1 {
-			continue
+// SetWeights applies reloaded weights, keyed by backend URL, to the
+// backends already in the pool.
+func (sp *ServerPool) SetWeights(weights map[string]int) {
+	sp.mux.RLock()
+	defer sp.mux.RUnlock()
+	for _, b := range sp.Backends {
+		if w, ok := weights[b.URL.String()]; ok {
+			b.SetWeight(w)
 		}
-		sp.Backends[index].SetAlive(false)
+	}
+}
 
-		targetsToRemove = append(targetsToRemove, sp.Backends[index].URL.String())
-		if sp.Backends[index].Timeout > maxTimeout {
-			maxTimeout = sp.Backends[index].Timeout
+// RemoveBackends drains the matching backends, waits for their in-flight
+// requests to finish or for the drain timeout to pass, and only then drops
+// them from the pool. Requests still running at the timeout are aborted
+// and fail over like any other backend error.
+func (sp *ServerPool) RemoveBackends(urls []string) {
+	sp.mux.RLock()
+	var targets []*Backend
+	for _, b := range sp.Backends {
+		if slices.Contains(urls, b.URL.String()) {
+			b.Drain()
+			targets = append(targets, b)
 		}
 	}
+	sp.mux.RUnlock()
 
-	if len(targetsToRemove) == 0 {
+	if len(targets) == 0 {
 		return
 	}
+	sp.closeDrained(targets)
 
-	sp.mux.Unlock()
-	time.Sleep(maxTimeout)
 	sp.mux.Lock()
+	defer sp.mux.Unlock()
 
-	for _, t := range targetsToRemove {
-		idx := slices.IndexFunc(sp.Backends, func(existing *Backend) bool {
-			return existing.URL.String() == t
-		})
-		if idx != -1 {
-			sp.Backends = append(sp.Backends[:idx], sp.Backends[idx+1:]...)
+	// Build a fresh slice so that copies handed out by Snapshot are never
+	// rewritten underneath a request.
+	remaining := make([]*Backend, 0, len(sp.Backends))
+	for _, b := range sp.Backends {
+		if !slices.Contains(targets, b) {
+			remaining = append(remaining, b)
 		}
 	}
+	sp.Backends = remaining
 }
 
+// closeDrained waits for the in-flight requests of the draining targets to
+// finish, or for the drain timeout to pass, and then closes them. Without a
+// drain timeout the longest backend timeout is used.
+func (sp *ServerPool) closeDrained(targets []*Backend) {
+	drainTimeout := sp.drainTimeout
+	if drainTimeout <= 0 {
+		for _, b := range targets {
+			drainTimeout = max(drainTimeout, b.Timeout)
+		}
+	}
+	deadline := time.Now().Add(drainTimeout)
+	for _, b := range targets {
+		if !b.WaitDrained(time.Until(deadline)) {
+			logger.Warn("backend drain timed out, aborting its requests", "backend", b.URL.String(), "active", b.ActiveConnections())
+		}
+		b.Close()
+	}
+}
+
+// Snapshot returns a copy of the pool's backends taken under the read lock.
+// Callers may filter or reorder it freely; reloads never modify it.
+func (sp *ServerPool) Snapshot() []*Backend {
+	sp.mux.RLock()
+	defer sp.mux.RUnlock()
+	return slices.Clone(sp.Backends)
+}
+
+// GetBackends is Snapshot under its older name.
 func (sp *ServerPool) GetBackends() []*Backend {
+	return sp.Snapshot()
+}
+
+func (sp *ServerPool) AliveCount() int {
+	sp.mux.RLock()
+	defer sp.mux.RUnlock()
+
+	alive := 0
+	for _, b := range sp.Backends {
+		if b.IsAlive() {
+			alive++
+		}
+	}
+	return alive
+}
+
+func (sp *ServerPool) Len() int {
 	sp.mux.RLock()
 	defer sp.mux.RUnlock()
-	copySlice := make([]*Backend, len(sp.Backends))
-	copy(copySlice, sp.Backends)
-	return copySlice
+	return len(sp.Backends)
 }
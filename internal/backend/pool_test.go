@@ -0,0 +1,223 @@
+package backend
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+)
+
+func mustBackend(t *testing.T, raw string) *Backend {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBackend(u, 3, time.Second)
+	b.SetAlive(true)
+	return b
+}
+
+func TestApplyChangeDoesNotWaitForDrain(t *testing.T) {
+	old := mustBackend(t, "http://10.0.0.1:80")
+	added := mustBackend(t, "http://10.0.0.2:80")
+	sp := &ServerPool{Backends: []*Backend{old}, drainTimeout: 5 * time.Second}
+
+	old.IncrementConnections()
+	ctx, unbind := old.Bind(context.Background())
+	defer unbind()
+
+	start := time.Now()
+	sp.ApplyChange([]*Backend{added}, []string{old.URL.String()})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("ApplyChange took %v with a request still in flight", elapsed)
+	}
+
+	if got := sp.Snapshot(); len(got) != 1 || got[0] != added {
+		t.Fatalf("pool = %v, want only the added backend", got)
+	}
+	if !old.IsDraining() {
+		t.Error("removed backend is not draining")
+	}
+	select {
+	case <-ctx.Done():
+		t.Fatal("removed backend closed before its request finished")
+	default:
+	}
+
+	old.DecrementConnections()
+	select {
+	case <-ctx.Done():
+		if context.Cause(ctx) != ErrBackendRemoved {
+			t.Errorf("cause = %v, want ErrBackendRemoved", context.Cause(ctx))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("removed backend was not closed once drained")
+	}
+}
+
+func TestRemoveBackendsAbortsAtDrainTimeout(t *testing.T) {
+	b := mustBackend(t, "http://10.0.0.1:80")
+	sp := &ServerPool{Backends: []*Backend{b}, drainTimeout: 100 * time.Millisecond}
+
+	b.IncrementConnections()
+	defer b.DecrementConnections()
+	ctx, unbind := b.Bind(context.Background())
+	defer unbind()
+
+	sp.RemoveBackends([]string{b.URL.String()})
+	if sp.Len() != 0 {
+		t.Errorf("pool still holds %d backends", sp.Len())
+	}
+	select {
+	case <-ctx.Done():
+		if context.Cause(ctx) != ErrBackendRemoved {
+			t.Errorf("cause = %v, want ErrBackendRemoved", context.Cause(ctx))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("in-flight request was not aborted")
+	}
+}
+
+func TestRemoveBackendsWaitsForDrain(t *testing.T) {
+	b := mustBackend(t, "http://10.0.0.1:80")
+	sp := &ServerPool{Backends: []*Backend{b}, drainTimeout: 5 * time.Second}
+
+	b.IncrementConnections()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		b.DecrementConnections()
+	}()
+
+	start := time.Now()
+	sp.RemoveBackends([]string{b.URL.String()})
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("RemoveBackends returned after %v, want it to wait for the request", elapsed)
+	}
+	if b.ActiveConnections() != 0 {
+		t.Errorf("active connections = %d, want 0", b.ActiveConnections())
+	}
+}
+
+func TestPoolWeightsFromConfig(t *testing.T) {
+	cfg, err := config.Parse([]byte(`
+backends:
+  - url: http://10.0.0.1:80
+    timeout: 5s
+    weight: 3
+  - url: http://10.0.0.2:80
+    timeout: 5s
+  - url: http://10.0.0.3:80
+    timeout: 5s
+    weight: 0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp, err := NewServerPool(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int{"http://10.0.0.1:80": 3, "http://10.0.0.2:80": 1, "http://10.0.0.3:80": 0}
+	for _, b := range sp.Snapshot() {
+		if b.Weight() != want[b.URL.String()] {
+			t.Errorf("%s: weight %d, want %d", b.URL, b.Weight(), want[b.URL.String()])
+		}
+	}
+}
+
+func TestUpstreamTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, pemBytes, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		tls  *config.UpstreamTLSConfig
+		want int
+	}{
+		{"no ca", nil, http.StatusBadGateway},
+		{"ca configured", &config.UpstreamTLSConfig{CAFile: caFile}, http.StatusOK},
+		{"wrong server name", &config.UpstreamTLSConfig{CAFile: caFile, ServerName: "wrong.example"}, http.StatusBadGateway},
+		{"matching server name", &config.UpstreamTLSConfig{CAFile: caFile, ServerName: "example.com"}, http.StatusOK},
+		{"verification skipped", &config.UpstreamTLSConfig{InsecureSkipVerify: true}, http.StatusOK},
+	} {
+		b, err := NewBackendFromConfig(config.BackendConfig{Url: srv.URL, Timeout: time.Second, TLS: tc.tls}, &config.Config{})
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		b.SetAlive(true)
+		rec := httptest.NewRecorder()
+		b.Serve(rec, httptest.NewRequest(http.MethodGet, "/", nil), false)
+		if rec.Code != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, rec.Code, tc.want)
+		}
+	}
+
+	missing := &config.UpstreamTLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}
+	if _, err := NewBackendFromConfig(config.BackendConfig{Url: srv.URL, TLS: missing}, &config.Config{}); err == nil {
+		t.Error("backend with an unreadable ca_file was built")
+	}
+}
+
+func TestTransportLimits(t *testing.T) {
+	parse := func() *config.Config {
+		c, err := config.Parse([]byte("backends:\n  - url: http://10.0.0.1:80\n    timeout: 5s\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	defaults, custom := parse(), parse()
+	custom.Proxy.Upstream = config.UpstreamConfig{
+		MaxIdleConns:        50,
+		MaxIdleConnsPerHost: 10,
+		MaxConnsPerHost:     20,
+		IdleConnTimeout:     15 * time.Second,
+		DisableHTTP2:        true,
+	}
+
+	for _, tc := range []struct {
+		name  string
+		cfg   *config.Config
+		want  config.UpstreamConfig
+		http2 bool
+	}{
+		{"defaults", defaults, config.UpstreamConfig{MaxIdleConns: 1000, MaxIdleConnsPerHost: 200, IdleConnTimeout: 90 * time.Second}, true},
+		{"configured", custom, custom.Proxy.Upstream, false},
+	} {
+		b, err := NewBackendFromConfig(tc.cfg.Backends[0], tc.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr, ok := b.ReverseProxy.Transport.(*http.Transport)
+		if !ok || tr != b.transport {
+			t.Fatalf("%s: reverse proxy transport = %T, want the backend's own", tc.name, b.ReverseProxy.Transport)
+		}
+		got := config.UpstreamConfig{
+			MaxIdleConns:        tr.MaxIdleConns,
+			MaxIdleConnsPerHost: tr.MaxIdleConnsPerHost,
+			MaxConnsPerHost:     tr.MaxConnsPerHost,
+			IdleConnTimeout:     tr.IdleConnTimeout,
+			DisableHTTP2:        tc.want.DisableHTTP2,
+		}
+		if got != tc.want {
+			t.Errorf("%s: transport limits = %+v, want %+v", tc.name, got, tc.want)
+		}
+		if tr.ForceAttemptHTTP2 != tc.http2 || (tr.TLSNextProto == nil) != tc.http2 {
+			t.Errorf("%s: http2 attempted %v, TLSNextProto %v, want http2 %v", tc.name, tr.ForceAttemptHTTP2, tr.TLSNextProto, tc.http2)
+		}
+	}
+}
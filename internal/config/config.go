@@ -1,40 +1,407 @@
 package config
 
 import (
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
 	"sync/atomic"
 	"time"
 )
 
+type TLSConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+	MinVersion string `yaml:"min_version"`
+	// CipherSuites restricts TLS 1.0-1.2 connections to these suites, by
+	// their Go names such as TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Empty
+	// uses Go's defaults; TLS 1.3 suites are not configurable.
+	CipherSuites []string `yaml:"cipher_suites"`
+}
+
+type ReadinessConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+}
+
 type ServerConfig struct {
 	Port         uint16        `yaml:"port"`
 	ReadTimeout  time.Duration `yaml:"read_timeout"`
 	WriteTimeout time.Duration `yaml:"write_timeout"`
+	// ReadHeaderTimeout bounds reading a request's headers and IdleTimeout
+	// how long a keep-alive connection may sit between requests; 0 leaves
+	// Go's default. Neither touches WebSocket and other upgraded
+	// connections, which are hijacked once the upgrade is proxied.
+	ReadHeaderTimeout time.Duration   `yaml:"read_header_timeout"`
+	IdleTimeout       time.Duration   `yaml:"idle_timeout"`
+	TLS               TLSConfig       `yaml:"tls"`
+	Readiness         ReadinessConfig `yaml:"readiness"`
+	// ManagementPrefix is reserved for the load balancer's own endpoints
+	// and is never proxied upstream.
+	ManagementPrefix string `yaml:"management_prefix"`
+	// Listeners replaces the single ":port" listener with one per entry,
+	// e.g. to bind specific interfaces or serve plain and TLS together.
+	// Port and TLS are ignored when it is set.
+	Listeners []ListenerConfig `yaml:"listeners"`
+	// ACME obtains and renews the certificates of TLS listeners from an
+	// ACME CA such as Let's Encrypt instead of reading cert_file and
+	// key_file.
+	ACME ACMEConfig `yaml:"acme"`
+}
+
+// ACMEConfig requests certificates for Domains, keeping them and the
+// account key in CacheDir so restarts don't have to issue new ones.
+// HTTP-01 challenges are answered on every plain listener, which should
+// include one on port 80; TLS-ALPN-01 challenges on the TLS listeners.
+type ACMEConfig struct {
+	Enabled  bool     `yaml:"enabled"`
+	Domains  []string `yaml:"domains"`
+	CacheDir string   `yaml:"cache_dir"`
+	// Email is given to the CA for expiry and problem notices.
+	Email string `yaml:"email"`
+	// DirectoryURL selects the CA; empty means Let's Encrypt production.
+	DirectoryURL string `yaml:"directory_url"`
 }
 
+type ListenerConfig struct {
+	// Address is a host:port; an empty host binds every interface.
+	Address string    `yaml:"address"`
+	TLS     TLSConfig `yaml:"tls"`
+	// Mode is http (the default) or tcp. A tcp listener forwards raw
+	// connections to the backends of Group, whose URLs are
+	// tcp://host:port, and is health checked by TCP connect.
+	Mode  ListenerMode `yaml:"mode"`
+	Group string       `yaml:"group"`
+	// IdleTimeout closes a tcp connection once neither side has sent
+	// anything for this long; 0 keeps idle connections open.
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+}
+
+// TCP reports whether the listener forwards raw TCP.
+func (lc ListenerConfig) TCP() bool {
+	return lc.Mode == TCPListener
+}
+
+// ListenerMode is the protocol a listener serves.
+type ListenerMode string
+
+const (
+	HTTPListener ListenerMode = "http"
+	TCPListener  ListenerMode = "tcp"
+)
+
 type BackendConfig struct {
-	Url     string        `yaml:"url"`
-	Timeout time.Duration `yaml:"timeout"`
+	Url           string        `yaml:"url"`
+	Timeout       time.Duration `yaml:"timeout"`
+	MaxConcurrent int           `yaml:"max_concurrent"`
+	// Weight is the backend's share for weighted strategies, 1 when
+	// omitted. A weight of 0 keeps the backend out of weighted selection.
+	Weight *uint `yaml:"weight"`
+	// Priority is the backend's failover tier. Traffic goes to tier 0;
+	// higher tiers are backups used only while every backend in the
+	// tiers before them is down.
+	Priority uint `yaml:"priority"`
+	// PreserveHost sends the client's Host header to this backend; by
+	// default the backend's own host is sent.
+	PreserveHost bool `yaml:"preserve_host"`
+	// Host, when set, is sent as the Host header to this backend.
+	Host string `yaml:"host"`
+	// HealthCheck overrides parts of load_balancing.health_check for this
+	// backend.
+	HealthCheck *HealthCheckOverride `yaml:"health_check"`
+	// Protocol pins the HTTP version spoken to this backend; empty
+	// negotiates h2 over TLS and uses HTTP/1.1 otherwise.
+	Protocol BackendProtocol `yaml:"protocol"`
+	// TLS overrides proxy.upstream_tls for this backend.
+	TLS *UpstreamTLSConfig `yaml:"tls"`
+	// Discover resolves the URL's host to every A and AAAA record and
+	// keeps a backend per address. URLs of the form dns+srv://name (or
+	// dns+srv+https://name) are always discovered, from the SRV record.
+	Discover bool `yaml:"discover"`
+}
+
+// Discovered reports whether the entry stands for the backends found in
+// DNS rather than a single backend.
+func (bc BackendConfig) Discovered() bool {
+	return bc.Discover || strings.HasPrefix(bc.Url, "dns+srv")
+}
+
+// EffectiveWeight is the configured weight, or 1 when it was omitted.
+func (bc BackendConfig) EffectiveWeight() int {
+	if bc.Weight == nil {
+		return 1
+	}
+	return int(*bc.Weight)
+}
+
+// UpstreamTLSConfig controls how the load balancer verifies https
+// backends.
+type UpstreamTLSConfig struct {
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile             string `yaml:"ca_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	// ServerName overrides the SNI name and the name the certificate is
+	// checked against; empty uses the backend URL's host.
+	ServerName string `yaml:"server_name"`
 }
 
+// BackendProtocol is the HTTP version used to reach a backend.
+type BackendProtocol string
+
+const (
+	// HTTP1 keeps the backend on HTTP/1.1, even over TLS.
+	HTTP1 BackendProtocol = "http1"
+	// H2 speaks HTTP/2 over TLS, for https backends.
+	H2 BackendProtocol = "h2"
+	// H2C speaks HTTP/2 with prior knowledge over cleartext, for http
+	// backends such as plaintext gRPC servers.
+	H2C BackendProtocol = "h2c"
+)
+
+type HealthCheckType string
+
+const (
+	HTTPHealthCheck HealthCheckType = "http"
+	TCPHealthCheck  HealthCheckType = "tcp"
+	GRPCHealthCheck HealthCheckType = "grpc"
+)
+
 type HealthCheckConfig struct {
+	Type               HealthCheckType `yaml:"type"`
+	Interval           time.Duration   `yaml:"interval"`
+	Timeout            time.Duration   `yaml:"timeout"`
+	UnhealthyThreshold uint8           `yaml:"unhealthy_threshold"`
+	HealthyThreshold   uint8           `yaml:"healthy_threshold"`
+	// MaxConcurrent bounds how many backends are probed at once.
+	MaxConcurrent int `yaml:"max_concurrent"`
+	// GRPCService is the service name sent in grpc health checks; empty
+	// asks about the server as a whole.
+	GRPCService string `yaml:"grpc_service"`
+	// Headers are added to every http and grpc probe request.
+	Headers map[string]string `yaml:"headers"`
+	// Host overrides the Host header (the :authority for grpc) of probes.
+	Host string `yaml:"host"`
+	// Path and Method make up the http probe request, GET /health by
+	// default. Path may carry a query string.
+	Path   string `yaml:"path"`
+	Method string `yaml:"method"`
+	// ExpectedStatus and ExpectedStatusRanges list the http probe statuses
+	// that count as healthy; with neither set only 200 does. Redirects
+	// are never followed.
+	ExpectedStatus       []int         `yaml:"expected_status"`
+	ExpectedStatusRanges []StatusRange `yaml:"expected_status_ranges"`
+	// ExpectedBody, when set, must appear in the first 64KiB of the http
+	// probe's response body, and ExpectedBodyRegex must match it.
+	ExpectedBody      string `yaml:"expected_body"`
+	ExpectedBodyRegex string `yaml:"expected_body_regex"`
+	// MaxLatency fails probes that succeed but take longer than this; 0
+	// judges probes by their result alone.
+	MaxLatency time.Duration `yaml:"max_latency"`
+}
+
+// StatusRange is an inclusive range of HTTP status codes, such as
+// {min: 200, max: 299}.
+type StatusRange struct {
+	Min int `yaml:"min"`
+	Max int `yaml:"max"`
+}
+
+// AcceptsStatus reports whether an http probe answered with code passes.
+func (hc HealthCheckConfig) AcceptsStatus(code int) bool {
+	if len(hc.ExpectedStatus) == 0 && len(hc.ExpectedStatusRanges) == 0 {
+		return code == http.StatusOK
+	}
+	if slices.Contains(hc.ExpectedStatus, code) {
+		return true
+	}
+	for _, r := range hc.ExpectedStatusRanges {
+		if code >= r.Min && code <= r.Max {
+			return true
+		}
+	}
+	return false
+}
+
+// HealthCheckOverride replaces the probe timing and thresholds of the
+// global health check for one backend. Zero fields keep the global value.
+type HealthCheckOverride struct {
 	Interval           time.Duration `yaml:"interval"`
 	Timeout            time.Duration `yaml:"timeout"`
 	UnhealthyThreshold uint8         `yaml:"unhealthy_threshold"`
 	HealthyThreshold   uint8         `yaml:"healthy_threshold"`
 }
 
+// Apply returns hc with the fields set in o replacing its own. A nil o
+// returns hc unchanged.
+func (o *HealthCheckOverride) Apply(hc HealthCheckConfig) HealthCheckConfig {
+	if o == nil {
+		return hc
+	}
+	if o.Interval != 0 {
+		hc.Interval = o.Interval
+	}
+	if o.Timeout != 0 {
+		hc.Timeout = o.Timeout
+	}
+	if o.UnhealthyThreshold != 0 {
+		hc.UnhealthyThreshold = o.UnhealthyThreshold
+	}
+	if o.HealthyThreshold != 0 {
+		hc.HealthyThreshold = o.HealthyThreshold
+	}
+	return hc
+}
+
 type Strategy string
 
 const (
 	RoundRobin      Strategy = "round_robin"
 	Weighted        Strategy = "weighted"
 	LeastConnection Strategy = "least_conn"
-	ConsistentHash  Strategy = "consistent_hash"
+	// ConsistentHash pins each value of hash_key to one backend.
+	ConsistentHash          Strategy = "consistent_hash"
+	Random                  Strategy = "random"
+	P2C                     Strategy = "p2c"
+	SizeAware               Strategy = "size_aware"
+	LeastLatency            Strategy = "least_latency"
+	IPHash                  Strategy = "ip_hash"
+	WeightedLeastConnection Strategy = "weighted_least_conn"
+	WeightedRandom          Strategy = "weighted_random"
 )
 
+// RouteConfig matches requests by Host and/or path prefix. Matching
+// requests go to the named backend group, or to the top-level backends when
+// Group is empty. An empty Strategy inherits the default strategy.
+type RouteConfig struct {
+	Host       string      `yaml:"host"`
+	PathPrefix string      `yaml:"path_prefix"`
+	Match      *RouteMatch `yaml:"match"`
+	Group      string      `yaml:"group"`
+	// Backends serves the route from a pool of its own instead of a named
+	// backend group. It is loaded as a group named RouteGroupName(i).
+	Backends []BackendConfig `yaml:"backends"`
+	Strategy Strategy        `yaml:"strategy"`
+	// HashKey overrides load_balancing.hash_key for the route.
+	HashKey   string            `yaml:"hash_key"`
+	RateLimit RateLimiterConfig `yaml:"rate_limit"`
+	// MaxBodyBytes overrides proxy.max_body_bytes for the route; 0 keeps
+	// the global limit.
+	MaxBodyBytes int64 `yaml:"max_body_bytes"`
+	// PathRewrite changes the path sent to the route's backends.
+	PathRewrite *PathRewriteConfig `yaml:"path_rewrite"`
+}
+
+// RouteMatch is another way of writing a route's host and path_prefix,
+// as match: {host: api.example.com, path: /v1}.
+type RouteMatch struct {
+	Host string `yaml:"host"`
+	Path string `yaml:"path"`
+}
+
+// RouteGroupName is the backend group holding the backends given inline
+// in route i.
+func RouteGroupName(i int) string {
+	return fmt.Sprintf("route[%d]", i)
+}
+
+// PathRewriteConfig replaces a leading StripPrefix with Replacement, which
+// may be empty to simply strip it.
+type PathRewriteConfig struct {
+	StripPrefix string `yaml:"strip_prefix"`
+	Replacement string `yaml:"replacement"`
+}
+
+type BackendGroupConfig struct {
+	Name     string          `yaml:"name"`
+	Backends []BackendConfig `yaml:"backends"`
+}
+
+// GroupHealthCheck returns the health check for the named backend group.
+// Groups behind tcp listeners are checked by TCP connect, whatever the
+// configured type; every other pool uses LoadBalancing.HealthCheck as is.
+func (c *Config) GroupHealthCheck(group string) HealthCheckConfig {
+	hc := c.LoadBalancing.HealthCheck
+	for _, l := range c.Server.Listeners {
+		if l.TCP() && l.Group == group && group != "" {
+			hc.Type = TCPHealthCheck
+			break
+		}
+	}
+	return hc
+}
+
+type StickySessionConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	CookieName string        `yaml:"cookie_name"`
+	TTL        time.Duration `yaml:"ttl"`
+}
+
 type LoadBalancingConfig struct {
-	Strategy    Strategy          `yaml:"strategy"`
+	Strategy Strategy `yaml:"strategy"`
+	// HashKey is what consistent_hash hashes: path (the default), uri,
+	// host, client_ip, header:<name>, cookie:<name> or query:<name>.
+	HashKey     string            `yaml:"hash_key"`
 	HealthCheck HealthCheckConfig `yaml:"health_check"`
+	Routes      []RouteConfig     `yaml:"routes"`
+	RateLimit   RateLimiterConfig `yaml:"rate_limit"`
+	// DrainTimeout bounds how long a removed backend may keep serving
+	// in-flight requests before they are aborted; 0 falls back to the
+	// backend's own timeout.
+	DrainTimeout  time.Duration       `yaml:"drain_timeout"`
+	StickySession StickySessionConfig `yaml:"sticky_session"`
+	// SlowStart is how long a backend that has just become healthy takes
+	// to ramp up to its full share of traffic; 0 disables ramp-up.
+	SlowStart          time.Duration            `yaml:"slow_start"`
+	OutlierDetection   OutlierDetectionConfig   `yaml:"outlier_detection"`
+	PassiveHealthCheck PassiveHealthCheckConfig `yaml:"passive_health_check"`
+	// DiscoveryInterval is how often discovered backends are re-resolved.
+	DiscoveryInterval time.Duration `yaml:"discovery_interval"`
+	// Fallback answers requests that find no healthy backend.
+	Fallback MaintenanceConfig `yaml:"fallback"`
+	// Maintenance answers every request while maintenance mode is on;
+	// Enabled starts the load balancer in maintenance mode.
+	Maintenance MaintenanceConfig `yaml:"maintenance"`
+}
+
+// MaintenanceConfig is what clients get when no backend can serve them, or
+// during maintenance: either the response of a backend kept out of normal
+// rotation, or a static page.
+type MaintenanceConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// BackendURL, when set, receives the requests instead; the static page
+	// is not used.
+	BackendURL string        `yaml:"backend_url"`
+	Timeout    time.Duration `yaml:"timeout"`
+	// Status defaults to 503.
+	Status      int    `yaml:"status"`
+	Body        string `yaml:"body"`
+	BodyFile    string `yaml:"body_file"`
+	ContentType string `yaml:"content_type"`
+}
+
+// OutlierDetectionConfig ejects backends that fail live traffic
+// ConsecutiveErrors times within Window. Ejections last BaseEjectionTime
+// times the number of recent ejections, capped at MaxEjectionMultiplier.
+type OutlierDetectionConfig struct {
+	Enabled               bool          `yaml:"enabled"`
+	ConsecutiveErrors     int           `yaml:"consecutive_errors"`
+	Window                time.Duration `yaml:"window"`
+	BaseEjectionTime      time.Duration `yaml:"base_ejection_time"`
+	MaxEjectionMultiplier int           `yaml:"max_ejection_multiplier"`
+}
+
+// PassiveHealthCheckConfig takes a backend out for EjectionDuration once
+// FailureThreshold proxied requests to it fail, with a 5xx or a connection
+// error, within Window. Unlike outlier_detection the failures need not be
+// consecutive and every ejection lasts the same. The two cannot be enabled
+// together.
+type PassiveHealthCheckConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	FailureThreshold int           `yaml:"failure_threshold"`
+	Window           time.Duration `yaml:"window"`
+	EjectionDuration time.Duration `yaml:"ejection_duration"`
 }
 
 type RateLimiterConfig struct {
@@ -43,22 +410,188 @@ type RateLimiterConfig struct {
 	Size    uint    `yaml:"size"`
 }
 
+// ClientRateLimiterConfig limits each client to Rate and Size, except API
+// keys listed in Overrides, which get their own tier.
+type ClientRateLimiterConfig struct {
+	RateLimiterConfig `yaml:",inline"`
+	Overrides         map[string]RateLimitTier `yaml:"overrides"`
+}
+
+type RateLimitTier struct {
+	Rate float64 `yaml:"rate"`
+	Size uint    `yaml:"size"`
+}
+
+type APIKeyConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Keys    []string `yaml:"keys"`
+}
+
+type CacheConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	TTL         time.Duration `yaml:"ttl"`
+	MaxBodySize int           `yaml:"max_body_size"`
+	// MaxEntries bounds how many responses are kept; the oldest is
+	// evicted to make room.
+	MaxEntries int `yaml:"max_entries"`
+}
+
+type CompressionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	Level   int  `yaml:"level"`
+	// MinSize is the smallest response body, in bytes, worth compressing.
+	MinSize int `yaml:"min_size"`
+	// ContentTypes lists the media types to compress; empty uses a
+	// built-in list of text, JSON, JavaScript and XML types.
+	ContentTypes []string `yaml:"content_types"`
+}
+
+// GzipLevel maps the configured level onto compress/gzip, treating an unset
+// level as the library's balanced default.
+func (cc CompressionConfig) GzipLevel() int {
+	if cc.Level == 0 {
+		return gzip.DefaultCompression
+	}
+	return cc.Level
+}
+
+type AccessLogConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+type RequestIDConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// ResponseHeadersConfig removes headers by name, case-insensitively, then
+// sets and appends the given values.
+type ResponseHeadersConfig struct {
+	Enabled bool              `yaml:"enabled"`
+	Set     map[string]string `yaml:"set"`
+	Add     map[string]string `yaml:"add"`
+	Remove  []string          `yaml:"remove"`
+}
+
 type LoadShedderConfig struct {
-	Enabled         bool         `yaml:"enabled"`
-	inFlight        atomic.Int64 `yaml:"in_flight"`
-	p95LatencyEWMA  atomic.Int64 `yaml:"p95_latency_ewma"`
-	errorRate       atomic.Int64 `yaml:"error_rate"`
-	healthyBackends atomic.Int64 `yaml:"healthy_backends"`
+	Enabled bool `yaml:"enabled"`
+	// MaxInflight is the most requests handled at once; more are shed
+	// with 503.
+	MaxInflight int `yaml:"max_inflight"`
+	// RetryAfter is advertised to shed clients; it is rounded up to whole
+	// seconds.
+	RetryAfter      time.Duration `yaml:"retry_after"`
+	inFlight        atomic.Int64  `yaml:"in_flight"`
+	p95LatencyEWMA  atomic.Int64  `yaml:"p95_latency_ewma"`
+	errorRate       atomic.Int64  `yaml:"error_rate"`
+	healthyBackends atomic.Int64  `yaml:"healthy_backends"`
 }
 
 type MiddlewareConfig struct {
-	RateLimiter RateLimiterConfig `yaml:"rate_limiter"`
-	LoadShedder LoadShedderConfig `yaml:"load_shedder"`
+	APIKey      APIKeyConfig            `yaml:"api_key"`
+	RateLimiter ClientRateLimiterConfig `yaml:"rate_limiter"`
+	LoadShedder LoadShedderConfig       `yaml:"load_shedder"`
+	Cache       CacheConfig             `yaml:"cache"`
+	Compression CompressionConfig       `yaml:"compression"`
+	AccessLog   AccessLogConfig         `yaml:"access_log"`
+	RequestID   RequestIDConfig         `yaml:"request_id"`
+	// ResponseHeaders edits the headers of every response sent to clients.
+	ResponseHeaders ResponseHeadersConfig `yaml:"response_headers"`
+}
+
+// ErrorPageConfig replaces the plain-text body of errors the load balancer
+// generates itself. Body may use {{status}} and {{message}}.
+type ErrorPageConfig struct {
+	Body        string `yaml:"body"`
+	ContentType string `yaml:"content_type"`
+}
+
+type ProxyConfig struct {
+	ForwardedHeaders bool            `yaml:"forwarded_headers"`
+	MaxAttempts      int             `yaml:"max_attempts"`
+	ErrorPage        ErrorPageConfig `yaml:"error_page"`
+	// MaxBodyBytes rejects request bodies larger than this with 413; 0
+	// means unlimited.
+	MaxBodyBytes int64 `yaml:"max_body_bytes"`
+	// RetryBodyBytes is the largest request body buffered so that the
+	// request can be retried; 0 disables buffering.
+	RetryBodyBytes int64 `yaml:"retry_body_bytes"`
+	// RetryMethods lists the methods that may be retried; add POST to opt
+	// in to retrying non-idempotent requests.
+	RetryMethods []string `yaml:"retry_methods"`
+	// RetryBackoff spaces out retries against the same backend.
+	RetryBackoff RetryBackoffConfig `yaml:"retry_backoff"`
+	// Upstream tunes the connection pool kept to each backend.
+	Upstream UpstreamConfig `yaml:"upstream"`
+	// UpstreamTLS applies to every https backend without its own tls.
+	UpstreamTLS UpstreamTLSConfig `yaml:"upstream_tls"`
+	// TrustedProxies are the CIDRs (or single addresses) of peers whose
+	// X-Forwarded-For is believed when identifying clients. Without any,
+	// the client is always the connection's remote address.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// Mirror sends a copy of some requests to a shadow backend.
+	Mirror MirrorConfig `yaml:"mirror"`
+}
+
+// UpstreamConfig sizes the connection pool of each backend's transport.
+// MaxConnsPerHost of 0 means no limit.
+type UpstreamConfig struct {
+	MaxIdleConns        int           `yaml:"max_idle_conns"`
+	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host"`
+	MaxConnsPerHost     int           `yaml:"max_conns_per_host"`
+	IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout"`
+	// DisableHTTP2 keeps https backends on HTTP/1.1.
+	DisableHTTP2 bool `yaml:"disable_http2"`
+}
+
+// MirrorConfig copies Percent of requests to URL and discards the
+// responses. Only requests without a body, or whose body fits in
+// retry_body_bytes, can be mirrored.
+type MirrorConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	URL     string        `yaml:"url"`
+	Percent float64       `yaml:"percent"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// RetryBackoffConfig doubles the delay before each retry, starting at Base
+// and capped at Max. Jitter picks a random delay up to that value instead.
+type RetryBackoffConfig struct {
+	Base   time.Duration `yaml:"base"`
+	Max    time.Duration `yaml:"max"`
+	Jitter bool          `yaml:"jitter"`
+}
+
+type AdminConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Port    uint16 `yaml:"port"`
+	// Debug serves pprof and expvar under /debug/ on the admin port.
+	Debug bool `yaml:"debug"`
+}
+
+type LoggingConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	Port    uint16 `yaml:"port"`
+}
+
+type WatcherConfig struct {
+	Debounce time.Duration `yaml:"debounce"`
 }
 
 type Config struct {
-	Server        ServerConfig        `yaml:"server"`
-	Backends      []BackendConfig     `yaml:"backends"`
-	LoadBalancing LoadBalancingConfig `yaml:"load_balancing"`
-	Middlewares   MiddlewareConfig    `yaml:"middlewares"`
+	Server        ServerConfig         `yaml:"server"`
+	Backends      []BackendConfig      `yaml:"backends"`
+	BackendGroups []BackendGroupConfig `yaml:"backend_groups"`
+	LoadBalancing LoadBalancingConfig  `yaml:"load_balancing"`
+	Proxy         ProxyConfig          `yaml:"proxy"`
+	Middlewares   MiddlewareConfig     `yaml:"middlewares"`
+	Metrics       MetricsConfig        `yaml:"metrics"`
+	Logging       LoggingConfig        `yaml:"logging"`
+	Admin         AdminConfig          `yaml:"admin"`
+	Watcher       WatcherConfig        `yaml:"watcher"`
 }
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv replaces ${VAR} and ${VAR:-default} references in the scalar
+// values of doc. Keys and comments are left alone, so a reference in a
+// commented-out line is never looked up. A variable that is unset and has
+// no default is an error rather than being silently replaced with an empty
+// string.
+func expandEnv(doc *yaml.Node) error {
+	var missing []string
+
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		switch n.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, child := range n.Content {
+				walk(child)
+			}
+		case yaml.MappingNode:
+			for i := 1; i < len(n.Content); i += 2 {
+				walk(n.Content[i])
+			}
+		case yaml.ScalarNode:
+			value := envRef.ReplaceAllStringFunc(n.Value, func(ref string) string {
+				m := envRef.FindStringSubmatch(ref)
+				if v, ok := os.LookupEnv(m[1]); ok {
+					return v
+				}
+				if m[2] != "" {
+					return m[3]
+				}
+				missing = append(missing, m[1])
+				return ref
+			})
+			if value != n.Value {
+				n.Value = value
+				// A plain scalar is typed again from what it expanded to, so
+				// port: ${PORT} still decodes as a number.
+				if n.Style == 0 {
+					n.Tag = ""
+				}
+			}
+		}
+	}
+	walk(doc)
+
+	if len(missing) > 0 {
+		return fmt.Errorf("undefined environment variables: %v", missing)
+	}
+	return nil
+}
+
+// applyEnvOverrides lets well-known LB_* variables take precedence over the
+// values parsed from the file.
+func (c *Config) applyEnvOverrides() error {
+	if v, ok := os.LookupEnv("LB_SERVER_PORT"); ok {
+		port, err := strconv.ParseUint(v, 10, 16)
+		if err != nil {
+			return fmt.Errorf("LB_SERVER_PORT: %w", err)
+		}
+		c.Server.Port = uint16(port)
+	}
+	if v, ok := os.LookupEnv("LB_STRATEGY"); ok {
+		c.LoadBalancing.Strategy = Strategy(v)
+	}
+	if v, ok := os.LookupEnv("LB_LOG_LEVEL"); ok {
+		c.Logging.Level = v
+	}
+	if v, ok := os.LookupEnv("LB_LOG_FORMAT"); ok {
+		c.Logging.Format = v
+	}
+	if v, ok := os.LookupEnv("LB_TLS_CERT_FILE"); ok {
+		c.Server.TLS.CertFile = v
+	}
+	if v, ok := os.LookupEnv("LB_TLS_KEY_FILE"); ok {
+		c.Server.TLS.KeyFile = v
+	}
+	return nil
+}
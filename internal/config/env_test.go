@@ -0,0 +1,122 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("LB_TEST_BACKEND", "http://10.0.0.1:80")
+	t.Setenv("LB_TEST_PORT", "9000")
+
+	c, err := Parse([]byte(`
+server:
+  port: ${LB_TEST_PORT}
+backends:
+  - url: ${LB_TEST_BACKEND}
+    timeout: ${LB_TEST_TIMEOUT:-7s}
+middlewares:
+  api_key:
+    keys: ["key-${LB_TEST_KEY:-}"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.Port != 9000 {
+		t.Errorf("port = %d, want 9000", c.Server.Port)
+	}
+	if got := c.Backends[0].Url; got != "http://10.0.0.1:80" {
+		t.Errorf("url = %q", got)
+	}
+	if got := c.Backends[0].Timeout; got != 7*time.Second {
+		t.Errorf("timeout = %v, want the 7s default", got)
+	}
+	if got := c.Middlewares.APIKey.Keys; len(got) != 1 || got[0] != "key-" {
+		t.Errorf("keys = %q, want an empty default", got)
+	}
+}
+
+func TestExpandEnvUndefined(t *testing.T) {
+	_, err := Parse([]byte("backends:\n  - url: ${LB_TEST_UNSET_VAR}\n"))
+	if err == nil || !strings.Contains(err.Error(), "LB_TEST_UNSET_VAR") {
+		t.Fatalf("err = %v, want it to name the undefined variable", err)
+	}
+}
+
+func TestExpandEnvSkipsComments(t *testing.T) {
+	c, err := Parse([]byte(`
+backends:
+  # - url: ${LB_TEST_UNSET_VAR}
+  - url: http://10.0.0.1:80 # not ${LB_TEST_UNSET_VAR} either
+`))
+	if err != nil {
+		t.Fatalf("a reference in a comment was expanded: %v", err)
+	}
+	if len(c.Backends) != 1 {
+		t.Errorf("got %d backends, want 1", len(c.Backends))
+	}
+}
+
+func TestExpandEnvLeavesKeys(t *testing.T) {
+	c, err := Parse([]byte(`
+middlewares:
+  rate_limiter:
+    overrides:
+      ${LB_TEST_UNSET_VAR}:
+        rate: 1
+        size: 1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Middlewares.RateLimiter.Overrides["${LB_TEST_UNSET_VAR}"]; !ok {
+		t.Errorf("overrides = %v, want the key kept verbatim", c.Middlewares.RateLimiter.Overrides)
+	}
+}
+
+func TestExpandEnvJSON(t *testing.T) {
+	t.Setenv("LB_TEST_BACKEND", "http://10.0.0.1:80")
+	c, err := ParseJSON([]byte(`{"backends": [{"url": "${LB_TEST_BACKEND}", "timeout": "${LB_TEST_TIMEOUT:-3s}"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := c.Backends[0]; b.Url != "http://10.0.0.1:80" || b.Timeout != 3*time.Second {
+		t.Errorf("backend = %+v", b)
+	}
+}
+
+func TestEnvOverridesWin(t *testing.T) {
+	t.Setenv("LB_TEST_PORT", "9000")
+	t.Setenv("LB_SERVER_PORT", "9100")
+	t.Setenv("LB_STRATEGY", "least_conn")
+	t.Setenv("LB_LOG_LEVEL", "debug")
+
+	c, err := Parse([]byte(`
+server:
+  port: ${LB_TEST_PORT}
+load_balancing:
+  strategy: round_robin
+logging:
+  level: info
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.Port != 9100 {
+		t.Errorf("port = %d, want LB_SERVER_PORT's 9100", c.Server.Port)
+	}
+	if c.LoadBalancing.Strategy != LeastConnection {
+		t.Errorf("strategy = %s, want least_conn", c.LoadBalancing.Strategy)
+	}
+	if c.Logging.Level != "debug" {
+		t.Errorf("log level = %s, want debug", c.Logging.Level)
+	}
+}
+
+func TestEnvOverrideInvalid(t *testing.T) {
+	t.Setenv("LB_SERVER_PORT", "not-a-port")
+	if _, err := Parse([]byte("server:\n  port: 8080\n")); err == nil || !strings.Contains(err.Error(), "LB_SERVER_PORT") {
+		t.Fatalf("err = %v, want an LB_SERVER_PORT error", err)
+	}
+}
@@ -1,12 +1,33 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
+// FormatFromPath picks the config format from the file extension, treating
+// anything that isn't .json as YAML.
+func FormatFromPath(path string) Format {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return FormatJSON
+	}
+	return FormatYAML
+}
+
 func Load(path string) (*Config, error) {
 
 	configContent, err := os.ReadFile(path)
@@ -14,7 +35,7 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	c, err := Parse(configContent)
+	c, err := ParseFormat(configContent, FormatFromPath(path))
 	if (err) != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
@@ -23,12 +44,138 @@ func Load(path string) (*Config, error) {
 }
 
 func Parse(data []byte) (*Config, error) {
+	return ParseFormat(data, FormatYAML)
+}
+
+// ParseJSON reads a JSON config. It uses the same keys as the YAML schema;
+// the config structs carry only yaml tags.
+func ParseJSON(data []byte) (*Config, error) {
+	return ParseFormat(data, FormatJSON)
+}
+
+func ParseFormat(data []byte, format Format) (*Config, error) {
 	c := &Config{}
 
-	err := yaml.Unmarshal(data, c)
+	var err error
+	switch format {
+	case FormatYAML:
+	case FormatJSON:
+		data, err = jsonToYAML(data)
+	default:
+		return nil, fmt.Errorf("unsupported config format: %s", format)
+	}
 	if (err) != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if err := expandEnv(&doc); err != nil {
+		return nil, err
+	}
+	if err := doc.Decode(c); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	if err := c.applyEnvOverrides(); err != nil {
+		return nil, err
+	}
+
+	c.setDefaults()
+
 	return c, nil
 }
+
+// inlineRoutes folds each route's match block into host and path_prefix
+// and turns backends given inline into a backend group of their own, so
+// the rest of the load balancer only ever sees groups. Conflicting
+// settings are left for Validate to report.
+func (c *Config) inlineRoutes() {
+	for i := range c.LoadBalancing.Routes {
+		route := &c.LoadBalancing.Routes[i]
+		if m := route.Match; m != nil {
+			if route.Host == "" {
+				route.Host = m.Host
+			}
+			if route.PathPrefix == "" {
+				route.PathPrefix = m.Path
+			}
+		}
+		if len(route.Backends) > 0 && route.Group == "" {
+			route.Group = RouteGroupName(i)
+			c.BackendGroups = append(c.BackendGroups, BackendGroupConfig{Name: route.Group, Backends: route.Backends})
+		}
+	}
+}
+
+// jsonToYAML strictly decodes data as JSON and re-encodes it as YAML for
+// the YAML decoder, so JSON is read through the yaml tags and durations
+// such as "15s" are accepted exactly as they are in YAML files rather than
+// as raw nanosecond integers.
+func jsonToYAML(data []byte) ([]byte, error) {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(raw)
+}
+
+func (c *Config) setDefaults() {
+	c.inlineRoutes()
+	if c.Proxy.MaxAttempts == 0 {
+		c.Proxy.MaxAttempts = 3
+	}
+	if c.Proxy.RetryMethods == nil {
+		c.Proxy.RetryMethods = []string{"GET", "HEAD", "PUT", "DELETE"}
+	}
+	if c.Proxy.RetryBackoff.Base == 0 {
+		c.Proxy.RetryBackoff.Base = 10 * time.Millisecond
+	}
+	if c.Proxy.RetryBackoff.Max == 0 {
+		c.Proxy.RetryBackoff.Max = max(time.Second, c.Proxy.RetryBackoff.Base)
+	}
+	if c.LoadBalancing.DiscoveryInterval == 0 {
+		c.LoadBalancing.DiscoveryInterval = 30 * time.Second
+	}
+	if c.Proxy.Upstream.MaxIdleConns == 0 {
+		c.Proxy.Upstream.MaxIdleConns = 1000
+	}
+	if c.Proxy.Upstream.MaxIdleConnsPerHost == 0 {
+		c.Proxy.Upstream.MaxIdleConnsPerHost = 200
+	}
+	if c.Proxy.Upstream.IdleConnTimeout == 0 {
+		c.Proxy.Upstream.IdleConnTimeout = 90 * time.Second
+	}
+	if c.Middlewares.Cache.MaxEntries == 0 {
+		c.Middlewares.Cache.MaxEntries = 10000
+	}
+	if c.Proxy.Mirror.Timeout == 0 {
+		c.Proxy.Mirror.Timeout = 5 * time.Second
+	}
+	if c.LoadBalancing.HealthCheck.Path == "" {
+		c.LoadBalancing.HealthCheck.Path = "/health"
+	}
+	if c.LoadBalancing.HealthCheck.Method == "" {
+		c.LoadBalancing.HealthCheck.Method = http.MethodGet
+	}
+	if c.LoadBalancing.HealthCheck.MaxConcurrent == 0 {
+		c.LoadBalancing.HealthCheck.MaxConcurrent = 32
+	}
+	if c.LoadBalancing.Fallback.Status == 0 {
+		c.LoadBalancing.Fallback.Status = 503
+	}
+	if c.LoadBalancing.Maintenance.Status == 0 {
+		c.LoadBalancing.Maintenance.Status = 503
+	}
+	if c.Server.ACME.CacheDir == "" {
+		c.Server.ACME.CacheDir = "certs/acme"
+	}
+	if c.Server.ManagementPrefix == "" {
+		c.Server.ManagementPrefix = "/__lb/"
+	}
+	if c.Server.Readiness.Path == "" {
+		c.Server.Readiness.Path = "/__health"
+	}
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadYAMLAndJSON(t *testing.T) {
+	fromYAML, err := Load("testdata/config.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON, err := Load("testdata/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromYAML, fromJSON) {
+		t.Fatalf("configs differ:\nyaml: %+v\njson: %+v", fromYAML, fromJSON)
+	}
+
+	for name, c := range map[string]*Config{"yaml": fromYAML, "json": fromJSON} {
+		if err := c.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		c.LoadBalancing.HealthCheck.Interval = 0
+		if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "interval") {
+			t.Errorf("%s: err = %v, want the missing interval rejected", name, err)
+		}
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	for path, want := range map[string]Format{
+		"config.json": FormatJSON,
+		"CONFIG.JSON": FormatJSON,
+		"config.yml":  FormatYAML,
+		"config.yaml": FormatYAML,
+		"config":      FormatYAML,
+	} {
+		if got := FormatFromPath(path); got != want {
+			t.Errorf("%s: %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestParseJSONInvalid(t *testing.T) {
+	if _, err := ParseJSON([]byte(`{"backends": [`)); err == nil {
+		t.Error("malformed JSON parsed")
+	}
+}
@@ -1,12 +1,23 @@
 package config
 
 import (
+	"compress/gzip"
+	"crypto/tls"
 	"fmt"
+	"net"
+	"net/http"
 	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
 )
 
 func (c *Config) Validate() error {
-	if c.Server.Port == 0 {
+	if c.Server.Port == 0 && len(c.Server.Listeners) == 0 {
 		return fmt.Errorf("port cannot be 0")
 	}
 	if c.Server.ReadTimeout <= 0 {
@@ -15,27 +26,714 @@ func (c *Config) Validate() error {
 	if c.Server.WriteTimeout <= 0 {
 		return fmt.Errorf("write timeout must be positive")
 	}
+	if c.Server.ReadHeaderTimeout < 0 {
+		return fmt.Errorf("read header timeout cannot be negative")
+	}
+	if c.Server.IdleTimeout < 0 {
+		return fmt.Errorf("idle timeout cannot be negative")
+	}
+
+	if c.Server.Readiness.Enabled && !strings.HasPrefix(c.Server.Readiness.Path, "/") {
+		return fmt.Errorf("readiness path must start with /")
+	}
+
+	mp := c.Server.ManagementPrefix
+	if !strings.HasPrefix(mp, "/") || !strings.HasSuffix(mp, "/") || len(mp) < 3 {
+		return fmt.Errorf("management prefix must start and end with / and not be the root")
+	}
+
+	acme := c.Server.ACME
+	if acme.Enabled {
+		if len(acme.Domains) == 0 {
+			return fmt.Errorf("acme requires at least one domain when enabled")
+		}
+		for _, d := range acme.Domains {
+			if d == "" || strings.ContainsAny(d, "/:* ") {
+				return fmt.Errorf("acme domain %q must be a plain host name", d)
+			}
+		}
+	}
+
+	if c.Server.TLS.Enabled && len(c.Server.Listeners) == 0 {
+		if err := c.Server.TLS.validate(acme.Enabled); err != nil {
+			return err
+		}
+	}
+
+	addrs := make(map[string]struct{}, len(c.Server.Listeners))
+	for i, l := range c.Server.Listeners {
+		_, port, err := net.SplitHostPort(l.Address)
+		if err != nil {
+			return fmt.Errorf("listeners[%d]: invalid address %q: %w", i, l.Address, err)
+		}
+		if p, err := strconv.ParseUint(port, 10, 16); err != nil || p == 0 {
+			return fmt.Errorf("listeners[%d]: invalid port in %q", i, l.Address)
+		}
+		if _, ok := addrs[l.Address]; ok {
+			return fmt.Errorf("listeners[%d]: duplicate address %q", i, l.Address)
+		}
+		addrs[l.Address] = struct{}{}
+		if l.TLS.Enabled {
+			if err := l.TLS.validate(acme.Enabled); err != nil {
+				return fmt.Errorf("listeners[%d]: %w", i, err)
+			}
+		}
+	}
+
+	if acme.Enabled && !c.Server.servesTLS() {
+		return fmt.Errorf("acme requires a listener with tls enabled")
+	}
 
 	if len(c.Backends) == 0 {
 		return fmt.Errorf("at least one backend must be specified")
 	}
-	for i, backend := range c.Backends {
-		_, err := url.Parse(backend.Url)
+	if err := validateBackends("backend", c.Backends); err != nil {
+		return err
+	}
+	if err := c.requireWeight("backends", c.Backends); err != nil {
+		return err
+	}
+
+	groups := make(map[string]struct{}, len(c.BackendGroups))
+	for i, group := range c.BackendGroups {
+		if group.Name == "" {
+			return fmt.Errorf("backend_groups[%d]: name must be set", i)
+		}
+		if _, ok := groups[group.Name]; ok {
+			return fmt.Errorf("backend_groups[%d]: duplicate group name %q", i, group.Name)
+		}
+		groups[group.Name] = struct{}{}
+		if len(group.Backends) == 0 {
+			return fmt.Errorf("backend group %q: at least one backend must be specified", group.Name)
+		}
+		if err := validateBackends(fmt.Sprintf("backend group %q: backend", group.Name), group.Backends); err != nil {
+			return err
+		}
+		if err := c.requireWeight(fmt.Sprintf("backend group %q", group.Name), group.Backends); err != nil {
+			return err
+		}
+	}
+
+	tcpGroups, err := c.validateTCPListeners()
+	if err != nil {
+		return err
+	}
+
+	if c.Proxy.MaxAttempts < 1 {
+		return fmt.Errorf("proxy max attempts must be at least 1")
+	}
+	if c.Proxy.MaxBodyBytes < 0 {
+		return fmt.Errorf("proxy max body bytes cannot be negative")
+	}
+	if _, err := util.ParseTrustedProxies(c.Proxy.TrustedProxies); err != nil {
+		return fmt.Errorf("proxy: %w", err)
+	}
+	if up := c.Proxy.Upstream; up.MaxIdleConns < 0 || up.MaxIdleConnsPerHost < 0 || up.MaxConnsPerHost < 0 || up.IdleConnTimeout < 0 {
+		return fmt.Errorf("proxy upstream connection limits and idle timeout cannot be negative")
+	}
+	if err := c.Proxy.UpstreamTLS.validate(); err != nil {
+		return fmt.Errorf("proxy: %w", err)
+	}
+	if c.Proxy.RetryBodyBytes < 0 {
+		return fmt.Errorf("proxy retry body bytes cannot be negative")
+	}
+	for _, m := range c.Proxy.RetryMethods {
+		if m == "" || strings.ToUpper(m) != m {
+			return fmt.Errorf("proxy retry method %q must be an upper-case HTTP method", m)
+		}
+	}
+
+	if rb := c.Proxy.RetryBackoff; rb.Base < 0 || rb.Max < rb.Base {
+		return fmt.Errorf("proxy retry backoff base must be positive and no larger than max")
+	}
+
+	if m := c.Proxy.Mirror; m.Enabled {
+		u, err := url.Parse(m.URL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("proxy mirror url must be an absolute URL")
+		}
+		if m.Percent <= 0 || m.Percent > 100 {
+			return fmt.Errorf("proxy mirror percent must be between 0 and 100")
+		}
+		if m.Timeout < 0 {
+			return fmt.Errorf("proxy mirror timeout cannot be negative")
+		}
+	}
+
+	if c.LoadBalancing.DiscoveryInterval < 0 {
+		return fmt.Errorf("discovery interval cannot be negative")
+	}
+
+	if !c.LoadBalancing.Strategy.valid() {
+		return fmt.Errorf("unrecognized load balancing strategy: %s", c.LoadBalancing.Strategy)
+	}
+	if !validHashKey(c.LoadBalancing.HashKey) {
+		return fmt.Errorf("unrecognized hash key: %s", c.LoadBalancing.HashKey)
+	}
+	for i, route := range c.LoadBalancing.Routes {
+		if route.Host == "" && route.PathPrefix == "" {
+			return fmt.Errorf("route[%d]: host or path prefix must be set", i)
+		}
+		if m := route.Match; m != nil && (m.Host != "" && m.Host != route.Host || m.Path != "" && m.Path != route.PathPrefix) {
+			return fmt.Errorf("route[%d]: match conflicts with host or path prefix", i)
+		}
+		if len(route.Backends) > 0 && route.Group != RouteGroupName(i) {
+			return fmt.Errorf("route[%d]: group and backends cannot both be set", i)
+		}
+		if route.PathPrefix != "" && !strings.HasPrefix(route.PathPrefix, "/") {
+			return fmt.Errorf("route[%d]: path prefix must start with /", i)
+		}
+		if _, ok := groups[route.Group]; route.Group != "" && !ok {
+			return fmt.Errorf("route[%d]: unknown backend group %q", i, route.Group)
+		}
+		if tcpGroups[route.Group] {
+			return fmt.Errorf("route[%d]: backend group %q is served by a tcp listener", i, route.Group)
+		}
+		if route.Strategy != "" && !route.Strategy.valid() {
+			return fmt.Errorf("route[%d]: unrecognized load balancing strategy: %s", i, route.Strategy)
+		}
+		if !validHashKey(route.HashKey) {
+			return fmt.Errorf("route[%d]: unrecognized hash key: %s", i, route.HashKey)
+		}
+		if route.RateLimit.Enabled && (route.RateLimit.Rate <= 0 || route.RateLimit.Size == 0) {
+			return fmt.Errorf("route[%d]: group rate limit rate and size must be positive when enabled", i)
+		}
+		if route.MaxBodyBytes < 0 {
+			return fmt.Errorf("route[%d]: max body bytes cannot be negative", i)
+		}
+		if pr := route.PathRewrite; pr != nil {
+			if !strings.HasPrefix(pr.StripPrefix, "/") {
+				return fmt.Errorf("route[%d]: path rewrite strip prefix must start with /", i)
+			}
+			if pr.Replacement != "" && !strings.HasPrefix(pr.Replacement, "/") {
+				return fmt.Errorf("route[%d]: path rewrite replacement must start with /", i)
+			}
+		}
+	}
+	if gl := c.LoadBalancing.RateLimit; gl.Enabled && (gl.Rate <= 0 || gl.Size == 0) {
+		return fmt.Errorf("group rate limit rate and size must be positive when enabled")
+	}
+
+	ss := c.LoadBalancing.StickySession
+	if ss.Enabled {
+		if ss.CookieName == "" {
+			return fmt.Errorf("sticky session cookie_name must be set when enabled")
+		}
+		if ss.TTL <= 0 {
+			return fmt.Errorf("sticky session ttl must be positive when enabled")
+		}
+	}
+
+	if c.LoadBalancing.DrainTimeout < 0 {
+		return fmt.Errorf("drain timeout cannot be negative")
+	}
+
+	if c.LoadBalancing.SlowStart < 0 {
+		return fmt.Errorf("slow start cannot be negative")
+	}
+
+	if od := c.LoadBalancing.OutlierDetection; od.Enabled {
+		if od.ConsecutiveErrors < 1 {
+			return fmt.Errorf("outlier detection consecutive errors must be at least 1")
+		}
+		if od.Window < 0 {
+			return fmt.Errorf("outlier detection window cannot be negative")
+		}
+		if od.BaseEjectionTime <= 0 {
+			return fmt.Errorf("outlier detection base ejection time must be positive")
+		}
+		if od.MaxEjectionMultiplier < 1 {
+			return fmt.Errorf("outlier detection max ejection multiplier must be at least 1")
+		}
+	}
+
+	if ph := c.LoadBalancing.PassiveHealthCheck; ph.Enabled {
+		if c.LoadBalancing.OutlierDetection.Enabled {
+			return fmt.Errorf("passive health check and outlier detection cannot both be enabled")
+		}
+		if ph.FailureThreshold < 1 {
+			return fmt.Errorf("passive health check failure threshold must be at least 1")
+		}
+		if ph.Window <= 0 {
+			return fmt.Errorf("passive health check window must be positive")
+		}
+		if ph.EjectionDuration <= 0 {
+			return fmt.Errorf("passive health check ejection duration must be positive")
+		}
+	}
+
+	if fb := c.LoadBalancing.Fallback; fb.Enabled {
+		if err := fb.validate("fallback"); err != nil {
+			return err
+		}
+	}
+	// Maintenance mode can be switched on at runtime, so a configured page
+	// is checked even when it doesn't start enabled.
+	if m := c.LoadBalancing.Maintenance; m.Configured() {
+		if err := m.validate("maintenance"); err != nil {
+			return err
+		}
+	}
+
+	hc := c.LoadBalancing.HealthCheck
+	switch hc.Type {
+	case "", HTTPHealthCheck, TCPHealthCheck:
+	case GRPCHealthCheck:
+		if err := requirePorts("backend", c.Backends); err != nil {
+			return err
+		}
+		for _, group := range c.BackendGroups {
+			if err := requirePorts(fmt.Sprintf("backend group %q: backend", group.Name), group.Backends); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unrecognized health check type: %s", hc.Type)
+	}
+	if err := hc.validateTiming(); err != nil {
+		return err
+	}
+	if err := validateHealthOverrides("backend", c.Backends, hc); err != nil {
+		return err
+	}
+	for _, group := range c.BackendGroups {
+		if err := validateHealthOverrides(fmt.Sprintf("backend group %q: backend", group.Name), group.Backends, hc); err != nil {
+			return err
+		}
+	}
+	if hc.MaxConcurrent < 1 {
+		return fmt.Errorf("health check max concurrent must be at least 1")
+	}
+	for _, code := range hc.ExpectedStatus {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("health check expected status %d is not a valid HTTP status code", code)
+		}
+	}
+	for _, r := range hc.ExpectedStatusRanges {
+		if r.Min < 100 || r.Max > 599 || r.Min > r.Max {
+			return fmt.Errorf("health check expected status range %d-%d is not a range of valid HTTP status codes", r.Min, r.Max)
+		}
+	}
+	if !strings.HasPrefix(hc.Path, "/") {
+		return fmt.Errorf("health check path must start with /")
+	}
+	switch hc.Method {
+	case http.MethodGet, http.MethodPost, http.MethodOptions:
+	case http.MethodHead:
+		if hc.ExpectedBody != "" || hc.ExpectedBodyRegex != "" {
+			return fmt.Errorf("health check body matching needs a method other than HEAD")
+		}
+	default:
+		return fmt.Errorf("unsupported health check method: %s", hc.Method)
+	}
+	if _, err := regexp.Compile(hc.ExpectedBodyRegex); err != nil {
+		return fmt.Errorf("invalid health check expected body regex: %w", err)
+	}
+
+	ak := c.Middlewares.APIKey
+	if ak.Enabled && len(ak.Keys) == 0 {
+		return fmt.Errorf("api key validation requires at least one key when enabled")
+	}
+
+	rl := c.Middlewares.RateLimiter
+	if rl.Enabled {
+		if rl.Rate <= 0 {
+			return fmt.Errorf("rate limiter refill rate must be positive when enabled")
+		}
+		if rl.Size == 0 {
+			return fmt.Errorf("rate limiter size must be positive when enabled")
+		}
+		for key, tier := range rl.Overrides {
+			if key == "" {
+				return fmt.Errorf("rate limiter override key cannot be empty")
+			}
+			if tier.Rate <= 0 || tier.Size == 0 {
+				return fmt.Errorf("rate limiter override for key %s...: rate and size must be positive", key[:min(4, len(key))])
+			}
+		}
+	}
+
+	cc := c.Middlewares.Cache
+	if cc.Enabled {
+		if cc.TTL <= 0 {
+			return fmt.Errorf("cache ttl must be positive when enabled")
+		}
+		if cc.MaxBodySize <= 0 {
+			return fmt.Errorf("cache max body size must be positive when enabled")
+		}
+		if cc.MaxEntries < 1 {
+			return fmt.Errorf("cache max entries must be at least 1 when enabled")
+		}
+	}
+
+	if ls := &c.Middlewares.LoadShedder; ls.Enabled {
+		if ls.MaxInflight < 1 {
+			return fmt.Errorf("load shedder max inflight must be at least 1 when enabled")
+		}
+		if ls.RetryAfter < 0 {
+			return fmt.Errorf("load shedder retry after cannot be negative")
+		}
+	}
+
+	cl := c.Middlewares.Compression.Level
+	if cl != 0 && (cl < gzip.BestSpeed || cl > gzip.BestCompression) {
+		return fmt.Errorf("compression level must be between %d and %d", gzip.BestSpeed, gzip.BestCompression)
+	}
+	if c.Middlewares.Compression.MinSize < 0 {
+		return fmt.Errorf("compression min size cannot be negative")
+	}
+
+	switch strings.ToLower(c.Logging.Level) {
+	case "", "debug", "info", "warn", "warning", "error":
+	default:
+		return fmt.Errorf("unrecognized log level: %s", c.Logging.Level)
+	}
+	switch strings.ToLower(c.Logging.Format) {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("unrecognized log format: %s", c.Logging.Format)
+	}
+
+	mc := c.Metrics
+	if mc.Enabled {
+		if mc.Port == 0 {
+			return fmt.Errorf("metrics port cannot be 0 when enabled")
+		}
+		if mc.Port == c.Server.Port {
+			return fmt.Errorf("metrics port must differ from server port")
+		}
+		if mc.Path != "" && !strings.HasPrefix(mc.Path, "/") {
+			return fmt.Errorf("metrics path must start with /")
+		}
+	}
+
+	if c.Watcher.Debounce < 0 {
+		return fmt.Errorf("watcher debounce cannot be negative")
+	}
+
+	ac := c.Admin
+	if ac.Enabled {
+		if ac.Port == 0 {
+			return fmt.Errorf("admin port cannot be 0 when enabled")
+		}
+		if ac.Port == c.Server.Port || (mc.Enabled && ac.Port == mc.Port) {
+			return fmt.Errorf("admin port must differ from server and metrics ports")
+		}
+	}
+
+	return nil
+}
+
+func validateBackends(prefix string, backends []BackendConfig) error {
+	seen := make(map[string]string, len(backends))
+	for i, backend := range backends {
+		key, err := NormalizeURL(backend.Url)
 		if err != nil {
-			return fmt.Errorf("backend[%d]: invalid URL: %w", i, err)
+			return fmt.Errorf("%s[%d]: invalid URL: %w", prefix, i, err)
 		}
+		if first, ok := seen[key]; ok {
+			return fmt.Errorf("%s[%d]: duplicate backend URL %s (same as %s)", prefix, i, backend.Url, first)
+		}
+		seen[key] = backend.Url
 		if backend.Timeout <= 0 {
-			return fmt.Errorf("backend timeout must be positive")
+			return fmt.Errorf("%s[%d]: timeout must be positive", prefix, i)
+		}
+		if backend.MaxConcurrent < 0 {
+			return fmt.Errorf("%s[%d]: max concurrent cannot be negative", prefix, i)
+		}
+		if backend.PreserveHost && backend.Host != "" {
+			return fmt.Errorf("%s[%d]: preserve_host and host cannot both be set", prefix, i)
+		}
+		if u, _ := url.Parse(backend.Url); strings.HasPrefix(u.Scheme, "dns+srv") && u.Scheme != "dns+srv" && u.Scheme != "dns+srv+https" {
+			return fmt.Errorf("%s[%d]: srv discovery scheme must be dns+srv or dns+srv+https", prefix, i)
+		}
+		if err := backend.validateProtocol(); err != nil {
+			return fmt.Errorf("%s[%d]: %w", prefix, i, err)
+		}
+		if backend.TLS != nil {
+			if err := backend.TLS.validate(); err != nil {
+				return fmt.Errorf("%s[%d]: %w", prefix, i, err)
+			}
 		}
 	}
+	return nil
+}
 
-	switch c.LoadBalancing.Strategy {
-	case RoundRobin, Weighted, LeastConnection, ConsistentHash:
+// validateProtocol checks the protocol is known and fits the URL: h2
+// needs TLS and h2c must not use it.
+func (bc BackendConfig) validateProtocol() error {
+	u, _ := url.Parse(bc.Url)
+	secure := u.Scheme == "https" || u.Scheme == "dns+srv+https"
+	switch bc.Protocol {
+	case "", HTTP1:
+	case H2:
+		if !secure {
+			return fmt.Errorf("protocol h2 needs an https backend, use h2c for http")
+		}
+	case H2C:
+		if secure {
+			return fmt.Errorf("protocol h2c needs an http backend, use h2 for https")
+		}
 	default:
-		return fmt.Errorf("unrecognized load balancing strategy: %s", c.LoadBalancing.Strategy)
+		return fmt.Errorf("unrecognized protocol: %s", bc.Protocol)
 	}
+	return nil
+}
 
-	hc := c.LoadBalancing.HealthCheck
+// NormalizeURL returns the canonical form of a backend URL used to detect
+// duplicates: scheme and host lower-cased, the scheme's default port
+// dropped, and any trailing slash removed.
+func NormalizeURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		port = ""
+	}
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	return scheme + "://" + host + strings.TrimRight(u.EscapedPath(), "/"), nil
+}
+
+// validateTCPListeners checks every tcp listener names a known backend
+// group of tcp:// backends, and returns the names of those groups.
+func (c *Config) validateTCPListeners() (map[string]bool, error) {
+	tcpGroups := make(map[string]bool)
+	for i, l := range c.Server.Listeners {
+		switch l.Mode {
+		case "", HTTPListener:
+			if l.Group != "" {
+				return nil, fmt.Errorf("listeners[%d]: group is only used in tcp mode", i)
+			}
+			continue
+		case TCPListener:
+		default:
+			return nil, fmt.Errorf("listeners[%d]: unrecognized mode: %s", i, l.Mode)
+		}
+		if l.TLS.Enabled {
+			return nil, fmt.Errorf("listeners[%d]: tls is not supported in tcp mode", i)
+		}
+		if l.IdleTimeout < 0 {
+			return nil, fmt.Errorf("listeners[%d]: idle timeout cannot be negative", i)
+		}
+		idx := slices.IndexFunc(c.BackendGroups, func(g BackendGroupConfig) bool { return g.Name == l.Group })
+		if idx < 0 {
+			return nil, fmt.Errorf("listeners[%d]: unknown backend group %q", i, l.Group)
+		}
+		group := c.BackendGroups[idx]
+		for j, backend := range group.Backends {
+			u, _ := url.Parse(backend.Url)
+			if u.Scheme != "tcp" || u.Port() == "" {
+				return nil, fmt.Errorf("backend group %q: backend[%d]: tcp listener needs a tcp://host:port URL", group.Name, j)
+			}
+		}
+		tcpGroups[l.Group] = true
+	}
+	return tcpGroups, nil
+}
+
+// requirePorts checks that every backend names an explicit host:port, which
+// grpc health checks dial directly.
+func requirePorts(prefix string, backends []BackendConfig) error {
+	for i, backend := range backends {
+		u, err := url.Parse(backend.Url)
+		if err != nil {
+			return fmt.Errorf("%s[%d]: invalid URL: %w", prefix, i, err)
+		}
+		// SRV records carry the port.
+		if strings.HasPrefix(u.Scheme, "dns+srv") {
+			continue
+		}
+		if u.Hostname() == "" || u.Port() == "" {
+			return fmt.Errorf("%s[%d]: grpc health check needs host:port in the URL", prefix, i)
+		}
+	}
+	return nil
+}
+
+// valid reports whether s names a strategy registered by the algorithms
+// package; the two lists have to be kept in step.
+func (s Strategy) valid() bool {
+	switch s {
+	case RoundRobin, Weighted, LeastConnection, ConsistentHash, Random, P2C, SizeAware, LeastLatency, IPHash, WeightedLeastConnection, WeightedRandom:
+		return true
+	}
+	return false
+}
+
+// validHashKey reports whether key is empty or names something
+// consistent_hash can hash.
+func validHashKey(key string) bool {
+	kind, name, hasName := strings.Cut(key, ":")
+	switch kind {
+	case "", "path", "uri", "host", "client_ip":
+		return !hasName
+	case "header", "cookie", "query":
+		return name != ""
+	}
+	return false
+}
+
+func (s Strategy) weighted() bool {
+	switch s {
+	case Weighted, WeightedLeastConnection, WeightedRandom:
+		return true
+	}
+	return false
+}
+
+// ValidateBackend checks a backend added at runtime as Validate checks
+// the entries of the backends list.
+func (c *Config) ValidateBackend(bc BackendConfig) error {
+	if err := validateBackends("backend", []BackendConfig{bc}); err != nil {
+		return err
+	}
+	return validateHealthOverrides("backend", []BackendConfig{bc}, c.LoadBalancing.HealthCheck)
+}
+
+// requireWeight checks that a weighted strategy has a backend to choose:
+// backends with weight 0 are left out of weighted selection, so at least
+// one of them needs a positive weight.
+func (c *Config) requireWeight(name string, backends []BackendConfig) error {
+	if !c.weighted() || len(backends) == 0 {
+		return nil
+	}
+	if !slices.ContainsFunc(backends, func(b BackendConfig) bool { return b.EffectiveWeight() > 0 }) {
+		return fmt.Errorf("%s: at least one backend needs a positive weight with a weighted strategy", name)
+	}
+	return nil
+}
+
+// weighted reports whether any strategy in use, default or per route,
+// balances by weight.
+func (c *Config) weighted() bool {
+	if c.LoadBalancing.Strategy.weighted() {
+		return true
+	}
+	for _, route := range c.LoadBalancing.Routes {
+		if route.Strategy.weighted() {
+			return true
+		}
+	}
+	return false
+}
+
+// Configured reports whether a maintenance page or backend was given;
+// without one a plain 503 is served.
+func (m MaintenanceConfig) Configured() bool {
+	return m.BackendURL != "" || m.Body != "" || m.BodyFile != ""
+}
+
+func (m MaintenanceConfig) validate(name string) error {
+	if m.BackendURL != "" {
+		u, err := url.Parse(m.BackendURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%s backend_url must be an absolute URL", name)
+		}
+		if m.Timeout <= 0 {
+			return fmt.Errorf("%s timeout must be positive with a backend_url", name)
+		}
+		return nil
+	}
+	if m.Body == "" && m.BodyFile == "" {
+		return fmt.Errorf("%s needs a backend_url, body or body_file when enabled", name)
+	}
+	if m.Body != "" && m.BodyFile != "" {
+		return fmt.Errorf("%s body and body_file are mutually exclusive", name)
+	}
+	if m.BodyFile != "" {
+		if _, err := os.Stat(m.BodyFile); err != nil {
+			return fmt.Errorf("%s body_file: %w", name, err)
+		}
+	}
+	if m.Status < 100 || m.Status > 599 {
+		return fmt.Errorf("%s status must be a valid HTTP status code", name)
+	}
+	return nil
+}
+
+func (t UpstreamTLSConfig) validate() error {
+	if t.CAFile == "" {
+		return nil
+	}
+	if _, err := os.ReadFile(t.CAFile); err != nil {
+		return fmt.Errorf("upstream tls ca_file not readable: %w", err)
+	}
+	return nil
+}
+
+// CipherSuiteIDs maps CipherSuites to their IDs. Only suites Go considers
+// secure are accepted.
+func (t TLSConfig) CipherSuiteIDs() ([]uint16, error) {
+	if len(t.CipherSuites) == 0 {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, cs := range tls.CipherSuites() {
+		known[cs.Name] = cs.ID
+	}
+	ids := make([]uint16, 0, len(t.CipherSuites))
+	for _, name := range t.CipherSuites {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unrecognized or insecure tls cipher suite: %s", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// servesTLS reports whether any http listener terminates TLS.
+func (sc ServerConfig) servesTLS() bool {
+	if len(sc.Listeners) == 0 {
+		return sc.TLS.Enabled
+	}
+	for _, l := range sc.Listeners {
+		if l.TLS.Enabled && !l.TCP() {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks an enabled TLS block. With acme the certificate comes
+// from the CA, so cert_file and key_file are not needed.
+func (t TLSConfig) validate(acme bool) error {
+	if !acme {
+		if t.CertFile == "" || t.KeyFile == "" {
+			return fmt.Errorf("tls cert_file and key_file must be set when tls is enabled")
+		}
+		for _, f := range []string{t.CertFile, t.KeyFile} {
+			file, err := os.Open(f)
+			if err != nil {
+				return fmt.Errorf("tls file not readable: %w", err)
+			}
+			file.Close()
+		}
+	}
+	switch t.MinVersion {
+	case "", "1.0", "1.1", "1.2", "1.3":
+	default:
+		return fmt.Errorf("unrecognized tls min_version: %s", t.MinVersion)
+	}
+	if _, err := t.CipherSuiteIDs(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateTiming checks the probe interval, timeout and thresholds, which
+// per-backend overrides may replace.
+func (hc HealthCheckConfig) validateTiming() error {
 	if hc.Interval <= 0 {
 		return fmt.Errorf("health check interval must be positive")
 	}
@@ -45,19 +743,32 @@ func (c *Config) Validate() error {
 	if hc.Timeout >= hc.Interval {
 		return fmt.Errorf("health check timeout must be less than interval")
 	}
+	if hc.MaxLatency < 0 || (hc.MaxLatency > 0 && hc.MaxLatency >= hc.Timeout) {
+		return fmt.Errorf("health check max latency must be less than timeout")
+	}
 	if hc.UnhealthyThreshold == 0 {
 		return fmt.Errorf("unhealthy threshold must be positive")
 	}
 	if hc.HealthyThreshold == 0 {
 		return fmt.Errorf("healthy threshold must be positive")
 	}
+	return nil
+}
 
-	rl := c.Middlewares.RateLimiter
-	if rl.Enabled {
-		if rl.Rate == 0 {
-			return fmt.Errorf("rate limiter refill rate must be positive when enabled")
+// validateHealthOverrides checks each backend's health check override as
+// merged over the global settings.
+func validateHealthOverrides(prefix string, backends []BackendConfig, hc HealthCheckConfig) error {
+	for _, b := range backends {
+		o := b.HealthCheck
+		if o == nil {
+			continue
+		}
+		if o.Interval < 0 || o.Timeout < 0 {
+			return fmt.Errorf("%s %s: health check interval and timeout must not be negative", prefix, b.Url)
+		}
+		if err := o.Apply(hc).validateTiming(); err != nil {
+			return fmt.Errorf("%s %s: %w", prefix, b.Url, err)
 		}
 	}
-
 	return nil
 }
@@ -0,0 +1,416 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// baseYAML is the smallest config that passes Validate.
+const baseYAML = `
+server:
+  port: 8080
+  read_timeout: 10s
+  write_timeout: 10s
+backends:
+  - url: http://127.0.0.1:8081
+    timeout: 5s
+load_balancing:
+  strategy: round_robin
+  health_check:
+    interval: 15s
+    timeout: 5s
+    unhealthy_threshold: 3
+    healthy_threshold: 2
+`
+
+func baseConfig(t *testing.T) *Config {
+	t.Helper()
+	c, err := Parse([]byte(baseYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("base config is invalid: %v", err)
+	}
+	return c
+}
+
+func weight(w uint) *uint { return &w }
+
+type validateCase struct {
+	name    string
+	mutate  func(c *Config)
+	wantErr string
+}
+
+func runValidate(t *testing.T, cases []validateCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := baseConfig(t)
+			tc.mutate(c)
+			err := c.Validate()
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tc.wantErr != "" && err == nil:
+				t.Fatalf("expected an error containing %q", tc.wantErr)
+			case tc.wantErr != "" && !strings.Contains(err.Error(), tc.wantErr):
+				t.Fatalf("error %q does not contain %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateWeights(t *testing.T) {
+	second := BackendConfig{Url: "http://127.0.0.1:8082", Timeout: 5 * time.Second}
+	runValidate(t, []validateCase{
+		{"omitted weight", func(c *Config) { c.LoadBalancing.Strategy = Weighted }, ""},
+		{"zero weight excluded beside a positive one", func(c *Config) {
+			c.LoadBalancing.Strategy = Weighted
+			c.Backends[0].Weight = weight(0)
+			c.Backends = append(c.Backends, second)
+		}, ""},
+		{"every weight zero", func(c *Config) {
+			c.LoadBalancing.Strategy = WeightedLeastConnection
+			c.Backends[0].Weight = weight(0)
+		}, "backends: at least one backend needs a positive weight"},
+		{"every weight zero in a group", func(c *Config) {
+			c.LoadBalancing.Strategy = WeightedRandom
+			c.BackendGroups = []BackendGroupConfig{{Name: "api", Backends: []BackendConfig{{Url: "http://127.0.0.1:9000", Timeout: 5 * time.Second, Weight: weight(0)}}}}
+		}, `backend group "api": at least one backend needs a positive weight`},
+		{"zero weight without a weighted strategy", func(c *Config) { c.Backends[0].Weight = weight(0) }, ""},
+		{"weighted route strategy", func(c *Config) {
+			c.Backends[0].Weight = weight(0)
+			c.LoadBalancing.Routes = []RouteConfig{{PathPrefix: "/api/", Strategy: Weighted}}
+		}, "at least one backend needs a positive weight"},
+	})
+}
+
+func TestEffectiveWeight(t *testing.T) {
+	c, err := Parse([]byte(`
+backends:
+  - url: http://a:1
+  - url: http://b:1
+    weight: 0
+  - url: http://c:1
+    weight: 3
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{1, 0, 3}
+	for i, b := range c.Backends {
+		if got := b.EffectiveWeight(); got != want[i] {
+			t.Errorf("%s: weight %d, want %d", b.Url, got, want[i])
+		}
+	}
+}
+
+func TestNegativeWeightRejected(t *testing.T) {
+	if _, err := Parse([]byte("backends:\n  - url: http://a:1\n    weight: -1\n")); err == nil {
+		t.Fatal("a negative weight parsed")
+	}
+}
+
+func TestWeightChangeToZero(t *testing.T) {
+	prev := []BackendConfig{{Url: "http://a:1"}, {Url: "http://b:1", Weight: weight(2)}}
+	curr := []BackendConfig{{Url: "http://a:1", Weight: weight(0)}, {Url: "http://b:1", Weight: weight(2)}}
+	got := diffWeights(curr, prev)
+	if len(got) != 1 || got["http://a:1"] != 0 {
+		t.Fatalf("diffWeights = %v, want a:1 reweighted to 0", got)
+	}
+	if got := diffWeights([]BackendConfig{{Url: "http://a:1", Weight: weight(1)}}, prev[:1]); got != nil {
+		t.Errorf("an explicit weight of 1 differs from an omitted one: %v", got)
+	}
+}
+
+func TestValidateTLS(t *testing.T) {
+	dir := t.TempDir()
+	cert := filepath.Join(dir, "cert.pem")
+	if err := os.WriteFile(cert, []byte("placeholder"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.pem")
+
+	runValidate(t, []validateCase{
+		{"readable files", func(c *Config) {
+			c.Server.TLS = TLSConfig{Enabled: true, CertFile: cert, KeyFile: cert, MinVersion: "1.3"}
+		}, ""},
+		{"no key file", func(c *Config) {
+			c.Server.TLS = TLSConfig{Enabled: true, CertFile: cert}
+		}, "cert_file and key_file must be set"},
+		{"unreadable file", func(c *Config) {
+			c.Server.TLS = TLSConfig{Enabled: true, CertFile: cert, KeyFile: missing}
+		}, "tls file not readable"},
+		{"unknown min version", func(c *Config) {
+			c.Server.TLS = TLSConfig{Enabled: true, CertFile: cert, KeyFile: cert, MinVersion: "2.0"}
+		}, "unrecognized tls min_version"},
+		{"disabled ignores files", func(c *Config) {
+			c.Server.TLS = TLSConfig{CertFile: missing}
+		}, ""},
+		{"upstream ca readable", func(c *Config) {
+			c.Backends[0].TLS = &UpstreamTLSConfig{CAFile: cert}
+		}, ""},
+		{"backend ca unreadable", func(c *Config) {
+			c.Backends[0].TLS = &UpstreamTLSConfig{CAFile: missing}
+		}, "backend[0]: upstream tls ca_file not readable"},
+		{"proxy ca unreadable", func(c *Config) {
+			c.Proxy.UpstreamTLS = UpstreamTLSConfig{CAFile: missing}
+		}, "upstream tls ca_file not readable"},
+	})
+}
+
+func TestValidateHealthCheckType(t *testing.T) {
+	runValidate(t, []validateCase{
+		{"tcp", func(c *Config) { c.LoadBalancing.HealthCheck.Type = TCPHealthCheck }, ""},
+		{"http", func(c *Config) { c.LoadBalancing.HealthCheck.Type = HTTPHealthCheck }, ""},
+		{"grpc", func(c *Config) { c.LoadBalancing.HealthCheck.Type = GRPCHealthCheck }, ""},
+		{"grpc without a port", func(c *Config) {
+			c.LoadBalancing.HealthCheck.Type = GRPCHealthCheck
+			c.Backends[0].Url = "http://127.0.0.1"
+		}, "backend[0]: grpc health check needs host:port in the URL"},
+		{"unknown", func(c *Config) { c.LoadBalancing.HealthCheck.Type = "icmp" }, "unrecognized health check type: icmp"},
+	})
+}
+
+func TestValidateMaxLatency(t *testing.T) {
+	maxLatency := func(d time.Duration) func(c *Config) {
+		return func(c *Config) { c.LoadBalancing.HealthCheck.MaxLatency = d }
+	}
+	runValidate(t, []validateCase{
+		{"unset", maxLatency(0), ""},
+		{"under timeout", maxLatency(time.Second), ""},
+		{"equal to timeout", maxLatency(5 * time.Second), "health check max latency must be less than timeout"},
+		{"over timeout", maxLatency(6 * time.Second), "health check max latency must be less than timeout"},
+		{"negative", maxLatency(-time.Second), "health check max latency must be less than timeout"},
+	})
+}
+
+func TestValidateHealthOverrides(t *testing.T) {
+	override := func(o HealthCheckOverride) func(c *Config) {
+		return func(c *Config) { c.Backends[0].HealthCheck = &o }
+	}
+	runValidate(t, []validateCase{
+		{"thresholds", override(HealthCheckOverride{UnhealthyThreshold: 10, HealthyThreshold: 5}), ""},
+		{"timing", override(HealthCheckOverride{Interval: time.Minute, Timeout: 10 * time.Second}), ""},
+		{"negative interval", override(HealthCheckOverride{Interval: -time.Second}), "health check interval and timeout must not be negative"},
+		// Merged with the global 15s interval, a 20s timeout is too long.
+		{"timeout over the global interval", override(HealthCheckOverride{Timeout: 20 * time.Second}), "backend http://127.0.0.1:8081: health check timeout must be less than interval"},
+		{"group backend", func(c *Config) {
+			c.BackendGroups = []BackendGroupConfig{{Name: "api", Backends: []BackendConfig{{
+				Url:         "http://127.0.0.1:9000",
+				Timeout:     5 * time.Second,
+				HealthCheck: &HealthCheckOverride{Interval: time.Second},
+			}}}}
+		}, `backend group "api": backend http://127.0.0.1:9000: health check timeout must be less than interval`},
+	})
+}
+
+func TestValidateCompressionLevel(t *testing.T) {
+	runValidate(t, []validateCase{
+		{"default", func(c *Config) {}, ""},
+		{"best speed", func(c *Config) { c.Middlewares.Compression.Level = 1 }, ""},
+		{"best compression", func(c *Config) { c.Middlewares.Compression.Level = 9 }, ""},
+		{"too high", func(c *Config) { c.Middlewares.Compression.Level = 10 }, "compression level must be between 1 and 9"},
+		{"negative", func(c *Config) { c.Middlewares.Compression.Level = -1 }, "compression level must be between 1 and 9"},
+	})
+}
+
+func TestValidateMaxAttempts(t *testing.T) {
+	if c := baseConfig(t); c.Proxy.MaxAttempts != 3 {
+		t.Errorf("default max attempts = %d, want 3", c.Proxy.MaxAttempts)
+	}
+	runValidate(t, []validateCase{
+		{"one", func(c *Config) { c.Proxy.MaxAttempts = 1 }, ""},
+		{"zero", func(c *Config) { c.Proxy.MaxAttempts = 0 }, "proxy max attempts must be at least 1"},
+		{"negative", func(c *Config) { c.Proxy.MaxAttempts = -2 }, "proxy max attempts must be at least 1"},
+	})
+}
+
+func TestValidateStickySession(t *testing.T) {
+	sticky := func(name string, ttl time.Duration) func(c *Config) {
+		return func(c *Config) {
+			c.LoadBalancing.StickySession = StickySessionConfig{Enabled: true, CookieName: name, TTL: ttl}
+		}
+	}
+	runValidate(t, []validateCase{
+		{"valid", sticky("lb_pin", time.Hour), ""},
+		{"no cookie name", sticky("", time.Hour), "sticky session cookie_name must be set"},
+		{"zero ttl", sticky("lb_pin", 0), "sticky session ttl must be positive"},
+		{"negative ttl", sticky("lb_pin", -time.Second), "sticky session ttl must be positive"},
+		{"disabled", func(c *Config) { c.LoadBalancing.StickySession = StickySessionConfig{} }, ""},
+	})
+}
+
+func TestValidateRateLimiter(t *testing.T) {
+	limiter := func(size uint, rate float64) func(c *Config) {
+		return func(c *Config) {
+			c.Middlewares.RateLimiter.Enabled = true
+			c.Middlewares.RateLimiter.Size = size
+			c.Middlewares.RateLimiter.Rate = rate
+		}
+	}
+	runValidate(t, []validateCase{
+		{"valid", limiter(10, 1), ""},
+		{"zero size", limiter(0, 1), "rate limiter size must be positive"},
+		{"zero rate", limiter(10, 0), "rate limiter refill rate must be positive"},
+		{"disabled with zero size", func(c *Config) { c.Middlewares.RateLimiter.Size = 0 }, ""},
+	})
+}
+
+func TestValidateRateLimiterOverrides(t *testing.T) {
+	override := func(key string, tier RateLimitTier) func(c *Config) {
+		return func(c *Config) {
+			c.Middlewares.RateLimiter.Enabled = true
+			c.Middlewares.RateLimiter.Size = 10
+			c.Middlewares.RateLimiter.Rate = 1
+			c.Middlewares.RateLimiter.Overrides = map[string]RateLimitTier{key: tier}
+		}
+	}
+	runValidate(t, []validateCase{
+		{"valid", override("premium-key", RateLimitTier{Rate: 100, Size: 200}), ""},
+		{"empty key", override("", RateLimitTier{Rate: 1, Size: 1}), "rate limiter override key cannot be empty"},
+		{"zero size", override("premium-key", RateLimitTier{Rate: 1}), "rate and size must be positive"},
+		{"zero rate", override("premium-key", RateLimitTier{Size: 1}), "rate and size must be positive"},
+		{"key is truncated", override("premium-key", RateLimitTier{}), "override for key prem...:"},
+	})
+}
+
+func TestValidateStrategy(t *testing.T) {
+	strategy := func(s Strategy) func(c *Config) {
+		return func(c *Config) { c.LoadBalancing.Strategy = s }
+	}
+	runValidate(t, []validateCase{
+		{"least_latency", strategy(LeastLatency), ""},
+		{"unknown", strategy("fastest"), "unrecognized load balancing strategy: fastest"},
+		{"unknown on a route", func(c *Config) {
+			c.LoadBalancing.Routes = []RouteConfig{{PathPrefix: "/api/", Strategy: "fastest"}}
+		}, "route[0]: unrecognized load balancing strategy: fastest"},
+	})
+}
+
+func TestValidateRoutes(t *testing.T) {
+	group := BackendGroupConfig{Name: "api", Backends: []BackendConfig{{Url: "http://127.0.0.1:9000", Timeout: 5 * time.Second}}}
+	routes := func(routes ...RouteConfig) func(c *Config) {
+		return func(c *Config) {
+			c.BackendGroups = []BackendGroupConfig{group}
+			c.LoadBalancing.Routes = routes
+		}
+	}
+	runValidate(t, []validateCase{
+		{"host and path", routes(RouteConfig{Host: "api.example.com", Group: "api"}, RouteConfig{PathPrefix: "/v2/", Group: "api"}), ""},
+		{"default group", routes(RouteConfig{PathPrefix: "/v2/"}), ""},
+		{"no match", routes(RouteConfig{Group: "api"}), "route[0]: host or path prefix must be set"},
+		{"relative prefix", routes(RouteConfig{PathPrefix: "v2/", Group: "api"}), "route[0]: path prefix must start with /"},
+		{"unknown group", routes(RouteConfig{PathPrefix: "/v2/", Group: "web"}), `route[0]: unknown backend group "web"`},
+	})
+}
+
+func TestValidatePathRewrite(t *testing.T) {
+	rewrite := func(pr PathRewriteConfig) func(c *Config) {
+		return func(c *Config) {
+			c.LoadBalancing.Routes = []RouteConfig{{PathPrefix: "/api/", PathRewrite: &pr}}
+		}
+	}
+	runValidate(t, []validateCase{
+		{"strip", rewrite(PathRewriteConfig{StripPrefix: "/api"}), ""},
+		{"replace", rewrite(PathRewriteConfig{StripPrefix: "/api", Replacement: "/v2"}), ""},
+		{"relative strip prefix", rewrite(PathRewriteConfig{StripPrefix: "api"}), "route[0]: path rewrite strip prefix must start with /"},
+		{"relative replacement", rewrite(PathRewriteConfig{StripPrefix: "/api", Replacement: "v2"}), "route[0]: path rewrite replacement must start with /"},
+	})
+}
+
+func TestValidateProbeConcurrency(t *testing.T) {
+	if c := baseConfig(t); c.LoadBalancing.HealthCheck.MaxConcurrent != 32 {
+		t.Errorf("default max concurrent probes = %d, want 32", c.LoadBalancing.HealthCheck.MaxConcurrent)
+	}
+	runValidate(t, []validateCase{
+		{"one", func(c *Config) { c.LoadBalancing.HealthCheck.MaxConcurrent = 1 }, ""},
+		{"zero", func(c *Config) { c.LoadBalancing.HealthCheck.MaxConcurrent = 0 }, "health check max concurrent must be at least 1"},
+	})
+}
+
+func TestValidateDiscovery(t *testing.T) {
+	runValidate(t, []validateCase{
+		{"a records", func(c *Config) { c.Backends[0].Discover = true }, ""},
+		{"srv", func(c *Config) { c.Backends[0].Url = "dns+srv://_http._tcp.example.internal" }, ""},
+		{"srv over https", func(c *Config) { c.Backends[0].Url = "dns+srv+https://_http._tcp.example.internal" }, ""},
+		{"srv with an unknown scheme", func(c *Config) {
+			c.Backends[0].Url = "dns+srv+ftp://_http._tcp.example.internal"
+		}, "backend[0]: srv discovery scheme must be dns+srv or dns+srv+https"},
+		{"negative interval", func(c *Config) { c.LoadBalancing.DiscoveryInterval = -time.Second }, "discovery interval cannot be negative"},
+	})
+}
+
+func TestValidateHostHeader(t *testing.T) {
+	runValidate(t, []validateCase{
+		{"preserve", func(c *Config) { c.Backends[0].PreserveHost = true }, ""},
+		{"override", func(c *Config) { c.Backends[0].Host = "api.internal" }, ""},
+		{"both", func(c *Config) {
+			c.Backends[0].PreserveHost = true
+			c.Backends[0].Host = "api.internal"
+		}, "backend[0]: preserve_host and host cannot both be set"},
+	})
+}
+
+func TestValidateDuplicateBackends(t *testing.T) {
+	withSecond := func(url string) func(c *Config) {
+		return func(c *Config) {
+			c.Backends = append(c.Backends, BackendConfig{Url: url, Timeout: c.Backends[0].Timeout})
+		}
+	}
+	runValidate(t, []validateCase{
+		{"distinct", withSecond("http://127.0.0.1:8082"), ""},
+		{"exact", withSecond("http://127.0.0.1:8081"), "backend[1]: duplicate backend URL http://127.0.0.1:8081 (same as http://127.0.0.1:8081)"},
+		{"trailing slash", withSecond("http://127.0.0.1:8081/"), "backend[1]: duplicate backend URL http://127.0.0.1:8081/"},
+		{"upper case", withSecond("HTTP://127.0.0.1:8081"), "backend[1]: duplicate backend URL HTTP://127.0.0.1:8081"},
+		{"other scheme", withSecond("https://127.0.0.1:8081"), ""},
+	})
+}
+
+func TestNormalizeURL(t *testing.T) {
+	for _, tc := range []struct{ a, b string }{
+		{"http://a:80", "http://a"},
+		{"https://a:443/", "https://a"},
+		{"HTTP://A.example.com/api/", "http://a.example.com/api"},
+		{"http://[::1]:80", "http://[::1]"},
+	} {
+		na, err := NormalizeURL(tc.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nb, err := NormalizeURL(tc.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if na != nb {
+			t.Errorf("%s normalizes to %s but %s to %s", tc.a, na, tc.b, nb)
+		}
+	}
+	for _, tc := range []struct{ a, b string }{
+		{"http://a:8080", "http://a"},
+		{"http://a/api", "http://a/other"},
+	} {
+		na, _ := NormalizeURL(tc.a)
+		nb, _ := NormalizeURL(tc.b)
+		if na == nb {
+			t.Errorf("%s and %s both normalize to %s", tc.a, tc.b, na)
+		}
+	}
+}
+
+func TestValidateServerTimeouts(t *testing.T) {
+	runValidate(t, []validateCase{
+		{"go defaults", func(c *Config) {}, ""},
+		{"set", func(c *Config) {
+			c.Server.ReadHeaderTimeout = 2 * time.Second
+			c.Server.IdleTimeout = time.Minute
+		}, ""},
+		{"negative read header", func(c *Config) { c.Server.ReadHeaderTimeout = -time.Second }, "read header timeout cannot be negative"},
+		{"negative idle", func(c *Config) { c.Server.IdleTimeout = -time.Second }, "idle timeout cannot be negative"},
+	})
+}
@@ -2,45 +2,97 @@ package config
 
 import (
 	"fmt"
+	"maps"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/logger"
 )
 
+const DefaultDebounce = 2 * time.Second
+
 type Watcher struct {
-	watcher  *fsnotify.Watcher
-	stopChan chan struct{}
-	once     sync.Once
-	path     string
-	config   *Config
+	watcher   *fsnotify.Watcher
+	stopChan  chan struct{}
+	once      sync.Once
+	path      string
+	config    *Config
+	debounce  time.Duration
+	overrides func(*Config)
+	mux       sync.Mutex
+	wg        sync.WaitGroup
 }
 
+// BackendChange describes a reloaded config relative to the last committed
+// one. Config is the full reloaded config; it only becomes the baseline for
+// future diffs once the receiver calls Watcher.Commit.
 type BackendChange struct {
 	Added   []string
 	Removed []string
+	// StrategyChanged reports that load_balancing.strategy or hash_key
+	// differs; the new values are in Config.LoadBalancing.
+	StrategyChanged bool
+	// Reweighted maps backends present in both configs whose weight
+	// changed to their new weight.
+	Reweighted map[string]int
+	// RateLimiterChanged reports that middlewares.rate_limiter differs.
+	RateLimiterChanged bool
+	// Groups holds the backend changes of every backend group, including
+	// a route's inline backends, present in both configs.
+	Groups map[string]GroupChange
+	// RestartRequired lists changes to groups and routes a running load
+	// balancer cannot apply, such as a group being added or a route's
+	// match changing.
+	RestartRequired []string
+	Config          *Config
+}
+
+// GroupChange is the part of a reload that applies to one backend group.
+type GroupChange struct {
+	Added      []string
+	Removed    []string
+	Reweighted map[string]int
 }
 
-func NewWatcher(path string, config *Config) *Watcher {
+func NewWatcher(path string, config *Config, debounce time.Duration) *Watcher {
 	if path == "" {
 		path = "configs/config.yml"
 	}
-	return &Watcher{stopChan: make(chan struct{}), path: path, config: config}
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+	return &Watcher{stopChan: make(chan struct{}), path: path, config: config, debounce: debounce}
+}
+
+// SetOverrides registers fn to adjust every reloaded config before it is
+// validated, so settings given outside the file survive a reload. It must be
+// called before Start.
+func (w *Watcher) SetOverrides(fn func(*Config)) {
+	w.overrides = fn
 }
 
 func (w *Watcher) Start(changeChan chan BackendChange) {
 	var err error
 	w.watcher, err = fsnotify.NewWatcher()
 	if err != nil {
-		fmt.Printf("Error starting watcher: %v\n", err)
+		logger.Error("failed to start config watcher", "error", err)
 		return
 	}
 
-	const debounce = 30 * time.Second
+	absPath, err := filepath.Abs(w.path)
+	if err != nil {
+		absPath = w.path
+	}
+
 	var timer *time.Timer
 
+	w.wg.Add(1)
 	go func() {
+		defer w.wg.Done()
 		defer func() {
 			if w.watcher != nil {
 				_ = w.watcher.Close()
@@ -59,9 +111,18 @@ func (w *Watcher) Start(changeChan chan BackendChange) {
 					return
 				}
 
-				if event.Op&fsnotify.Write == fsnotify.Write {
+				// Editors and deploy tools that save by renaming a new file
+				// over the old one take the watched inode away with it, so
+				// the watch has to be put back on whatever is at the path now.
+				if event.Op&(fsnotify.Rename|fsnotify.Remove) != 0 {
+					if !w.rewatch(absPath) {
+						return
+					}
+				}
+
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 {
 					if timer == nil {
-						timer = time.NewTimer(debounce)
+						timer = time.NewTimer(w.debounce)
 					} else {
 						if !timer.Stop() {
 							select {
@@ -69,24 +130,30 @@ func (w *Watcher) Start(changeChan chan BackendChange) {
 							default:
 							}
 						}
-						timer.Reset(debounce)
+						timer.Reset(w.debounce)
 					}
 				}
 			case err, ok := <-w.watcher.Errors:
 				if !ok {
 					return
 				}
-				fmt.Println("error:", err)
+				logger.Error("config watcher error", "error", err)
 			case <-timerC:
-				c, _ := Load(w.path)
-				added, removed := CheckIfBackendChanged(c, w.config)
-				if len(added) > 0 || len(removed) > 0 {
-					changeChan <- BackendChange{Added: added, Removed: removed}
-					w.config.Backends = c.Backends
-				}
 				timer = nil
+				ev, changed, err := w.Reload()
+				if err != nil {
+					logger.Error("config reload failed, keeping current config", "path", w.path, "error", err)
+					continue
+				}
+				if changed {
+					select {
+					case changeChan <- ev:
+					case <-w.stopChan:
+						return
+					}
+				}
 			case <-w.stopChan:
-				fmt.Println("Watcher stopped")
+				logger.Info("config watcher stopped")
 				if timer != nil {
 					_ = timer.Stop()
 					timer = nil
@@ -96,17 +163,85 @@ func (w *Watcher) Start(changeChan chan BackendChange) {
 		}
 	}()
 
-	absPath, err := filepath.Abs(w.path)
-	if err != nil {
-		absPath = w.path
-	}
-
 	if err = w.watcher.Add(absPath); err != nil {
-		fmt.Printf("Error adding watch path %s: %v\n", absPath, err)
+		logger.Error("failed to watch config file", "path", absPath, "error", err)
 		return
 	}
 }
 
+// rewatchAttempts and rewatchDelay bound how long rewatch waits for a
+// replaced config file to reappear.
+const (
+	rewatchAttempts = 20
+	rewatchDelay    = 50 * time.Millisecond
+)
+
+// rewatch re-adds the watch on path after the file was renamed or removed,
+// retrying while the replacement is not there yet. It returns false only
+// when the watcher is stopped meanwhile.
+func (w *Watcher) rewatch(path string) bool {
+	_ = w.watcher.Remove(path)
+	for attempt := 0; attempt < rewatchAttempts; attempt++ {
+		if err := w.watcher.Add(path); err == nil {
+			return true
+		}
+		select {
+		case <-time.After(rewatchDelay):
+		case <-w.stopChan:
+			return false
+		}
+	}
+	logger.Error("config file is gone, no longer watching it", "path", path)
+	return true
+}
+
+// Reload reads and validates the config file and diffs it against the last
+// committed config. changed is false when nothing the running load balancer
+// can apply differs. The file watcher calls it after each debounced change;
+// it may also be called directly, e.g. on SIGHUP, when the file is swapped
+// in ways fsnotify does not report.
+func (w *Watcher) Reload() (ev BackendChange, changed bool, err error) {
+	c, err := Load(w.path)
+	if err != nil {
+		return BackendChange{}, false, err
+	}
+	if w.overrides != nil {
+		w.overrides(c)
+	}
+	if err := c.Validate(); err != nil {
+		return BackendChange{}, false, fmt.Errorf("invalid configuration: %w", err)
+	}
+	prev := w.committed()
+	ev = BackendChange{
+		StrategyChanged:    CheckIfStrategyChanged(c, prev),
+		Reweighted:         CheckIfWeightChanged(c, prev),
+		RateLimiterChanged: CheckIfRateLimiterChanged(c, prev),
+		Config:             c,
+	}
+	ev.Added, ev.Removed = CheckIfBackendChanged(c, prev)
+	ev.Groups, ev.RestartRequired = CheckIfGroupsChanged(c, prev)
+	changed = len(ev.Added) > 0 || len(ev.Removed) > 0 || ev.StrategyChanged || len(ev.Reweighted) > 0 ||
+		ev.RateLimiterChanged || len(ev.Groups) > 0 || len(ev.RestartRequired) > 0
+	return ev, changed, nil
+}
+
+// Commit records c as successfully applied so that later reloads are diffed
+// against it. Changes that are never committed are reported again on the
+// next reload.
+func (w *Watcher) Commit(c *Config) {
+	w.mux.Lock()
+	w.config = c
+	w.mux.Unlock()
+}
+
+func (w *Watcher) committed() *Config {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return w.config
+}
+
+// Stop ends the watch loop and waits for it to exit. It is safe to call
+// more than once.
 func (w *Watcher) Stop() {
 	w.once.Do(func() {
 		close(w.stopChan)
@@ -114,20 +249,24 @@ func (w *Watcher) Stop() {
 			_ = w.watcher.Close()
 		}
 	})
+	w.wg.Wait()
 }
 
 func CheckIfBackendChanged(c *Config, prevConfig *Config) (added []string, removed []string) {
 	if prevConfig == nil {
 		return nil, nil
 	}
+	return diffBackends(c.Backends, prevConfig.Backends)
+}
 
+func diffBackends(curr, prev []BackendConfig) (added []string, removed []string) {
 	prevMap := make(map[string]struct{})
-	for _, b := range prevConfig.Backends {
+	for _, b := range prev {
 		prevMap[b.Url] = struct{}{}
 	}
 
 	currMap := make(map[string]struct{})
-	for _, b := range c.Backends {
+	for _, b := range curr {
 		currMap[b.Url] = struct{}{}
 	}
 
@@ -145,3 +284,97 @@ func CheckIfBackendChanged(c *Config, prevConfig *Config) (added []string, remov
 
 	return added, removed
 }
+
+// CheckIfWeightChanged returns the new weight of every backend whose weight
+// differs between prevConfig and c. Added and removed backends are left to
+// CheckIfBackendChanged.
+func CheckIfWeightChanged(c *Config, prevConfig *Config) map[string]int {
+	if prevConfig == nil {
+		return nil
+	}
+	return diffWeights(c.Backends, prevConfig.Backends)
+}
+
+func diffWeights(curr, prev []BackendConfig) map[string]int {
+	prevWeights := make(map[string]int, len(prev))
+	for _, b := range prev {
+		prevWeights[b.Url] = b.EffectiveWeight()
+	}
+
+	var changed map[string]int
+	for _, b := range curr {
+		if w, ok := prevWeights[b.Url]; ok && w != b.EffectiveWeight() {
+			if changed == nil {
+				changed = make(map[string]int)
+			}
+			changed[b.Url] = b.EffectiveWeight()
+		}
+	}
+	return changed
+}
+
+// CheckIfGroupsChanged diffs the backends of every group in both configs.
+// Groups that appear or disappear and routes whose settings other than their
+// inline backends differ can only be picked up on restart; they are
+// described in restart instead.
+func CheckIfGroupsChanged(c *Config, prevConfig *Config) (groups map[string]GroupChange, restart []string) {
+	if prevConfig == nil {
+		return nil, nil
+	}
+
+	prevGroups := make(map[string][]BackendConfig, len(prevConfig.BackendGroups))
+	for _, g := range prevConfig.BackendGroups {
+		prevGroups[g.Name] = g.Backends
+	}
+	for _, g := range c.BackendGroups {
+		prev, ok := prevGroups[g.Name]
+		if !ok {
+			restart = append(restart, fmt.Sprintf("backend group %q added", g.Name))
+			continue
+		}
+		delete(prevGroups, g.Name)
+
+		var gc GroupChange
+		gc.Added, gc.Removed = diffBackends(g.Backends, prev)
+		gc.Reweighted = diffWeights(g.Backends, prev)
+		if len(gc.Added) > 0 || len(gc.Removed) > 0 || len(gc.Reweighted) > 0 {
+			if groups == nil {
+				groups = make(map[string]GroupChange)
+			}
+			groups[g.Name] = gc
+		}
+	}
+	for name := range prevGroups {
+		restart = append(restart, fmt.Sprintf("backend group %q removed", name))
+	}
+	slices.Sort(restart)
+
+	if len(c.LoadBalancing.Routes) != len(prevConfig.LoadBalancing.Routes) {
+		return groups, append(restart, "routes added or removed")
+	}
+	for i, route := range c.LoadBalancing.Routes {
+		// Inline backends are diffed above, as group route[i].
+		prev := prevConfig.LoadBalancing.Routes[i]
+		route.Backends, prev.Backends = nil, nil
+		if !reflect.DeepEqual(route, prev) {
+			restart = append(restart, fmt.Sprintf("route[%d] changed", i))
+		}
+	}
+	return groups, restart
+}
+
+func CheckIfRateLimiterChanged(c *Config, prevConfig *Config) bool {
+	if prevConfig == nil {
+		return false
+	}
+	curr, prev := c.Middlewares.RateLimiter, prevConfig.Middlewares.RateLimiter
+	return curr.RateLimiterConfig != prev.RateLimiterConfig || !maps.Equal(curr.Overrides, prev.Overrides)
+}
+
+func CheckIfStrategyChanged(c *Config, prevConfig *Config) bool {
+	if prevConfig == nil {
+		return false
+	}
+	return c.LoadBalancing.Strategy != prevConfig.LoadBalancing.Strategy ||
+		c.LoadBalancing.HashKey != prevConfig.LoadBalancing.HashKey
+}
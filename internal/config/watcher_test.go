@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const addedBackend = `
+  - url: http://127.0.0.1:8082
+    timeout: 5s
+`
+
+// withBackend returns baseYAML with addedBackend appended to its backends.
+func withBackend() string {
+	return strings.Replace(baseYAML, "load_balancing:", strings.TrimPrefix(addedBackend, "\n")+"load_balancing:", 1)
+}
+
+// startWatcher writes baseYAML to a temp file and watches it with debounce.
+func startWatcher(t *testing.T, debounce time.Duration) (path string, changes chan BackendChange) {
+	t.Helper()
+	path = filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(baseYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWatcher(path, c, debounce)
+	changes = make(chan BackendChange, 1)
+	w.Start(changes)
+	t.Cleanup(w.Stop)
+	return path, changes
+}
+
+func waitChange(t *testing.T, changes chan BackendChange, within time.Duration) BackendChange {
+	t.Helper()
+	select {
+	case ev := <-changes:
+		return ev
+	case <-time.After(within):
+		t.Fatalf("no BackendChange within %v", within)
+	}
+	return BackendChange{}
+}
+
+func TestWatcherDebounce(t *testing.T) {
+	const debounce = 100 * time.Millisecond
+	path, changes := startWatcher(t, debounce)
+
+	start := time.Now()
+	if err := os.WriteFile(path, []byte(withBackend()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ev := waitChange(t, changes, debounce+2*time.Second)
+	if elapsed := time.Since(start); elapsed < debounce {
+		t.Errorf("change delivered after %v, before the %v debounce", elapsed, debounce)
+	}
+	if !slices.Equal(ev.Added, []string{"http://127.0.0.1:8082"}) || len(ev.Removed) != 0 {
+		t.Errorf("change = added %v removed %v", ev.Added, ev.Removed)
+	}
+}
+
+func TestWatcherDefaultDebounce(t *testing.T) {
+	if w := NewWatcher("", nil, 0); w.debounce != DefaultDebounce {
+		t.Errorf("debounce = %v, want %v", w.debounce, DefaultDebounce)
+	}
+}
+
+func TestValidateDebounce(t *testing.T) {
+	runValidate(t, []validateCase{
+		{"short", func(c *Config) { c.Watcher.Debounce = 50 * time.Millisecond }, ""},
+		{"negative", func(c *Config) { c.Watcher.Debounce = -time.Second }, "debounce"},
+	})
+}
+
+// replace swaps body in at path the way editors and config management do:
+// write a temp file beside it, then rename it over the original.
+func replace(t *testing.T, path, body string) {
+	t.Helper()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcherAtomicReplace(t *testing.T) {
+	path, changes := startWatcher(t, 50*time.Millisecond)
+
+	replace(t, path, withBackend())
+	ev := waitChange(t, changes, 3*time.Second)
+	if !slices.Equal(ev.Added, []string{"http://127.0.0.1:8082"}) {
+		t.Fatalf("change = added %v removed %v", ev.Added, ev.Removed)
+	}
+
+	// The watch follows the path, so a second replace is seen as well.
+	third := strings.Replace(withBackend(), "load_balancing:", "  - url: http://127.0.0.1:8083\n    timeout: 5s\nload_balancing:", 1)
+	replace(t, path, third)
+	ev = waitChange(t, changes, 3*time.Second)
+	if !slices.Contains(ev.Added, "http://127.0.0.1:8083") {
+		t.Errorf("second replace: added %v, want 8083 among them", ev.Added)
+	}
+}
@@ -0,0 +1,258 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type BackendState struct {
+	URL               string
+	ActiveConnections int64
+	Alive             bool
+	// HealthCheckDuration is how long the last health probe took, and
+	// ConsecutiveFailures how many probes in a row have failed.
+	HealthCheckDuration time.Duration
+	ConsecutiveFailures uint64
+}
+
+type backendCounters struct {
+	requests     atomic.Uint64
+	errors       atomic.Uint64
+	degraded     atomic.Uint64
+	transitions  atomic.Uint64
+	retries      atomic.Uint64
+	healthPassed atomic.Uint64
+	healthFailed atomic.Uint64
+	latency      histogram
+}
+
+// latencyBuckets are the upper bounds, in seconds, of the request latency
+// histogram.
+var latencyBuckets = [...]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// histogram counts observations per latency bucket; the last slot is +Inf.
+type histogram struct {
+	counts [len(latencyBuckets) + 1]atomic.Uint64
+	sumNs  atomic.Uint64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := sort.SearchFloat64s(latencyBuckets[:], d.Seconds())
+	h.counts[i].Add(1)
+	h.sumNs.Add(uint64(max(d, 0)))
+}
+
+var (
+	requestsTotal    atomic.Uint64
+	rateLimitedTotal atomic.Uint64
+	shedTotal        atomic.Uint64
+	failoversTotal   atomic.Uint64
+
+	backendsMux sync.RWMutex
+	backends    = make(map[string]*backendCounters)
+)
+
+func countersFor(url string) *backendCounters {
+	backendsMux.RLock()
+	bc, ok := backends[url]
+	backendsMux.RUnlock()
+	if ok {
+		return bc
+	}
+
+	backendsMux.Lock()
+	defer backendsMux.Unlock()
+	if bc, ok = backends[url]; !ok {
+		bc = &backendCounters{}
+		backends[url] = bc
+	}
+	return bc
+}
+
+func IncRequests() {
+	requestsTotal.Add(1)
+}
+
+func IncRateLimited() {
+	rateLimitedTotal.Add(1)
+}
+
+func IncShed() {
+	shedTotal.Add(1)
+}
+
+func IncBackendRequests(url string) {
+	countersFor(url).requests.Add(1)
+}
+
+func IncBackendErrors(url string) {
+	countersFor(url).errors.Add(1)
+}
+
+func IncBackendDegraded(url string) {
+	countersFor(url).degraded.Add(1)
+}
+
+func IncHealthTransitions(url string) {
+	countersFor(url).transitions.Add(1)
+}
+
+func IncFailovers() {
+	failoversTotal.Add(1)
+}
+
+func IncBackendRetries(url string) {
+	countersFor(url).retries.Add(1)
+}
+
+// IncHealthChecks counts one health probe of the backend at url by result.
+func IncHealthChecks(url string, healthy bool) {
+	if healthy {
+		countersFor(url).healthPassed.Add(1)
+	} else {
+		countersFor(url).healthFailed.Add(1)
+	}
+}
+
+// ObserveBackendLatency records how long the backend at url took to serve
+// a proxied request.
+func ObserveBackendLatency(url string, d time.Duration) {
+	countersFor(url).latency.observe(d)
+}
+
+// Totals returns the load-balancer-wide counters.
+func Totals() (requests, rateLimited, shed uint64) {
+	return requestsTotal.Load(), rateLimitedTotal.Load(), shedTotal.Load()
+}
+
+// BackendTotals returns the requests sent to and errors returned by the
+// backend at url.
+func BackendTotals(url string) (requests, errors uint64) {
+	backendsMux.RLock()
+	bc, ok := backends[url]
+	backendsMux.RUnlock()
+	if !ok {
+		return 0, 0
+	}
+	return bc.requests.Load(), bc.errors.Load()
+}
+
+// Handler serves every metric in the Prometheus text exposition format.
+// snapshot is called on each scrape to read the live per-backend gauges.
+func Handler(snapshot func() []BackendState) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		write(w, snapshot())
+	})
+}
+
+func write(w io.Writer, states []BackendState) {
+	header(w, "lb_requests_total", "counter", "Total requests received by the load balancer.")
+	fmt.Fprintf(w, "lb_requests_total %d\n", requestsTotal.Load())
+
+	header(w, "lb_rate_limited_total", "counter", "Total requests rejected by the rate limiter.")
+	fmt.Fprintf(w, "lb_rate_limited_total %d\n", rateLimitedTotal.Load())
+
+	header(w, "lb_shed_total", "counter", "Total requests rejected because max_inflight was reached.")
+	fmt.Fprintf(w, "lb_shed_total %d\n", shedTotal.Load())
+
+	header(w, "lb_failovers_total", "counter", "Total requests moved to another backend after theirs failed.")
+	fmt.Fprintf(w, "lb_failovers_total %d\n", failoversTotal.Load())
+
+	backendsMux.RLock()
+	urls := make([]string, 0, len(backends))
+	for u := range backends {
+		urls = append(urls, u)
+	}
+	backendsMux.RUnlock()
+	sort.Strings(urls)
+
+	header(w, "lb_backend_requests_total", "counter", "Total requests proxied to each backend.")
+	for _, u := range urls {
+		fmt.Fprintf(w, "lb_backend_requests_total{backend=\"%s\"} %d\n", escape(u), countersFor(u).requests.Load())
+	}
+
+	header(w, "lb_backend_errors_total", "counter", "Total proxied requests to each backend classified as errors.")
+	for _, u := range urls {
+		fmt.Fprintf(w, "lb_backend_errors_total{backend=\"%s\"} %d\n", escape(u), countersFor(u).errors.Load())
+	}
+
+	header(w, "lb_backend_degraded_total", "counter", "Total proxied requests to each backend classified as degraded.")
+	for _, u := range urls {
+		fmt.Fprintf(w, "lb_backend_degraded_total{backend=\"%s\"} %d\n", escape(u), countersFor(u).degraded.Load())
+	}
+
+	header(w, "lb_backend_health_transitions_total", "counter", "Total health state changes of each backend.")
+	for _, u := range urls {
+		fmt.Fprintf(w, "lb_backend_health_transitions_total{backend=\"%s\"} %d\n", escape(u), countersFor(u).transitions.Load())
+	}
+
+	header(w, "lb_backend_retries_total", "counter", "Total requests retried against the same backend after an error.")
+	for _, u := range urls {
+		fmt.Fprintf(w, "lb_backend_retries_total{backend=\"%s\"} %d\n", escape(u), countersFor(u).retries.Load())
+	}
+
+	header(w, "lb_backend_health_checks_total", "counter", "Total health probes of each backend by result.")
+	for _, u := range urls {
+		bc := countersFor(u)
+		fmt.Fprintf(w, "lb_backend_health_checks_total{backend=\"%s\",result=\"success\"} %d\n", escape(u), bc.healthPassed.Load())
+		fmt.Fprintf(w, "lb_backend_health_checks_total{backend=\"%s\",result=\"failure\"} %d\n", escape(u), bc.healthFailed.Load())
+	}
+
+	header(w, "lb_backend_request_duration_seconds", "histogram", "Time each backend took to serve proxied requests.")
+	for _, u := range urls {
+		writeHistogram(w, "lb_backend_request_duration_seconds", escape(u), &countersFor(u).latency)
+	}
+
+	header(w, "lb_backend_active_connections", "gauge", "Requests currently in flight to each backend.")
+	for _, s := range states {
+		fmt.Fprintf(w, "lb_backend_active_connections{backend=\"%s\"} %d\n", escape(s.URL), s.ActiveConnections)
+	}
+
+	header(w, "lb_backend_up", "gauge", "Whether each backend is currently healthy (1) or not (0).")
+	for _, s := range states {
+		up := 0
+		if s.Alive {
+			up = 1
+		}
+		fmt.Fprintf(w, "lb_backend_up{backend=\"%s\"} %d\n", escape(s.URL), up)
+	}
+
+	header(w, "lb_backend_health_check_duration_seconds", "gauge", "How long the last health probe of each backend took.")
+	for _, s := range states {
+		fmt.Fprintf(w, "lb_backend_health_check_duration_seconds{backend=\"%s\"} %g\n", escape(s.URL), s.HealthCheckDuration.Seconds())
+	}
+
+	header(w, "lb_backend_health_consecutive_failures", "gauge", "Health probes of each backend that have failed in a row.")
+	for _, s := range states {
+		fmt.Fprintf(w, "lb_backend_health_consecutive_failures{backend=\"%s\"} %d\n", escape(s.URL), s.ConsecutiveFailures)
+	}
+}
+
+func writeHistogram(w io.Writer, name, backend string, h *histogram) {
+	var cumulative uint64
+	for i, le := range latencyBuckets {
+		cumulative += h.counts[i].Load()
+		fmt.Fprintf(w, "%s_bucket{backend=\"%s\",le=\"%g\"} %d\n", name, backend, le, cumulative)
+	}
+	cumulative += h.counts[len(latencyBuckets)].Load()
+	fmt.Fprintf(w, "%s_bucket{backend=\"%s\",le=\"+Inf\"} %d\n", name, backend, cumulative)
+	fmt.Fprintf(w, "%s_sum{backend=\"%s\"} %g\n", name, backend, time.Duration(h.sumNs.Load()).Seconds())
+	fmt.Fprintf(w, "%s_count{backend=\"%s\"} %d\n", name, backend, cumulative)
+}
+
+func header(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(v string) string {
+	return labelEscaper.Replace(v)
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scrape(t *testing.T, states ...BackendState) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Handler(func() []BackendState { return states }).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return rec.Body.String()
+}
+
+func TestHealthProbeMetrics(t *testing.T) {
+	const url = "http://probe-metrics.test:80"
+	IncHealthChecks(url, true)
+	IncHealthChecks(url, false)
+	IncHealthChecks(url, false)
+
+	body := scrape(t, BackendState{URL: url, HealthCheckDuration: 250 * time.Millisecond, ConsecutiveFailures: 2})
+	for _, want := range []string{
+		`lb_backend_health_checks_total{backend="` + url + `",result="success"} 1`,
+		`lb_backend_health_checks_total{backend="` + url + `",result="failure"} 2`,
+		`lb_backend_health_check_duration_seconds{backend="` + url + `"} 0.25`,
+		`lb_backend_health_consecutive_failures{backend="` + url + `"} 2`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("scrape is missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package accesslog
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/logger"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
+)
+
+type Handler interface {
+	ServeHTTP(http.ResponseWriter, *http.Request)
+}
+
+type AccessLog struct {
+	trusted []netip.Prefix
+	next    Handler
+}
+
+// NewAccessLog logs every request. trusted lists the proxies whose
+// X-Forwarded-For is used for the logged client address.
+func NewAccessLog(trusted []netip.Prefix, next Handler) *AccessLog {
+	return &AccessLog{trusted: trusted, next: next}
+}
+
+func (al *AccessLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	upstream := &util.Upstream{}
+	r = r.WithContext(context.WithValue(r.Context(), util.CtxUpstreamKey, upstream))
+
+	rec := NewRecorder(w)
+	al.next.ServeHTTP(rec, r)
+
+	logger.Info("request",
+		"request_id", util.GetRequestIDFromContext(r),
+		"method", r.Method,
+		"path", r.URL.Path,
+		"client", util.ClientIP(r, al.trusted),
+		"status", rec.Status(),
+		"bytes", rec.BytesWritten(),
+		"backend", upstream.Get(),
+		"latency_ms", float64(time.Since(start).Microseconds())/1000,
+	)
+}
+
+// Recorder captures the status code and body size of a response. The status
+// defaults to 200 for handlers that write without calling WriteHeader.
+type Recorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (rec *Recorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.status = code
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *Recorder) Write(p []byte) (int, error) {
+	rec.wroteHeader = true
+	n, err := rec.ResponseWriter.Write(p)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+func (rec *Recorder) Status() int {
+	return rec.status
+}
+
+func (rec *Recorder) BytesWritten() int64 {
+	return rec.bytes
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer for
+// flushing and hijacking.
+func (rec *Recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,82 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/logger"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
+)
+
+func TestRecorderStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+		want    int
+	}{
+		{"implicit 200", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("hello")) }, http.StatusOK},
+		{"no body", func(w http.ResponseWriter, r *http.Request) {}, http.StatusOK},
+		{"custom", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) }, http.StatusTeapot},
+		{"first wins", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.WriteHeader(http.StatusInternalServerError)
+		}, http.StatusNotFound},
+		{"write then header", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("x"))
+			w.WriteHeader(http.StatusInternalServerError)
+		}, http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := NewRecorder(httptest.NewRecorder())
+			tc.handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if rec.Status() != tc.want {
+				t.Errorf("status = %d, want %d", rec.Status(), tc.want)
+			}
+		})
+	}
+}
+
+func TestAccessLogLine(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := logger.New(&buf, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(l)
+
+	h := NewAccessLog(nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		util.SetUpstream(r, "http://10.0.0.1:80")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/items", nil)
+	req.RemoteAddr = "192.0.2.1:40000"
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log output %q: %v", buf.String(), err)
+	}
+	want := map[string]any{
+		"msg":     "request",
+		"method":  "POST",
+		"path":    "/items",
+		"client":  "192.0.2.1",
+		"status":  float64(http.StatusCreated),
+		"bytes":   float64(len("created")),
+		"backend": "http://10.0.0.1:80",
+	}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("%s = %v, want %v", k, line[k], v)
+		}
+	}
+	if _, ok := line["latency_ms"].(float64); !ok {
+		t.Errorf("latency_ms = %v, want a number", line["latency_ms"])
+	}
+}
@@ -0,0 +1,51 @@
+package apikey
+
+import (
+	"net/http"
+)
+
+type Handler interface {
+	ServeHTTP(http.ResponseWriter, *http.Request)
+}
+
+type Validator interface {
+	Valid(key string) bool
+}
+
+type AllowList map[string]struct{}
+
+func NewAllowList(keys []string) AllowList {
+	al := make(AllowList, len(keys))
+	for _, k := range keys {
+		al[k] = struct{}{}
+	}
+	return al
+}
+
+func (al AllowList) Valid(key string) bool {
+	_, ok := al[key]
+	return ok
+}
+
+type APIKey struct {
+	validator Validator
+	next      Handler
+}
+
+func NewAPIKey(validator Validator, next Handler) *APIKey {
+	return &APIKey{
+		validator: validator,
+		next:      next,
+	}
+}
+
+func (a *APIKey) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("x-api-key")
+
+	if key == "" || !a.validator.Valid(key) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	a.next.ServeHTTP(w, r)
+}
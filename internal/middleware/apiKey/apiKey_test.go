@@ -0,0 +1,67 @@
+package apikey
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ratelimiter "github.com/soham0w0sarkar/LoadBalancerGo.git/internal/middleware/rateLimiter"
+)
+
+func TestAPIKey(t *testing.T) {
+	var served int
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { served++ })
+	rl := ratelimiter.NewRateLimiter(1, 0, nil, ratelimiter.NewFakeClock(ratelimiter.RealClock.Now()), ok)
+	defer rl.Close()
+	h := NewAPIKey(NewAllowList([]string{"known"}), rl)
+
+	send := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if key != "" {
+			req.Header.Set("x-api-key", key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := send(""); code != http.StatusUnauthorized {
+		t.Errorf("missing key: status %d, want 401", code)
+	}
+	if code := send("unknown"); code != http.StatusUnauthorized {
+		t.Errorf("unknown key: status %d, want 401", code)
+	}
+	if served != 0 {
+		t.Fatalf("rejected keys reached the handler %d times", served)
+	}
+
+	if code := send("known"); code != http.StatusOK {
+		t.Errorf("known key: status %d, want 200", code)
+	}
+	// The bucket holds one token, so the known key is now rate limited
+	// rather than rejected.
+	if code := send("known"); code != http.StatusTooManyRequests {
+		t.Errorf("known key over its limit: status %d, want 429", code)
+	}
+	if served != 1 {
+		t.Errorf("handler served %d requests, want 1", served)
+	}
+}
+
+type prefixValidator string
+
+func (p prefixValidator) Valid(key string) bool { return strings.HasPrefix(key, string(p)) }
+
+func TestCustomValidator(t *testing.T) {
+	h := NewAPIKey(prefixValidator("lb_"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for key, want := range map[string]int{"lb_123": http.StatusOK, "xx_123": http.StatusUnauthorized} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("x-api-key", key)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("%s: status %d, want %d", key, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
+)
+
+type Handler interface {
+	ServeHTTP(http.ResponseWriter, *http.Request)
+}
+
+type entry struct {
+	key       string
+	status    int
+	header    http.Header
+	body      []byte
+	vary      map[string]string
+	expiresAt time.Time
+	elem      *list.Element
+}
+
+// Cache keeps successful GET responses for ttl, up to maxEntries of them.
+// Every entry lives for the same ttl, so order, oldest first, is also the
+// order they expire in and the order they are evicted in when full.
+type Cache struct {
+	entries     map[string]*entry
+	order       *list.List
+	ttl         time.Duration
+	maxBodySize int
+	maxEntries  int
+	next        Handler
+	mux         sync.Mutex
+	stop        chan struct{}
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// sweepInterval is how often expired entries are dropped, so entries that
+// are never asked for again don't hold memory until they are evicted.
+const sweepInterval = time.Minute
+
+func NewCache(ttl time.Duration, maxBodySize, maxEntries int, next Handler) *Cache {
+	c := &Cache{
+		entries:     make(map[string]*entry),
+		order:       list.New(),
+		ttl:         ttl,
+		maxBodySize: maxBodySize,
+		maxEntries:  maxEntries,
+		next:        next,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go c.sweep()
+	return c
+}
+
+func (c *Cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Requests carrying credentials may get a response meant for that
+	// user only, so they are neither answered from nor stored in the cache.
+	if r.Method != http.MethodGet || util.IsUpgradeRequest(r) ||
+		r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+		c.next.ServeHTTP(w, r)
+		return
+	}
+
+	key := r.Host + r.URL.RequestURI()
+
+	if e := c.get(key, r); e != nil {
+		for k, v := range e.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(e.status)
+		w.Write(e.body)
+		return
+	}
+
+	// Headers set by the middlewares in front of the cache, such as a
+	// request ID, belong to this request and are left out of the entry.
+	before := w.Header().Clone()
+	bw := &bufferingWriter{ResponseWriter: w, status: http.StatusOK, limit: c.maxBodySize}
+	c.next.ServeHTTP(bw, r)
+
+	if bw.streaming {
+		return
+	}
+
+	// The whole body fit under the limit, so its length is known now even if
+	// the backend sent it chunked.
+	w.Header().Set("Content-Length", strconv.Itoa(len(bw.buf)))
+
+	// Taken before WriteHeader, which lets the middlewares in front add
+	// their own headers, like the sticky cookie.
+	if bw.status == http.StatusOK && storable(w.Header()) {
+		c.set(&entry{
+			key:       key,
+			status:    bw.status,
+			header:    addedHeaders(before, w.Header()),
+			body:      bw.buf,
+			vary:      varyValues(w.Header(), r),
+			expiresAt: time.Now().Add(c.ttl),
+		})
+	}
+
+	w.WriteHeader(bw.status)
+	w.Write(bw.buf)
+}
+
+// storable reports whether a response may be shared with other clients:
+// it sets no cookie, doesn't forbid shared caching and doesn't vary on
+// something other than request headers.
+func storable(h http.Header) bool {
+	if len(h.Values("Set-Cookie")) > 0 {
+		return false
+	}
+	for _, v := range h.Values("Cache-Control") {
+		for _, directive := range strings.Split(v, ",") {
+			name, _, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			switch strings.ToLower(name) {
+			case "no-store", "no-cache", "private":
+				return false
+			}
+		}
+	}
+	for _, name := range varyNames(h) {
+		if name == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+func varyNames(h http.Header) []string {
+	var names []string
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// varyValues records the request headers named by the response's Vary, which
+// a later request must repeat to be served the entry.
+func varyValues(h http.Header, r *http.Request) map[string]string {
+	names := varyNames(h)
+	if len(names) == 0 {
+		return nil
+	}
+	vary := make(map[string]string, len(names))
+	for _, name := range names {
+		vary[name] = strings.Join(r.Header.Values(name), ",")
+	}
+	return vary
+}
+
+func addedHeaders(before, after http.Header) http.Header {
+	added := make(http.Header, len(after))
+	for k, v := range after {
+		if !slices.Equal(before[k], v) {
+			added[k] = slices.Clone(v)
+		}
+	}
+	return added
+}
+
+func (c *Cache) get(key string, r *http.Request) *entry {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(e.expiresAt) {
+		c.remove(e)
+		return nil
+	}
+	for name, value := range e.vary {
+		if strings.Join(r.Header.Values(name), ",") != value {
+			return nil
+		}
+	}
+	return e
+}
+
+func (c *Cache) set(e *entry) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if old, ok := c.entries[e.key]; ok {
+		c.remove(old)
+	}
+	for c.maxEntries > 0 && len(c.entries) >= c.maxEntries {
+		c.remove(c.order.Front().Value.(*entry))
+	}
+	e.elem = c.order.PushBack(e)
+	c.entries[e.key] = e
+}
+
+// remove drops e; the caller holds c.mux.
+func (c *Cache) remove(e *entry) {
+	c.order.Remove(e.elem)
+	delete(c.entries, e.key)
+}
+
+func (c *Cache) sweep() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			c.mux.Lock()
+			for front := c.order.Front(); front != nil; front = c.order.Front() {
+				e := front.Value.(*entry)
+				if !now.After(e.expiresAt) {
+					break
+				}
+				c.remove(e)
+			}
+			c.mux.Unlock()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Close stops the expiry sweeper and waits for it to exit. It is safe to
+// call more than once.
+func (c *Cache) Close() error {
+	c.closeOnce.Do(func() { close(c.stop) })
+	<-c.done
+	return nil
+}
+
+// bufferingWriter holds the response in memory until it either completes or
+// grows past limit, at which point it falls back to streaming what it has
+// buffered and everything after it straight to the client.
+type bufferingWriter struct {
+	http.ResponseWriter
+	status    int
+	buf       []byte
+	limit     int
+	streaming bool
+}
+
+func (bw *bufferingWriter) WriteHeader(code int) {
+	if bw.streaming {
+		return
+	}
+	bw.status = code
+	if code != http.StatusOK {
+		bw.startStreaming()
+	}
+}
+
+func (bw *bufferingWriter) Write(p []byte) (int, error) {
+	if bw.streaming {
+		return bw.ResponseWriter.Write(p)
+	}
+	if len(bw.buf)+len(p) > bw.limit {
+		if err := bw.startStreaming(); err != nil {
+			return 0, err
+		}
+		return bw.ResponseWriter.Write(p)
+	}
+	bw.buf = append(bw.buf, p...)
+	return len(p), nil
+}
+
+// Flush is a no-op while buffering; the reverse proxy flushes eagerly on
+// responses without a Content-Length, which would otherwise defeat caching.
+func (bw *bufferingWriter) Flush() {
+	if !bw.streaming {
+		return
+	}
+	if f, ok := bw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (bw *bufferingWriter) startStreaming() error {
+	bw.streaming = true
+	bw.ResponseWriter.WriteHeader(bw.status)
+	if len(bw.buf) == 0 {
+		return nil
+	}
+	_, err := bw.ResponseWriter.Write(bw.buf)
+	bw.buf = nil
+	return err
+}
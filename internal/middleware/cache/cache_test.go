@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chunked writes body in small flushed pieces without a Content-Length,
+// the way the reverse proxy relays a chunked upstream response.
+func chunked(body string, calls *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		for chunk := range strings.SplitSeq(body, " ") {
+			w.Write([]byte(chunk + " "))
+			w.(http.Flusher).Flush()
+		}
+	})
+}
+
+func get(h http.Handler, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestChunkedUnderLimitIsCached(t *testing.T) {
+	var calls int
+	c := NewCache(time.Minute, 64, 0, chunked("a small chunked body", &calls))
+	defer c.Close()
+
+	first := get(c, "/small")
+	second := get(c, "/small")
+	if calls != 1 {
+		t.Fatalf("backend called %d times, want 1", calls)
+	}
+	for _, rec := range []*httptest.ResponseRecorder{first, second} {
+		if got := rec.Body.String(); got != "a small chunked body " {
+			t.Errorf("body = %q", got)
+		}
+		if got := rec.Header().Get("Content-Length"); got != "21" {
+			t.Errorf("Content-Length = %q, want 21", got)
+		}
+	}
+}
+
+func TestChunkedOverLimitStreams(t *testing.T) {
+	var calls int
+	body := strings.Repeat("chunk ", 20)
+	c := NewCache(time.Minute, 64, 0, chunked(strings.TrimSpace(body), &calls))
+	defer c.Close()
+
+	first := get(c, "/large")
+	if got := first.Body.String(); got != body {
+		t.Errorf("body = %q, want all %d bytes", got, len(body))
+	}
+	if !first.Flushed {
+		t.Error("oversized response was not streamed")
+	}
+	if got := first.Header().Get("Content-Length"); got != "" {
+		t.Errorf("streamed response has Content-Length %q", got)
+	}
+	get(c, "/large")
+	if calls != 2 {
+		t.Errorf("backend called %d times, want the large response left uncached", calls)
+	}
+}
+
+func TestPerUserResponsesNotCached(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		request  http.Header
+		response http.Header
+	}{
+		{"authorization", http.Header{"Authorization": {"Bearer x"}}, nil},
+		{"cookie", http.Header{"Cookie": {"session=1"}}, nil},
+		{"set-cookie", nil, http.Header{"Set-Cookie": {"session=2"}}},
+		{"private", nil, http.Header{"Cache-Control": {"max-age=60, private"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls int
+			c := NewCache(time.Minute, 64, 0, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				for k, v := range tc.response {
+					w.Header()[k] = v
+				}
+				w.Write([]byte("hello"))
+			}))
+			defer c.Close()
+
+			for range 2 {
+				req := httptest.NewRequest(http.MethodGet, "/user", nil)
+				for k, v := range tc.request {
+					req.Header[k] = v
+				}
+				c.ServeHTTP(httptest.NewRecorder(), req)
+			}
+			if calls != 2 {
+				t.Errorf("backend called %d times, want 2", calls)
+			}
+		})
+	}
+}
+
+func TestMaxEntriesEvictsOldest(t *testing.T) {
+	calls := map[string]int{}
+	c := NewCache(time.Minute, 64, 2, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.URL.Path]++
+	}))
+	defer c.Close()
+
+	for _, path := range []string{"/a", "/b", "/c", "/c", "/b", "/a"} {
+		get(c, path)
+	}
+	want := map[string]int{"/a": 2, "/b": 1, "/c": 1}
+	for path, n := range want {
+		if calls[path] != n {
+			t.Errorf("%s: backend called %d times, want %d", path, calls[path], n)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package compression
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
+)
+
+type Handler interface {
+	ServeHTTP(http.ResponseWriter, *http.Request)
+}
+
+// DefaultContentTypes are compressed when no allowlist is configured.
+var DefaultContentTypes = []string{
+	"text/html",
+	"text/plain",
+	"text/css",
+	"text/javascript",
+	"application/javascript",
+	"application/json",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// Compression gzips responses for clients that accept it, provided the
+// response is of an allowed content type, at least minSize bytes, and not
+// already encoded.
+type Compression struct {
+	minSize      int
+	contentTypes []string
+	pool         sync.Pool
+	next         Handler
+}
+
+func NewCompression(level, minSize int, contentTypes []string, next Handler) *Compression {
+	if len(contentTypes) == 0 {
+		contentTypes = DefaultContentTypes
+	}
+	c := &Compression{minSize: minSize, contentTypes: contentTypes, next: next}
+	c.pool.New = func() any {
+		// level is validated with the config, so this cannot fail.
+		gz, _ := gzip.NewWriterLevel(nil, level)
+		return gz
+	}
+	return c
+}
+
+func (c *Compression) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodHead || util.IsUpgradeRequest(r) || !acceptsGzip(r) {
+		c.next.ServeHTTP(w, r)
+		return
+	}
+
+	gw := &gzipWriter{ResponseWriter: w, c: c, status: http.StatusOK}
+	defer gw.close()
+	c.next.ServeHTTP(gw, r)
+}
+
+// acceptsGzip reports whether the client lists gzip in Accept-Encoding
+// without disabling it with q=0.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		v, err := strconv.ParseFloat(q, 64)
+		return err == nil && v > 0
+	}
+	return false
+}
+
+func (c *Compression) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range c.contentTypes {
+		if strings.EqualFold(t, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipWriter holds back the first minSize bytes of the response so it can
+// decide whether compressing is worthwhile before committing the headers.
+type gzipWriter struct {
+	http.ResponseWriter
+	c           *Compression
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	gz          *gzip.Writer
+}
+
+func (gw *gzipWriter) WriteHeader(code int) {
+	if gw.wroteHeader {
+		return
+	}
+	// Informational responses pass straight through.
+	if code >= 100 && code < 200 {
+		gw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	gw.wroteHeader = true
+	gw.status = code
+}
+
+func (gw *gzipWriter) Write(p []byte) (int, error) {
+	gw.wroteHeader = true
+	if !gw.decided {
+		gw.buf = append(gw.buf, p...)
+		if len(gw.buf) < gw.c.minSize {
+			return len(p), nil
+		}
+		if err := gw.decide(false); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if gw.gz != nil {
+		return gw.gz.Write(p)
+	}
+	return gw.ResponseWriter.Write(p)
+}
+
+// decide picks compression or passthrough and writes out the held-back
+// bytes. final means the response is complete, so the buffer is the whole
+// body.
+func (gw *gzipWriter) decide(final bool) error {
+	gw.decided = true
+	h := gw.Header()
+
+	if h.Get("Content-Type") == "" && len(gw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(gw.buf))
+	}
+
+	compress := gw.status != http.StatusNoContent &&
+		gw.status != http.StatusNotModified &&
+		h.Get("Content-Encoding") == "" &&
+		gw.c.allowed(h.Get("Content-Type")) &&
+		!(final && (len(gw.buf) == 0 || len(gw.buf) < gw.c.minSize))
+	if cl := h.Get("Content-Length"); compress && cl != "" {
+		if n, err := strconv.Atoi(cl); err == nil && n < gw.c.minSize {
+			compress = false
+		}
+	}
+
+	if !compress {
+		gw.ResponseWriter.WriteHeader(gw.status)
+		return gw.flushBuf(gw.ResponseWriter.Write)
+	}
+
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", "gzip")
+	h.Add("Vary", "Accept-Encoding")
+	gw.ResponseWriter.WriteHeader(gw.status)
+
+	gw.gz = gw.c.pool.Get().(*gzip.Writer)
+	gw.gz.Reset(gw.ResponseWriter)
+	return gw.flushBuf(gw.gz.Write)
+}
+
+func (gw *gzipWriter) flushBuf(write func([]byte) (int, error)) error {
+	if len(gw.buf) == 0 {
+		return nil
+	}
+	_, err := write(gw.buf)
+	gw.buf = nil
+	return err
+}
+
+// Flush commits to a decision with what has arrived so far, so streamed
+// responses aren't held back waiting for minSize bytes.
+func (gw *gzipWriter) Flush() {
+	if !gw.decided {
+		if err := gw.decide(false); err != nil {
+			return
+		}
+	}
+	if gw.gz != nil {
+		_ = gw.gz.Flush()
+	}
+	if f, ok := gw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (gw *gzipWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
+
+func (gw *gzipWriter) close() {
+	if !gw.decided {
+		if !gw.wroteHeader {
+			// The handler wrote nothing; leave the implicit response alone.
+			return
+		}
+		if err := gw.decide(true); err != nil {
+			return
+		}
+	}
+	if gw.gz != nil {
+		_ = gw.gz.Close()
+		gw.c.pool.Put(gw.gz)
+		gw.gz = nil
+	}
+}
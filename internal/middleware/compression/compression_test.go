@@ -0,0 +1,115 @@
+package compression
+
+import (
+	"compress/gzip"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serve sends a gzip-accepting GET through c and returns the response.
+func serve(c *Compression) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, req)
+	return rec
+}
+
+func respond(contentType, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		io.WriteString(w, body)
+	})
+}
+
+func gunzip(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestCompressionLevels(t *testing.T) {
+	// Words drawn from a small vocabulary are compressible, but not so
+	// trivially that every level finds the same encoding.
+	rnd := rand.New(rand.NewSource(1))
+	words := []string{"alpha", "beta", "gamma", "delta", "epsilon", "zeta", "eta", "theta"}
+	var sb strings.Builder
+	for range 20000 {
+		sb.WriteString(words[rnd.Intn(len(words))])
+		sb.WriteByte(' ')
+	}
+	body := sb.String()
+
+	sizes := map[int]int{}
+	for _, level := range []int{gzip.BestSpeed, gzip.BestCompression} {
+		rec := serve(NewCompression(level, 0, nil, respond("text/plain", body)))
+		sizes[level] = rec.Body.Len()
+		if got := gunzip(t, rec); got != body {
+			t.Fatalf("level %d: body did not round-trip", level)
+		}
+	}
+	if sizes[gzip.BestCompression] >= sizes[gzip.BestSpeed] {
+		t.Errorf("level %d gave %d bytes, level %d gave %d; want the higher level smaller",
+			gzip.BestCompression, sizes[gzip.BestCompression], gzip.BestSpeed, sizes[gzip.BestSpeed])
+	}
+}
+
+func TestCompressesJSON(t *testing.T) {
+	body := `{"items":[` + strings.Repeat(`{"name":"item","ok":true},`, 100) + `{}]}`
+	rec := serve(NewCompression(gzip.DefaultCompression, 256, nil, respond("application/json", body)))
+
+	if enc := rec.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", enc)
+	}
+	if vary := rec.Header().Get("Vary"); vary != "Accept-Encoding" {
+		t.Errorf("Vary = %q, want Accept-Encoding", vary)
+	}
+	if rec.Body.Len() >= len(body) {
+		t.Errorf("compressed body is %d bytes, not smaller than %d", rec.Body.Len(), len(body))
+	}
+	if got := gunzip(t, rec); got != body {
+		t.Error("body did not round-trip")
+	}
+}
+
+func TestSkipsEncodedAndSmallResponses(t *testing.T) {
+	var buf strings.Builder
+	zw := gzip.NewWriter(&buf)
+	io.WriteString(zw, strings.Repeat("already compressed ", 100))
+	zw.Close()
+	gzipped := buf.String()
+
+	for _, tc := range []struct {
+		name     string
+		next     http.Handler
+		want     string
+		encoding string
+	}{
+		{"already gzipped", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Content-Encoding", "gzip")
+			io.WriteString(w, gzipped)
+		}), gzipped, "gzip"},
+		{"under min size", respond("application/json", `{"ok":true}`), `{"ok":true}`, ""},
+		{"disallowed type", respond("image/png", strings.Repeat("x", 1024)), strings.Repeat("x", 1024), ""},
+	} {
+		rec := serve(NewCompression(gzip.DefaultCompression, 256, nil, tc.next))
+		if rec.Body.String() != tc.want {
+			t.Errorf("%s: body was rewritten", tc.name)
+		}
+		if enc := rec.Header().Get("Content-Encoding"); enc != tc.encoding {
+			t.Errorf("%s: Content-Encoding = %q, want %q", tc.name, enc, tc.encoding)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package headers
+
+import (
+	"net/http"
+)
+
+type Handler interface {
+	ServeHTTP(http.ResponseWriter, *http.Request)
+}
+
+// ResponseHeaders edits the headers of every response leaving the load
+// balancer, whether proxied or generated locally. Remove runs first, then
+// Set replaces and Add appends, so a header can be both stripped from the
+// upstream response and given a value of the load balancer's own.
+type ResponseHeaders struct {
+	set    http.Header
+	add    http.Header
+	remove []string
+	next   Handler
+}
+
+func NewResponseHeaders(set, add map[string]string, remove []string, next Handler) *ResponseHeaders {
+	rh := &ResponseHeaders{set: http.Header{}, add: http.Header{}, next: next}
+	for k, v := range set {
+		rh.set.Set(k, v)
+	}
+	for k, v := range add {
+		rh.add.Add(k, v)
+	}
+	// Canonicalising up front makes removal case-insensitive.
+	for _, k := range remove {
+		rh.remove = append(rh.remove, http.CanonicalHeaderKey(k))
+	}
+	return rh
+}
+
+func (rh *ResponseHeaders) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rh.next.ServeHTTP(&headerWriter{ResponseWriter: w, rules: rh}, r)
+}
+
+func (rh *ResponseHeaders) apply(h http.Header) {
+	for _, k := range rh.remove {
+		h.Del(k)
+	}
+	for k, v := range rh.set {
+		h[k] = append([]string(nil), v...)
+	}
+	for k, v := range rh.add {
+		h[k] = append(h[k], v...)
+	}
+}
+
+// headerWriter applies the rules once, just before the header is sent.
+type headerWriter struct {
+	http.ResponseWriter
+	rules       *ResponseHeaders
+	wroteHeader bool
+}
+
+func (hw *headerWriter) WriteHeader(code int) {
+	// Informational responses go out with whatever is set so far; the
+	// rules belong to the final response.
+	if !hw.wroteHeader && code >= 200 {
+		hw.rules.apply(hw.ResponseWriter.Header())
+		hw.wroteHeader = true
+	}
+	hw.ResponseWriter.WriteHeader(code)
+}
+
+func (hw *headerWriter) Write(p []byte) (int, error) {
+	if !hw.wroteHeader {
+		hw.WriteHeader(http.StatusOK)
+	}
+	return hw.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer for
+// flushing and hijacking.
+func (hw *headerWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
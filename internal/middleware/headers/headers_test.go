@@ -0,0 +1,63 @@
+package headers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestResponseHeaders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "upstream/1.0")
+		w.Header().Set("X-Powered-By", "php")
+		w.Header().Set("X-Frame-Options", "ALLOW")
+		w.Header().Set("Cache-Control", "no-cache")
+	}))
+	defer upstream.Close()
+	u, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewResponseHeaders(
+		map[string]string{"x-frame-options": "DENY", "X-Served-By": "lb"},
+		map[string]string{"Cache-Control": "private", "Server": "lb"},
+		[]string{"x-powered-by", "SERVER"},
+		httputil.NewSingleHostReverseProxy(u),
+	)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	got := rec.Result().Header
+	if v := got.Values("X-Frame-Options"); !slices.Equal(v, []string{"DENY"}) {
+		t.Errorf("X-Frame-Options = %q, want it set to DENY", v)
+	}
+	if v := got.Get("X-Served-By"); v != "lb" {
+		t.Errorf("X-Served-By = %q, want lb", v)
+	}
+	if v := got.Values("Cache-Control"); !slices.Equal(v, []string{"no-cache", "private"}) {
+		t.Errorf("Cache-Control = %q, want private added to the upstream value", v)
+	}
+	if v := got.Values("X-Powered-By"); v != nil {
+		t.Errorf("X-Powered-By = %q, want it removed", v)
+	}
+	// Removal runs before add, so only the load balancer's value is left.
+	if v := got.Values("Server"); !slices.Equal(v, []string{"lb"}) {
+		t.Errorf("Server = %q, want only lb", v)
+	}
+}
+
+func TestResponseHeadersOnLocalResponse(t *testing.T) {
+	h := NewResponseHeaders(map[string]string{"X-Served-By": "lb"}, nil, nil,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("X-Served-By") != "lb" {
+		t.Errorf("got %d with X-Served-By %q, want 503 with lb", rec.Code, rec.Header().Get("X-Served-By"))
+	}
+}
@@ -0,0 +1,51 @@
+package loadshedder
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/metrics"
+)
+
+type Handler interface {
+	ServeHTTP(http.ResponseWriter, *http.Request)
+}
+
+// LoadShedder caps the number of requests in flight through the load
+// balancer. Requests over the cap are rejected at once with 503 rather than
+// queued, so a saturated fleet isn't pushed further behind.
+type LoadShedder struct {
+	slots      chan struct{}
+	retryAfter string
+	next       Handler
+}
+
+func NewLoadShedder(maxInflight int, retryAfter time.Duration, next Handler) *LoadShedder {
+	seconds := int((retryAfter + time.Second - 1) / time.Second)
+	return &LoadShedder{
+		slots:      make(chan struct{}, maxInflight),
+		retryAfter: strconv.Itoa(max(seconds, 1)),
+		next:       next,
+	}
+}
+
+func (ls *LoadShedder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	select {
+	case ls.slots <- struct{}{}:
+	default:
+		metrics.IncShed()
+		w.Header().Set("Retry-After", ls.retryAfter)
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	// Released however the request ends, including on panic.
+	defer func() { <-ls.slots }()
+
+	ls.next.ServeHTTP(w, r)
+}
+
+// InFlight returns the number of requests currently holding a slot.
+func (ls *LoadShedder) InFlight() int {
+	return len(ls.slots)
+}
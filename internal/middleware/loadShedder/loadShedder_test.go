@@ -0,0 +1,80 @@
+package loadshedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoadShedderSheds(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	ls := NewLoadShedder(3, 1500*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/block" {
+			entered <- struct{}{}
+			<-release
+		}
+	}))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		ls.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	var blocked sync.WaitGroup
+	for range 3 {
+		blocked.Add(1)
+		go func() {
+			defer blocked.Done()
+			if rec := serve("/block"); rec.Code != http.StatusOK {
+				t.Errorf("request holding a slot: status %d", rec.Code)
+			}
+		}()
+		<-entered
+	}
+	if n := ls.InFlight(); n != 3 {
+		t.Fatalf("in flight = %d, want 3", n)
+	}
+
+	var shed sync.WaitGroup
+	for range 10 {
+		shed.Add(1)
+		go func() {
+			defer shed.Done()
+			start := time.Now()
+			rec := serve("/")
+			if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "2" {
+				t.Errorf("excess request: status %d, Retry-After %q; want 503 and 2", rec.Code, rec.Header().Get("Retry-After"))
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("excess request waited %v instead of being shed", elapsed)
+			}
+		}()
+	}
+	shed.Wait()
+
+	close(release)
+	blocked.Wait()
+	if n := ls.InFlight(); n != 0 {
+		t.Errorf("in flight = %d after every request finished, want 0", n)
+	}
+	if rec := serve("/"); rec.Code != http.StatusOK {
+		t.Errorf("status %d once slots were free, want 200", rec.Code)
+	}
+}
+
+func TestLoadShedderReleasesOnPanic(t *testing.T) {
+	ls := NewLoadShedder(1, time.Second, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	func() {
+		defer func() { recover() }()
+		ls.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	if n := ls.InFlight(); n != 0 {
+		t.Errorf("in flight = %d after a panic, want the slot released", n)
+	}
+}
@@ -8,13 +8,18 @@ import (
 type Bucket struct {
 	tokens     float64
 	lastRefill time.Time
+	clock      Clock
 	mux        sync.RWMutex
 }
 
-func NewBucket(capacity uint) *Bucket {
+// NewBucket returns a full bucket that refills by clock; nil means
+// RealClock.
+func NewBucket(capacity uint, clock Clock) *Bucket {
+	clock = clockOrReal(clock)
 	return &Bucket{
 		tokens:     float64(capacity),
-		lastRefill: time.Now(),
+		lastRefill: clock.Now(),
+		clock:      clock,
 	}
 }
 
@@ -22,10 +27,7 @@ func (b *Bucket) CheckAndConsumeToken(refillRate float64, capacity uint) bool {
 	b.mux.Lock()
 	defer b.mux.Unlock()
 
-	elapsed := time.Since(b.lastRefill)
-	tokensToAdd := elapsed.Seconds() * refillRate
-	b.tokens = min(tokensToAdd+b.tokens, float64(capacity))
-	b.lastRefill = time.Now()
+	b.refill(refillRate, capacity)
 
 	if b.tokens >= 1.0 {
 		b.tokens -= 1.0
@@ -34,3 +36,63 @@ func (b *Bucket) CheckAndConsumeToken(refillRate float64, capacity uint) bool {
 
 	return false
 }
+
+// Tokens returns the tokens left as of the last refill.
+func (b *Bucket) Tokens() float64 {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.tokens
+}
+
+// RefillAndPeek tops the bucket up for the time elapsed since the last
+// refill and returns the tokens available, without consuming one.
+func (b *Bucket) RefillAndPeek(refillRate float64, capacity uint) float64 {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.refill(refillRate, capacity)
+	return b.tokens
+}
+
+// refill adds the tokens earned since lastRefill, up to capacity. The caller
+// must hold b.mux for writing.
+func (b *Bucket) refill(refillRate float64, capacity uint) {
+	now := b.clock.Now()
+	tokensToAdd := now.Sub(b.lastRefill).Seconds() * refillRate
+	b.tokens = min(tokensToAdd+b.tokens, float64(capacity))
+	b.lastRefill = now
+}
+
+func (b *Bucket) clamp(capacity uint) {
+	b.mux.Lock()
+	b.tokens = min(b.tokens, float64(capacity))
+	b.mux.Unlock()
+}
+
+// full reports whether the bucket would be back at capacity by now, making
+// it indistinguishable from a fresh one.
+func (b *Bucket) full(refillRate float64, capacity uint) bool {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.tokens+b.clock.Now().Sub(b.lastRefill).Seconds()*refillRate >= float64(capacity)
+}
+
+// Limit is a single shared bucket for capping aggregate traffic, as opposed
+// to the per-client buckets kept by RateLimiter.
+type Limit struct {
+	bucket     *Bucket
+	capacity   uint
+	refillRate float64
+}
+
+func NewLimit(capacity uint, refillRate float64) *Limit {
+	return &Limit{
+		bucket:     NewBucket(capacity, RealClock),
+		capacity:   capacity,
+		refillRate: refillRate,
+	}
+}
+
+func (l *Limit) Allow() bool {
+	return l.bucket.CheckAndConsumeToken(l.refillRate, l.capacity)
+}
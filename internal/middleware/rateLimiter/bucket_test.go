@@ -0,0 +1,80 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketRefill(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	b := NewBucket(4, clock)
+	for range 4 {
+		if !b.CheckAndConsumeToken(2, 4) {
+			t.Fatal("a full bucket refused a token")
+		}
+	}
+	if b.CheckAndConsumeToken(2, 4) {
+		t.Fatal("an empty bucket handed out a token")
+	}
+
+	for _, tc := range []struct {
+		elapsed time.Duration
+		want    float64
+	}{
+		{250 * time.Millisecond, 0.5},
+		{250 * time.Millisecond, 1},
+		{time.Second, 3},
+		// Refill stops at the capacity however long the bucket sat idle.
+		{time.Hour, 4},
+	} {
+		clock.Advance(tc.elapsed)
+		if got := b.RefillAndPeek(2, 4); got != tc.want {
+			t.Errorf("after another %v: %v tokens, want %v", tc.elapsed, got, tc.want)
+		}
+	}
+}
+
+func TestBucketCapacityClamp(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	b := NewBucket(10, clock)
+
+	// A smaller capacity passed to a refill caps the tokens straight away.
+	if got := b.RefillAndPeek(1, 3); got != 3 {
+		t.Errorf("tokens = %v after refilling to a capacity of 3", got)
+	}
+	b.clamp(1)
+	if got := b.Tokens(); got != 1 {
+		t.Errorf("tokens = %v after clamping to 1", got)
+	}
+	// Raising the capacity doesn't add tokens by itself.
+	b.clamp(10)
+	if got := b.Tokens(); got != 1 {
+		t.Errorf("tokens = %v after clamping to a larger capacity", got)
+	}
+}
+
+func TestBucketPeekDoesNotConsume(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	b := NewBucket(2, clock)
+	for range 5 {
+		if got := b.RefillAndPeek(1, 2); got != 2 {
+			t.Fatalf("peek = %v, want the 2 tokens untouched", got)
+		}
+	}
+	if got := b.Tokens(); got != 2 {
+		t.Errorf("Tokens = %v after peeking, want 2", got)
+	}
+
+	b.CheckAndConsumeToken(1, 2)
+	clock.Advance(500 * time.Millisecond)
+	// Tokens reports the last refill; RefillAndPeek brings it up to date.
+	if got := b.Tokens(); got != 1 {
+		t.Errorf("Tokens = %v before a refill, want 1", got)
+	}
+	if got := b.RefillAndPeek(1, 2); got != 1.5 {
+		t.Errorf("RefillAndPeek = %v, want 1.5", got)
+	}
+	if got := b.Tokens(); got != 1.5 {
+		t.Errorf("Tokens = %v after the refill, want 1.5", got)
+	}
+}
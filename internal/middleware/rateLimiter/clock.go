@@ -0,0 +1,49 @@
+package ratelimiter
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is where buckets read the current time, so refills can be driven
+// by a FakeClock instead of waiting in real time.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// RealClock is the wall clock, used when a nil Clock is given.
+var RealClock Clock = realClock{}
+
+// FakeClock is a Clock that only moves when Advance is called.
+type FakeClock struct {
+	now time.Time
+	mux sync.Mutex
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mux.Lock()
+	c.now = c.now.Add(d)
+	c.mux.Unlock()
+}
+
+func clockOrReal(c Clock) Clock {
+	if c == nil {
+		return RealClock
+	}
+	return c
+}
@@ -0,0 +1,61 @@
+package ratelimiter
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewFakeClock(start)
+	if !clock.Now().Equal(start) {
+		t.Fatalf("Now = %v, want %v", clock.Now(), start)
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			clock.Advance(time.Second)
+			clock.Now()
+		})
+	}
+	wg.Wait()
+	if got := clock.Now().Sub(start); got != 10*time.Second {
+		t.Errorf("clock advanced %v, want 10s", got)
+	}
+}
+
+func TestRefillOverSimulatedTime(t *testing.T) {
+	rl, clock := newTestLimiter(t, 10, 2)
+	if got := allowed(rl, "", 20); got != 10 {
+		t.Fatalf("%d requests allowed from a fresh bucket, want 10", got)
+	}
+
+	// Each simulated second earns exactly two requests, however many are
+	// waiting.
+	for sec := 1; sec <= 5; sec++ {
+		clock.Advance(time.Second)
+		if got := allowed(rl, "", 20); got != 2 {
+			t.Fatalf("second %d: %d requests allowed, want 2", sec, got)
+		}
+	}
+
+	// Quarter seconds earn half a token, so every other one lets a request
+	// through.
+	var got []int
+	for range 4 {
+		clock.Advance(250 * time.Millisecond)
+		got = append(got, allowed(rl, "", 5))
+	}
+	if want := []int{0, 1, 0, 1}; !slices.Equal(got, want) {
+		t.Errorf("allowed per quarter second = %v, want %v", got, want)
+	}
+
+	// An idle client is back to a full bucket, and no more.
+	clock.Advance(time.Hour)
+	if got := allowed(rl, "", 20); got != 10 {
+		t.Errorf("%d requests allowed after an hour idle, want the capacity of 10", got)
+	}
+}
@@ -1,9 +1,14 @@
 package ratelimiter
 
 import (
-	"fmt"
 	"net/http"
+	"net/netip"
 	"sync"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/logger"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/metrics"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
 )
 
 type Handler interface {
@@ -14,38 +19,108 @@ type RateLimiter struct {
 	BucketList map[string]*Bucket
 	capacity   uint
 	refillRate float64
+	overrides  map[string]Tier
+	trusted    []netip.Prefix
+	clock      Clock
 	next       Handler
 	mux        sync.RWMutex
+	stop       chan struct{}
+	done       chan struct{}
+	closeOnce  sync.Once
+}
+
+// Tier is the bucket size and refill rate given to one API key instead of
+// the limiter's defaults.
+type Tier struct {
+	Capacity   uint
+	RefillRate float64
 }
 
-func NewRateLimiter(capacity uint, refillRate float64, next Handler) *RateLimiter {
-	return &RateLimiter{
+// sweepInterval is how often buckets that have refilled completely are
+// dropped, so clients that went away don't accumulate forever.
+const sweepInterval = time.Minute
+
+// NewRateLimiter limits each client, identified by its x-api-key or, without
+// one, by its IP. trusted lists the proxies whose X-Forwarded-For is used to
+// find that IP. Buckets refill by clock; nil means RealClock.
+func NewRateLimiter(capacity uint, refillRate float64, trusted []netip.Prefix, clock Clock, next Handler) *RateLimiter {
+	rl := &RateLimiter{
 		BucketList: make(map[string]*Bucket),
 		capacity:   capacity,
 		refillRate: refillRate,
+		trusted:    trusted,
+		clock:      clockOrReal(clock),
 		next:       next,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
 	}
+	go rl.sweep()
+	return rl
 }
 
 func (rl *RateLimiter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	clientIp := r.Header.Get("x-api-key")
+	if clientIp == "" {
+		clientIp = util.ClientIP(r, rl.trusted)
+	}
 
-	fmt.Printf("Hey there: %s\n", clientIp)
+	logger.Debug("rate limit check", "client", clientIp)
 
+	rl.mux.RLock()
 	clientBucket := rl.BucketList[clientIp]
+	capacity, refillRate := rl.limitsFor(clientIp)
+	rl.mux.RUnlock()
 
 	if clientBucket != nil {
-		if !clientBucket.CheckAndConsumeToken(rl.refillRate, rl.capacity) {
+		if !clientBucket.CheckAndConsumeToken(refillRate, capacity) {
+			metrics.IncRateLimited()
 			http.Error(w, "Rate Limited this IP", http.StatusTooManyRequests)
 			return
 		}
 	} else {
-		bucketToAdd := NewBucket(rl.capacity - 1)
+		bucketToAdd := NewBucket(capacity-1, rl.clock)
 		rl.addBucket(bucketToAdd, clientIp)
 	}
 	rl.next.ServeHTTP(w, r)
 }
 
+// SetLimits changes the default bucket size and refill rate. Buckets
+// holding more tokens than their new capacity are cut down to it.
+func (rl *RateLimiter) SetLimits(capacity uint, refillRate float64) {
+	rl.mux.Lock()
+	defer rl.mux.Unlock()
+
+	rl.capacity = capacity
+	rl.refillRate = refillRate
+	rl.clampBuckets()
+}
+
+// SetOverrides replaces the per-key tiers; keys without one get the
+// default limits.
+func (rl *RateLimiter) SetOverrides(overrides map[string]Tier) {
+	rl.mux.Lock()
+	defer rl.mux.Unlock()
+
+	rl.overrides = overrides
+	rl.clampBuckets()
+}
+
+// limitsFor returns the bucket size and refill rate for key. The caller
+// must hold rl.mux.
+func (rl *RateLimiter) limitsFor(key string) (uint, float64) {
+	if tier, ok := rl.overrides[key]; ok {
+		return tier.Capacity, tier.RefillRate
+	}
+	return rl.capacity, rl.refillRate
+}
+
+func (rl *RateLimiter) clampBuckets() {
+	for key, bucket := range rl.BucketList {
+		capacity, _ := rl.limitsFor(key)
+		bucket.clamp(capacity)
+	}
+}
+
 func (rl *RateLimiter) addBucket(bucket *Bucket, clientIp string) {
 	rl.mux.Lock()
 
@@ -53,3 +128,34 @@ func (rl *RateLimiter) addBucket(bucket *Bucket, clientIp string) {
 
 	rl.mux.Unlock()
 }
+
+func (rl *RateLimiter) sweep() {
+	defer close(rl.done)
+
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			rl.mux.Lock()
+			for key, bucket := range rl.BucketList {
+				capacity, refillRate := rl.limitsFor(key)
+				if bucket.full(refillRate, capacity) {
+					delete(rl.BucketList, key)
+				}
+			}
+			rl.mux.Unlock()
+		case <-rl.stop:
+			return
+		}
+	}
+}
+
+// Close stops the bucket sweeper and waits for it to exit. It is safe to
+// call more than once.
+func (rl *RateLimiter) Close() error {
+	rl.closeOnce.Do(func() { close(rl.stop) })
+	<-rl.done
+	return nil
+}
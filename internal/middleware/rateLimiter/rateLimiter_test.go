@@ -0,0 +1,93 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+func newTestLimiter(t *testing.T, capacity uint, refillRate float64) (*RateLimiter, *FakeClock) {
+	t.Helper()
+	clock := NewFakeClock(time.Unix(0, 0))
+	rl := NewRateLimiter(capacity, refillRate, nil, clock, ok)
+	t.Cleanup(func() { rl.Close() })
+	return rl, clock
+}
+
+// allowed sends n requests with the given API key, or from the default
+// httptest client address without one, and returns how many got through.
+func allowed(rl *RateLimiter, key string, n int) int {
+	served := 0
+	for range n {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if key != "" {
+			req.Header.Set("x-api-key", key)
+		}
+		rec := httptest.NewRecorder()
+		rl.ServeHTTP(rec, req)
+		if rec.Code == http.StatusOK {
+			served++
+		}
+	}
+	return served
+}
+
+func TestSetLimits(t *testing.T) {
+	rl, clock := newTestLimiter(t, 2, 1)
+	if got := allowed(rl, "", 5); got != 2 {
+		t.Fatalf("%d requests allowed, want the bucket size of 2", got)
+	}
+
+	rl.SetLimits(5, 10)
+	clock.Advance(time.Second)
+	if got := allowed(rl, "", 10); got != 5 {
+		t.Errorf("%d requests allowed after raising the limits, want the new size of 5", got)
+	}
+	clock.Advance(200 * time.Millisecond)
+	if got := allowed(rl, "", 10); got != 2 {
+		t.Errorf("%d requests allowed 200ms later, want 2 at the new rate of 10/s", got)
+	}
+
+	// A bucket holding more than a lowered capacity is cut down at once.
+	clock.Advance(time.Second)
+	allowed(rl, "", 1)
+	rl.SetLimits(1, 0)
+	if got := allowed(rl, "", 10); got != 1 {
+		t.Errorf("%d requests allowed after lowering the size to 1", got)
+	}
+	clock.Advance(time.Hour)
+	if got := allowed(rl, "", 10); got != 0 {
+		t.Errorf("%d requests allowed at a refill rate of 0", got)
+	}
+}
+
+func TestOverrideTiers(t *testing.T) {
+	rl, clock := newTestLimiter(t, 2, 1)
+	rl.SetOverrides(map[string]Tier{
+		"premium": {Capacity: 10, RefillRate: 5},
+		"trial":   {Capacity: 1, RefillRate: 0.5},
+	})
+
+	for key, want := range map[string]int{"": 2, "standard": 2, "premium": 10, "trial": 1} {
+		if got := allowed(rl, key, 20); got != want {
+			t.Errorf("key %q: %d requests allowed, want %d", key, got, want)
+		}
+	}
+
+	clock.Advance(time.Second)
+	for key, want := range map[string]int{"standard": 1, "premium": 5, "trial": 0} {
+		if got := allowed(rl, key, 20); got != want {
+			t.Errorf("key %q: %d requests allowed a second later, want %d", key, got, want)
+		}
+	}
+
+	// Dropping an override puts the key back on the defaults.
+	rl.SetOverrides(nil)
+	clock.Advance(time.Hour)
+	if got := allowed(rl, "premium", 20); got != 2 {
+		t.Errorf("%d requests allowed once premium lost its tier, want the default 2", got)
+	}
+}
@@ -0,0 +1,49 @@
+package readiness
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+)
+
+type Handler interface {
+	ServeHTTP(http.ResponseWriter, *http.Request)
+}
+
+type status struct {
+	Status string `json:"status"`
+	Alive  int    `json:"alive"`
+	Total  int    `json:"total"`
+}
+
+// Readiness answers requests for path itself, reporting whether the pool
+// has any backend to route to, and passes everything else through.
+type Readiness struct {
+	path string
+	pool *backend.ServerPool
+	next Handler
+}
+
+func NewReadiness(path string, pool *backend.ServerPool, next Handler) *Readiness {
+	return &Readiness{path: path, pool: pool, next: next}
+}
+
+func (rd *Readiness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != rd.path || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		rd.next.ServeHTTP(w, r)
+		return
+	}
+
+	body := status{Status: "ok", Alive: rd.pool.AliveCount(), Total: rd.pool.Len()}
+	code := http.StatusOK
+	if body.Alive == 0 {
+		body.Status = "unavailable"
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
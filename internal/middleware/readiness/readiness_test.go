@@ -0,0 +1,80 @@
+package readiness
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+)
+
+func testPool(t *testing.T, alive ...bool) *backend.ServerPool {
+	t.Helper()
+	pool := &backend.ServerPool{}
+	for i, a := range alive {
+		u, err := url.Parse(fmt.Sprintf("http://10.0.0.%d:80", i+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := backend.NewBackend(u, 3, time.Second)
+		b.SetAlive(a)
+		pool.Backends = append(pool.Backends, b)
+	}
+	return pool
+}
+
+func TestReadiness(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		alive    []bool
+		wantCode int
+		want     status
+	}{
+		{"all down", []bool{false, false}, http.StatusServiceUnavailable, status{Status: "unavailable", Alive: 0, Total: 2}},
+		{"some up", []bool{false, true, true}, http.StatusOK, status{Status: "ok", Alive: 2, Total: 3}},
+		{"empty pool", nil, http.StatusServiceUnavailable, status{Status: "unavailable"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var proxied bool
+			rd := NewReadiness("/__health", testPool(t, tc.alive...), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				proxied = true
+			}))
+
+			rec := httptest.NewRecorder()
+			rd.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/__health", nil))
+			if proxied {
+				t.Fatal("readiness request was passed upstream")
+			}
+			if rec.Code != tc.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tc.wantCode)
+			}
+			var got status
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("body = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadinessPassesOtherPaths(t *testing.T) {
+	var proxied int
+	rd := NewReadiness("/__health", testPool(t, true), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied++
+	}))
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/health", nil),
+		httptest.NewRequest(http.MethodPost, "/__health", nil),
+	} {
+		rd.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if proxied != 2 {
+		t.Errorf("%d of 2 requests were passed through", proxied)
+	}
+}
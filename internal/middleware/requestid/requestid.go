@@ -0,0 +1,47 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
+)
+
+const Header = "X-Request-ID"
+
+type Handler interface {
+	ServeHTTP(http.ResponseWriter, *http.Request)
+}
+
+type RequestID struct {
+	next Handler
+}
+
+func NewRequestID(next Handler) *RequestID {
+	return &RequestID{next: next}
+}
+
+// ServeHTTP keeps an incoming X-Request-ID or generates one, and carries it
+// on the proxied request, the response and the request context.
+func (rid *RequestID) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get(Header)
+	if id == "" {
+		id = newID()
+		r.Header.Set(Header, id)
+	}
+
+	w.Header().Set(Header, id)
+	ctx := context.WithValue(r.Context(), util.CtxRequestIDKey, id)
+	rid.next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// newID returns a random RFC 4122 version 4 UUID.
+func newID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
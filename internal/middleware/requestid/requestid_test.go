@@ -0,0 +1,49 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
+)
+
+var uuidV4 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+// serve sends a request with the given X-Request-ID, if any, and returns
+// the ID the next handler saw in the header and the context, and the one
+// echoed on the response.
+func serve(incoming string) (upstream, ctx, echoed string) {
+	h := NewRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream = r.Header.Get(Header)
+		ctx = util.GetRequestIDFromContext(r)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if incoming != "" {
+		req.Header.Set(Header, incoming)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return upstream, ctx, rec.Header().Get(Header)
+}
+
+func TestRequestIDPreserved(t *testing.T) {
+	upstream, ctx, echoed := serve("trace-123")
+	if upstream != "trace-123" || ctx != "trace-123" || echoed != "trace-123" {
+		t.Errorf("upstream %q, context %q, response %q; want trace-123 throughout", upstream, ctx, echoed)
+	}
+}
+
+func TestRequestIDGenerated(t *testing.T) {
+	upstream, ctx, echoed := serve("")
+	if !uuidV4.MatchString(echoed) {
+		t.Fatalf("generated ID %q is not a v4 UUID", echoed)
+	}
+	if upstream != echoed || ctx != echoed {
+		t.Errorf("upstream %q, context %q, response %q; want one ID throughout", upstream, ctx, echoed)
+	}
+	if _, _, again := serve(""); again == echoed {
+		t.Error("two requests were given the same ID")
+	}
+}
@@ -0,0 +1,93 @@
+package sticky
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
+)
+
+type Handler interface {
+	ServeHTTP(http.ResponseWriter, *http.Request)
+}
+
+// StickySession pins each client to the backend that first served it. The
+// pin is a cookie naming the backend by its util.StickyID; the proxy sends
+// the request there while that backend can take it and otherwise balances
+// as usual, in which case the cookie is moved to the new backend.
+type StickySession struct {
+	cookieName string
+	ttl        time.Duration
+	next       Handler
+}
+
+func NewStickySession(cookieName string, ttl time.Duration, next Handler) *StickySession {
+	return &StickySession{cookieName: cookieName, ttl: ttl, next: next}
+}
+
+func (s *StickySession) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var pinned string
+	if c, err := r.Cookie(s.cookieName); err == nil {
+		pinned = c.Value
+	}
+
+	// Share the access log's Upstream when there is one; a second one
+	// further in would hide the served backend from it.
+	upstream, ok := r.Context().Value(util.CtxUpstreamKey).(*util.Upstream)
+	ctx := r.Context()
+	if !ok {
+		upstream = &util.Upstream{}
+		ctx = context.WithValue(ctx, util.CtxUpstreamKey, upstream)
+	}
+	if pinned != "" {
+		ctx = context.WithValue(ctx, util.CtxStickyKey, pinned)
+	}
+
+	sw := &stickyWriter{ResponseWriter: w, session: s, r: r, upstream: upstream, pinned: pinned}
+	s.next.ServeHTTP(sw, r.WithContext(ctx))
+}
+
+// stickyWriter sets the cookie just before the header is sent, once the
+// proxy has picked a backend.
+type stickyWriter struct {
+	http.ResponseWriter
+	session     *StickySession
+	r           *http.Request
+	upstream    *util.Upstream
+	pinned      string
+	wroteHeader bool
+}
+
+func (sw *stickyWriter) WriteHeader(code int) {
+	if !sw.wroteHeader && code >= 200 {
+		sw.wroteHeader = true
+		if url := sw.upstream.Get(); url != "" {
+			if id := util.StickyID(url); id != sw.pinned {
+				http.SetCookie(sw.ResponseWriter, &http.Cookie{
+					Name:     sw.session.cookieName,
+					Value:    id,
+					Path:     "/",
+					MaxAge:   int(sw.session.ttl / time.Second),
+					HttpOnly: true,
+					Secure:   sw.r.TLS != nil,
+					SameSite: http.SameSiteLaxMode,
+				})
+			}
+		}
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *stickyWriter) Write(p []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	return sw.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer for
+// flushing and hijacking.
+func (sw *stickyWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
@@ -0,0 +1,36 @@
+package proxy
+
+import (
+	"io"
+	"sync/atomic"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+)
+
+// countingBody charges the bytes of a request body to its backend's
+// in-flight total as they are streamed upstream.
+type countingBody struct {
+	io.ReadCloser
+	backend *backend.Backend
+	read    atomic.Int64
+}
+
+func (cb *countingBody) Read(p []byte) (int, error) {
+	n, err := cb.ReadCloser.Read(p)
+	if n > 0 {
+		cb.read.Add(int64(n))
+		cb.backend.AddBytesInFlight(int64(n))
+	}
+	return n, err
+}
+
+func (cb *countingBody) release() {
+	cb.backend.AddBytesInFlight(-cb.read.Swap(0))
+}
+
+// prefixedBody is a request body whose first bytes were already read into
+// memory; Reader yields them followed by the rest of the original body.
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/metrics"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
+)
+
+// Fallback answers requests that find every backend of their pool down.
+// With Backend set the request is proxied there, whatever its health;
+// otherwise the static page is written.
+type Fallback struct {
+	Backend     *backend.Backend
+	Status      int
+	Body        []byte
+	ContentType string
+}
+
+func (f *Fallback) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.Backend != nil {
+		metrics.IncBackendRequests(f.Backend.URL.String())
+		util.SetUpstream(r, f.Backend.URL.String())
+
+		ctx := r.Context()
+		if f.Backend.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, f.Backend.Timeout)
+			defer cancel()
+		}
+		f.Backend.Serve(w, r.WithContext(ctx), false)
+		return
+	}
+
+	contentType := f.ContentType
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(f.Status)
+	_, _ = w.Write(f.Body)
+}
@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFallback(t *testing.T) {
+	fallbackBackend := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fallback"))
+	}))
+
+	for _, tc := range []struct {
+		name            string
+		fallback        *Fallback
+		wantCode        int
+		wantBody        string
+		wantContentType string
+	}{
+		{
+			"static page",
+			&Fallback{Status: http.StatusServiceUnavailable, Body: []byte("<h1>Back soon</h1>"), ContentType: "text/html"},
+			http.StatusServiceUnavailable, "<h1>Back soon</h1>", "text/html",
+		},
+		{
+			"fallback backend",
+			&Fallback{Backend: fallbackBackend},
+			http.StatusOK, "fallback", "text/plain; charset=utf-8",
+		},
+	} {
+		backends := namedBackends(t, 2)
+		p := newTestProxy(backends...)
+		p.Fallback = tc.fallback
+
+		if got := get(t, p, "/", nil); got != "0" {
+			t.Errorf("%s: %q answered with a backend alive, want backend 0", tc.name, got)
+		}
+
+		for _, b := range backends {
+			b.SetAlive(false)
+		}
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != tc.wantCode || rec.Body.String() != tc.wantBody {
+			t.Errorf("%s: got %d %q, want %d %q", tc.name, rec.Code, rec.Body.String(), tc.wantCode, tc.wantBody)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != tc.wantContentType {
+			t.Errorf("%s: content type %q, want %q", tc.name, ct, tc.wantContentType)
+		}
+	}
+}
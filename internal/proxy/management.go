@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/metrics"
+)
+
+// SetManagement reserves every path under prefix for the load balancer
+// itself. Such requests are served by h, or answered 404 when h doesn't
+// handle them, and are never forwarded to a backend.
+func (p *Proxy) SetManagement(prefix string, h http.Handler) {
+	p.managementPrefix = prefix
+	p.management = h
+}
+
+func (p *Proxy) isManagement(r *http.Request) bool {
+	return p.managementPrefix != "" && strings.HasPrefix(r.URL.Path, p.managementPrefix)
+}
+
+// ManagementHandler serves the built-in endpoints under prefix.
+func (p *Proxy) ManagementHandler(prefix string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+prefix+"status", p.status)
+	mux.HandleFunc("GET "+prefix+"stats", p.stats)
+	return mux
+}
+
+func (p *Proxy) status(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		Alive int `json:"alive"`
+		Total int `json:"total"`
+	}{
+		Alive: p.ServerPool.AliveCount(),
+		Total: p.ServerPool.Len(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+type backendStats struct {
+	URL               string  `json:"url"`
+	Alive             bool    `json:"alive"`
+	Draining          bool    `json:"draining"`
+	ActiveConnections int64   `json:"active_connections"`
+	LatencyEWMAMs     float64 `json:"latency_ewma_ms"`
+	RequestsTotal     uint64  `json:"requests_total"`
+	ErrorsTotal       uint64  `json:"errors_total"`
+	Weight            int     `json:"weight"`
+	// HealthCheckMs is how long the last health probe took.
+	HealthCheckMs       float64 `json:"health_check_ms"`
+	ConsecutiveFailures uint64  `json:"consecutive_failures"`
+}
+
+type stats struct {
+	RequestsTotal    uint64         `json:"requests_total"`
+	RateLimitedTotal uint64         `json:"rate_limited_total"`
+	ShedTotal        uint64         `json:"shed_total"`
+	Alive            int            `json:"alive"`
+	Total            int            `json:"total"`
+	Backends         []backendStats `json:"backends"`
+}
+
+// stats reports a point-in-time snapshot of every backend the proxy can
+// reach, through the default pool or a route's group, and the global
+// counters.
+func (p *Proxy) stats(w http.ResponseWriter, r *http.Request) {
+	var body stats
+	body.RequestsTotal, body.RateLimitedTotal, body.ShedTotal = metrics.Totals()
+	body.Backends = []backendStats{}
+
+	seen := make(map[*backend.ServerPool]bool)
+	pools := []*backend.ServerPool{p.ServerPool}
+	for _, route := range p.routes {
+		pools = append(pools, route.Pool)
+	}
+	for _, pool := range pools {
+		if seen[pool] {
+			continue
+		}
+		seen[pool] = true
+
+		for _, b := range pool.Snapshot() {
+			probes := b.ProbeMetrics()
+			bs := backendStats{
+				URL:                 b.URL.String(),
+				Alive:               b.IsAlive(),
+				Draining:            b.IsDraining(),
+				ActiveConnections:   b.ActiveConnections(),
+				LatencyEWMAMs:       float64(b.LatencyEWMA().Microseconds()) / 1000,
+				Weight:              b.Weight(),
+				HealthCheckMs:       float64(probes.LastDuration.Microseconds()) / 1000,
+				ConsecutiveFailures: probes.ConsecutiveFailures,
+			}
+			bs.RequestsTotal, bs.ErrorsTotal = metrics.BackendTotals(bs.URL)
+			body.Backends = append(body.Backends, bs)
+
+			body.Total++
+			if bs.Alive {
+				body.Alive++
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/algorithms"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/metrics"
+)
+
+func newManagedProxy(t *testing.T) (*Proxy, *int) {
+	t.Helper()
+	var proxied int
+	b := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied++
+		w.Write([]byte("upstream"))
+	}))
+	p := newTestProxy(b)
+	p.SetManagement("/__lb/", p.ManagementHandler("/__lb/"))
+	return p, &proxied
+}
+
+func TestManagementPrefix(t *testing.T) {
+	p, proxied := newManagedProxy(t)
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/__lb/status", nil))
+	var status struct{ Alive, Total int }
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || status.Alive != 1 || status.Total != 1 {
+		t.Errorf("/__lb/status = %d %+v, want 200 with 1/1 alive", rec.Code, status)
+	}
+
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/__lb/unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("/__lb/unknown: status %d, want 404", rec.Code)
+	}
+	if *proxied != 0 {
+		t.Fatalf("%d management requests reached the backend", *proxied)
+	}
+
+	if got := get(t, p, "/anything-else", nil); got != "upstream" || *proxied != 1 {
+		t.Errorf("/anything-else got %q, want it proxied", got)
+	}
+}
+
+func TestStats(t *testing.T) {
+	ok := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	failing := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	down := newTestBackend(t, http.NotFoundHandler())
+	down.SetAlive(false)
+	ok.SetWeight(3)
+	withConnections(ok, 2)
+	defer func() {
+		for range 2 {
+			ok.DecrementConnections()
+		}
+	}()
+
+	p := NewProxy(&backend.ServerPool{Backends: []*backend.Backend{ok, failing, down}}, &algorithms.RoundRobin{}, 1)
+	p.SetManagement("/__lb/", p.ManagementHandler("/__lb/"))
+	before, _, _ := metrics.Totals()
+	// Round robin sends these to ok, failing, ok, failing.
+	for range 4 {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/__lb/stats", nil))
+	if ct := rec.Header().Get("Content-Type"); rec.Code != http.StatusOK || ct != "application/json" {
+		t.Fatalf("status %d, content type %q", rec.Code, ct)
+	}
+
+	var shape map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &shape); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"requests_total", "rate_limited_total", "shed_total", "alive", "total", "backends"} {
+		if _, found := shape[key]; !found {
+			t.Errorf("stats has no %q", key)
+		}
+	}
+	backendShape := shape["backends"].([]any)[0].(map[string]any)
+	for _, key := range []string{"url", "alive", "draining", "active_connections", "latency_ewma_ms", "requests_total", "errors_total", "weight", "health_check_ms", "consecutive_failures"} {
+		if _, found := backendShape[key]; !found {
+			t.Errorf("backend stats have no %q", key)
+		}
+	}
+
+	var got stats
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.RequestsTotal-before != 4 || got.Alive != 2 || got.Total != 3 || len(got.Backends) != 3 {
+		t.Fatalf("stats = %+v, want 4 new requests and 2 of 3 backends alive", got)
+	}
+	first, second, third := got.Backends[0], got.Backends[1], got.Backends[2]
+	if first.URL != ok.URL.String() || !first.Alive || first.ActiveConnections != 2 || first.Weight != 3 ||
+		first.RequestsTotal != 2 || first.ErrorsTotal != 0 || first.LatencyEWMAMs <= 0 {
+		t.Errorf("healthy backend stats = %+v", first)
+	}
+	if second.RequestsTotal != 2 || second.ErrorsTotal != 2 {
+		t.Errorf("failing backend stats = %+v, want 2 requests and 2 errors", second)
+	}
+	if third.Alive || third.RequestsTotal != 0 {
+		t.Errorf("down backend stats = %+v", third)
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	p, proxied := newManagedProxy(t)
+	p.SetMaintenance(true)
+	if !p.InMaintenance() {
+		t.Fatal("maintenance mode did not turn on")
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("in maintenance: status %d, Retry-After %q; want 503 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if *proxied != 0 {
+		t.Fatal("request reached the backend in maintenance mode")
+	}
+
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/__lb/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("management endpoint in maintenance: status %d, want 200", rec.Code)
+	}
+
+	p.Maintenance = &Fallback{Status: http.StatusServiceUnavailable, Body: []byte("down for maintenance")}
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "down for maintenance" {
+		t.Errorf("maintenance page: got %d %q", rec.Code, rec.Body.String())
+	}
+
+	p.SetMaintenance(false)
+	if got := get(t, p, "/", nil); got != "upstream" {
+		t.Errorf("after maintenance got %q, want it proxied", got)
+	}
+}
@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/logger"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
+)
+
+// mirrorInflight caps the shadow requests outstanding at once; samples
+// taken while the cap is reached are dropped rather than queued.
+const mirrorInflight = 64
+
+// Mirror copies a sample of live requests to a shadow backend. Copies are
+// sent in the background with their own deadline and their responses are
+// discarded, so the shadow can neither slow down nor fail the real request.
+type Mirror struct {
+	Target *url.URL
+	// Percent is the share of requests mirrored, from 0 to 100.
+	Percent float64
+	client  *http.Client
+	sem     chan struct{}
+}
+
+func NewMirror(target *url.URL, percent float64, timeout time.Duration) *Mirror {
+	return &Mirror{
+		Target:  target,
+		Percent: percent,
+		client: &http.Client{
+			Timeout: timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		sem: make(chan struct{}, mirrorInflight),
+	}
+}
+
+// Send mirrors r if it is sampled. Requests whose body was not buffered
+// for replay are skipped, since the primary request needs the only copy.
+func (m *Mirror) Send(r *http.Request) {
+	if util.HasBody(r) && r.GetBody == nil {
+		return
+	}
+	if rand.Float64()*100 >= m.Percent {
+		return
+	}
+
+	select {
+	case m.sem <- struct{}{}:
+	default:
+		logger.Debug("mirror busy, dropping sample", "path", r.URL.Path)
+		return
+	}
+
+	shadow := r.Clone(context.Background())
+	shadow.RequestURI = ""
+	shadow.URL.Scheme = m.Target.Scheme
+	shadow.URL.Host = m.Target.Host
+	shadow.Host = m.Target.Host
+	shadow.Body = http.NoBody
+	if r.GetBody != nil {
+		if body, err := r.GetBody(); err == nil {
+			shadow.Body = body
+		}
+	}
+
+	go func() {
+		defer func() { <-m.sem }()
+
+		resp, err := m.client.Do(shadow)
+		if err != nil {
+			logger.Debug("mirror request failed", "target", m.Target.String(), "path", shadow.URL.Path, "error", err)
+			return
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+}
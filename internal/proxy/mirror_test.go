@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitIdle waits for m's background requests to finish.
+func waitIdle(t *testing.T, m *Mirror) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(m.sem) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d mirror requests still in flight", len(m.sem))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMirrorSamples(t *testing.T) {
+	var mirrored atomic.Int32
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrored.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer shadow.Close()
+	target, err := url.Parse(shadow.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := newTestProxy(namedBackends(t, 1)...)
+	p.Mirror = NewMirror(target, 25, time.Second)
+
+	const n = 400
+	for range n {
+		if got := get(t, p, "/", nil); got != "0" {
+			t.Fatalf("client got %q, want the primary's answer", got)
+		}
+	}
+	waitIdle(t, p.Mirror)
+
+	// 25% of 400 is 100, with a standard deviation of about 9.
+	if got := mirrored.Load(); got < 60 || got > 140 {
+		t.Errorf("shadow received %d of %d requests, want about 100", got, n)
+	}
+}
+
+func TestMirrorFailureDoesNotAffectClient(t *testing.T) {
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	target, err := url.Parse(dead.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := newTestProxy(namedBackends(t, 1)...)
+	p.Mirror = NewMirror(target, 100, time.Second)
+	for range 20 {
+		if got := get(t, p, "/", nil); got != "0" {
+			t.Fatalf("client got %q, want the primary's answer", got)
+		}
+	}
+	waitIdle(t, p.Mirror)
+}
@@ -1,52 +1,497 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/algorithms"
 	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/logger"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/metrics"
+	ratelimiter "github.com/soham0w0sarkar/LoadBalancerGo.git/internal/middleware/rateLimiter"
 	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
 )
 
+// DefaultRetryMethods are the idempotent methods retried unless configured
+// otherwise.
+var DefaultRetryMethods = []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete}
+
+// StatusClientClosedRequest is the non-standard status nginx uses when the
+// client goes away before a response could be produced.
+const StatusClientClosedRequest = 499
+
+type Route struct {
+	// Host and PathPrefix must both match when set.
+	Host       string
+	PathPrefix string
+	// Pool is the backend group the route sends traffic to; nil means the
+	// proxy's default ServerPool.
+	Pool *backend.ServerPool
+	// Balancer picks the route's backend; nil means the proxy's default
+	// balancer, so the route follows SetBalancer.
+	Balancer algorithms.Balancer
+	// Limit caps the total request rate of the route's backend group; nil
+	// means unlimited.
+	Limit *ratelimiter.Limit
+	// MaxBodyBytes overrides the proxy's MaxBodyBytes; 0 keeps it.
+	MaxBodyBytes int64
+	// Rewrite changes the path sent upstream; nil forwards it unchanged.
+	Rewrite *PathRewrite
+}
+
 type Proxy struct {
 	ServerPool *backend.ServerPool
-	Balancer   algorithms.Balancer
+	Limit      *ratelimiter.Limit
+	// ErrorPage is written with the errors the proxy generates itself; nil
+	// means a plain-text message.
+	ErrorPage *util.ErrorPage
+	// MaxBodyBytes caps request bodies; larger ones get 413. 0 means
+	// unlimited.
+	MaxBodyBytes int64
+	// RetryBodyBytes is the largest request body kept in memory so the
+	// request can be retried; larger bodies are streamed and sent once.
+	// 0 disables buffering.
+	RetryBodyBytes int64
+	// RetryMethods are the methods that may be sent to a backend more than
+	// once.
+	RetryMethods []string
+	// Fallback serves requests whose pool has no alive backend; nil means
+	// a 503.
+	Fallback *Fallback
+	// Mirror receives a copy of a sample of requests; nil disables
+	// mirroring.
+	Mirror *Mirror
+	// Maintenance answers every request while maintenance mode is on; nil
+	// means a 503.
+	Maintenance *Fallback
+	maxAttempts int
+	maintenance atomic.Bool
+	routes      []Route
+
+	balancerMux sync.RWMutex
+	balancer    algorithms.Balancer
+
+	managementPrefix string
+	management       http.Handler
 }
 
-func NewProxy(s *backend.ServerPool, b algorithms.Balancer) *Proxy {
+func NewProxy(s *backend.ServerPool, b algorithms.Balancer, maxAttempts int) *Proxy {
+	if maxAttempts < 1 {
+		maxAttempts = 3
+	}
 	return &Proxy{
-		ServerPool: s,
-		Balancer:   b,
+		ServerPool:   s,
+		balancer:     b,
+		maxAttempts:  maxAttempts,
+		RetryMethods: DefaultRetryMethods,
 	}
 }
 
+// SetMaintenance turns maintenance mode on or off. While it is on every
+// request except management ones is answered by Maintenance; health checks
+// keep running so traffic can resume as soon as it is switched off.
+func (p *Proxy) SetMaintenance(on bool) {
+	p.maintenance.Store(on)
+}
+
+func (p *Proxy) InMaintenance() bool {
+	return p.maintenance.Load()
+}
+
+// Balancer returns the default balancer.
+func (p *Proxy) Balancer() algorithms.Balancer {
+	p.balancerMux.RLock()
+	defer p.balancerMux.RUnlock()
+	return p.balancer
+}
+
+// SetBalancer swaps the default balancer. Requests that already picked a
+// backend are unaffected; later selections use b.
+func (p *Proxy) SetBalancer(b algorithms.Balancer) {
+	p.balancerMux.Lock()
+	p.balancer = b
+	p.balancerMux.Unlock()
+}
+
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	backends := p.ServerPool.GetBackends()
+	if p.isManagement(r) {
+		p.management.ServeHTTP(w, r)
+		return
+	}
+
+	if p.maintenance.Load() && util.GetAttemptsFromContext(r) == 0 {
+		metrics.IncRequests()
+		if p.Maintenance != nil {
+			p.Maintenance.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Retry-After", "60")
+		util.WriteError(w, p.ErrorPage, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
 
 	attempts := util.GetAttemptsFromContext(r)
-	if attempts > 3 {
-		fmt.Printf("%s(%s) Max attempts reached, terminating\n", r.RemoteAddr, r.URL.Path)
-		http.Error(w, "Service not available", http.StatusServiceUnavailable)
+	if attempts == 0 {
+		metrics.IncRequests()
+	}
+
+	if r.Context().Err() != nil {
+		logger.Debug("client closed request before backend selection", "client", r.RemoteAddr, "path", r.URL.Path)
+		w.WriteHeader(StatusClientClosedRequest)
 		return
 	}
 
-	backend, err := p.Balancer.Select(backends)
+	// Every attempt so far reached a backend that failed to respond.
+	if attempts >= p.maxAttempts {
+		logger.Warn("max attempts reached", "request_id", util.GetRequestIDFromContext(r), "client", r.RemoteAddr, "path", r.URL.Path, "status", http.StatusBadGateway)
+		util.WriteError(w, p.ErrorPage, "Bad Gateway", http.StatusBadGateway)
+		return
+	}
 
-	if err != nil {
-		http.Error(w, "Failed to select backend", http.StatusInternalServerError)
+	route := p.routeFor(r)
+	if attempts == 0 {
+		if !p.limitBody(w, r, route) || !p.bufferBody(w, r) {
+			return
+		}
+		if p.Mirror != nil {
+			p.Mirror.Send(r)
+		}
+	} else if r.GetBody != nil {
+		// Later attempts replay the buffered body from the start.
+		if body, err := r.GetBody(); err == nil {
+			r.Body = body
+		}
+	}
+	if route.Limit != nil && !route.Limit.Allow() {
+		metrics.IncRateLimited()
+		util.WriteError(w, p.ErrorPage, "Backend group over capacity", http.StatusServiceUnavailable)
 		return
 	}
 
-	if backend.Timeout > 0 {
-		ctx, cancel := context.WithTimeout(r.Context(), backend.Timeout)
-		defer cancel()
-		ctx = context.WithValue(ctx, util.CtxAttemptsKey, attempts+1)
-		backend.ReverseProxy.ServeHTTP(w, r.WithContext(ctx))
+	tried := util.GetTriedFromContext(r)
+	snapshot := route.Pool.Snapshot()
+	backends := slices.DeleteFunc(slices.Clone(snapshot), func(b *backend.Backend) bool {
+		return slices.Contains(tried, b.URL.String())
+	})
+	backends = backend.ActiveTier(backends)
+
+	balancer := route.Balancer
+	if balancer == nil {
+		balancer = p.Balancer()
+	}
+
+	var backend *backend.Backend
+	var err error
+	if sb, ok := balancer.(algorithms.StableListBalancer); ok {
+		backend, err = acquireStable(sb, r, backends, stableTier(snapshot, backends))
+	} else {
+		backend, err = acquireBackend(balancer, r, backends)
+	}
+	if err == errAllSaturated {
+		util.WriteError(w, p.ErrorPage, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil && p.Fallback != nil && route.Pool.AliveCount() == 0 {
+		p.Fallback.ServeHTTP(w, r)
+		return
+	}
+	if err != nil {
+		// With backends already tried this is the tail of a failover: the
+		// request did reach upstreams, and they failed.
+		if len(tried) > 0 {
+			util.WriteError(w, p.ErrorPage, "Bad Gateway", http.StatusBadGateway)
+			return
+		}
+		util.WriteError(w, p.ErrorPage, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if !p.serveBackend(w, r, route, backend, attempts) {
 		return
 	}
 
+	// The backend has given its slot back, so failover runs without it
+	// still counting the request as in flight.
+	metrics.IncFailovers()
 	ctx := context.WithValue(r.Context(), util.CtxAttemptsKey, attempts+1)
-	backend.ReverseProxy.ServeHTTP(w, r.WithContext(ctx))
+	ctx = context.WithValue(ctx, util.CtxTriedKey, append(tried, backend.URL.String()))
+	p.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// serveBackend sends r to b, which holds a connection slot for it, and
+// gives the slot back once b is done. It reports whether b failed to
+// answer and the request should fail over to another backend; nothing has
+// been written to w then, and the failed attempt's latency isn't recorded.
+func (p *Proxy) serveBackend(w http.ResponseWriter, r *http.Request, route Route, b *backend.Backend, attempts int) (failed bool) {
+	defer b.DecrementConnections()
+
+	if r.ContentLength > 0 {
+		b.AddBytesInFlight(r.ContentLength)
+		defer b.AddBytesInFlight(-r.ContentLength)
+	} else if util.HasBody(r) {
+		body := &countingBody{ReadCloser: r.Body, backend: b}
+		defer body.release()
+		r.Body = body
+	}
+
+	metrics.IncBackendRequests(b.URL.String())
+	util.SetUpstream(r, b.URL.String())
+
+	if util.IsUpgradeRequest(r) {
+		// Upgraded connections are long-lived: the backend timeout would tear
+		// the tunnel down, and the server's read/write deadlines must not
+		// apply to the socket either.
+		rc := http.NewResponseController(w)
+		_ = rc.SetReadDeadline(time.Time{})
+		_ = rc.SetWriteDeadline(time.Time{})
+
+		ctx, unbind := b.Bind(r.Context())
+		defer unbind()
+		ctx = context.WithValue(ctx, util.CtxAttemptsKey, attempts+1)
+		b.Serve(w, r.WithContext(ctx), false)
+		return false
+	}
+
+	ctx, unbind := b.Bind(r.Context())
+	defer unbind()
+	if b.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.Timeout)
+		defer cancel()
+	}
+	ctx = context.WithValue(ctx, util.CtxAttemptsKey, attempts+1)
+	canReplay := p.canReplay(r)
+	if !canReplay {
+		ctx = context.WithValue(ctx, util.CtxNoReplayKey, true)
+	}
+
+	out := r.WithContext(ctx)
+	if route.Rewrite != nil {
+		// Only the outgoing request is rewritten; failover goes back
+		// through routeFor with the original path.
+		out.URL = route.Rewrite.apply(r.URL)
+	}
+
+	start := time.Now()
+	if b.Serve(w, out, canReplay) {
+		return true
+	}
+	elapsed := time.Since(start)
+	b.RecordLatency(elapsed)
+	metrics.ObserveBackendLatency(b.URL.String(), elapsed)
+	return false
+}
+
+// limitBody enforces the route's body size limit. A declared length over
+// the limit is rejected up front; otherwise the body is capped so that a
+// body that grows past the limit fails the upstream request with 413.
+func (p *Proxy) limitBody(w http.ResponseWriter, r *http.Request, route Route) bool {
+	limit := p.MaxBodyBytes
+	if route.MaxBodyBytes > 0 {
+		limit = route.MaxBodyBytes
+	}
+	if limit <= 0 || !util.HasBody(r) {
+		return true
+	}
+
+	if r.ContentLength > limit {
+		util.WriteError(w, p.ErrorPage, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	return true
+}
+
+// canReplay reports whether r can be sent again after a failed attempt: its
+// method must be retryable, and any body must have been buffered.
+func (p *Proxy) canReplay(r *http.Request) bool {
+	return slices.Contains(p.RetryMethods, r.Method) && (!util.HasBody(r) || r.GetBody != nil)
+}
+
+// bufferBody reads a retryable request's body into memory when it fits in
+// RetryBodyBytes and sets GetBody so every attempt can send it in full. A
+// body that doesn't fit is passed through as-is and can't be retried.
+func (p *Proxy) bufferBody(w http.ResponseWriter, r *http.Request) bool {
+	if p.RetryBodyBytes <= 0 || !util.HasBody(r) || !slices.Contains(p.RetryMethods, r.Method) {
+		return true
+	}
+	if r.ContentLength > p.RetryBodyBytes {
+		return true
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(r.Body, p.RetryBodyBytes+1))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			util.WriteError(w, p.ErrorPage, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return false
+		}
+		util.WriteError(w, p.ErrorPage, "Bad Request", http.StatusBadRequest)
+		return false
+	}
+
+	if int64(len(buf)) > p.RetryBodyBytes {
+		r.Body = &prefixedBody{Reader: io.MultiReader(bytes.NewReader(buf), r.Body), Closer: r.Body}
+		return true
+	}
+
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf)), nil
+	}
+	r.Body, _ = r.GetBody()
+	return true
+}
+
+// AddRoute sends requests matching route's host and path prefix to its
+// pool, balancer and group limit. More specific routes win: a host match
+// outranks a path-only match, and longer prefixes outrank shorter ones.
+func (p *Proxy) AddRoute(route Route) {
+	if route.Pool == nil {
+		route.Pool = p.ServerPool
+	}
+	p.routes = append(p.routes, route)
+	sort.SliceStable(p.routes, func(i, j int) bool {
+		return specificity(p.routes[i]) > specificity(p.routes[j])
+	})
+}
+
+func specificity(route Route) int {
+	score := len(route.PathPrefix)
+	if route.Host != "" {
+		score += 1 << 16
+	}
+	return score
+}
+
+func (p *Proxy) routeFor(r *http.Request) Route {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	for _, route := range p.routes {
+		if route.Host != "" && !strings.EqualFold(route.Host, host) {
+			continue
+		}
+		if !strings.HasPrefix(r.URL.Path, route.PathPrefix) {
+			continue
+		}
+		return route
+	}
+	return Route{Pool: p.ServerPool, Limit: p.Limit}
+}
+
+// pinnedBackend returns the backend named by the request's sticky session,
+// if it is among backends and may take a new request.
+func pinnedBackend(r *http.Request, backends []*backend.Backend) *backend.Backend {
+	id := util.GetStickyFromContext(r)
+	if id == "" {
+		return nil
+	}
+	for _, b := range backends {
+		if util.StickyID(b.URL.String()) == id && b.Eligible() {
+			return b
+		}
+	}
+	return nil
+}
+
+var errAllSaturated = errors.New("all backends at their concurrency limit")
+
+// acquireBackend selects a backend and takes one of its connection slots.
+// Backends at their MaxConcurrent limit are withheld from the balancer, so it
+// falls through to the next candidate as it would for a dead backend.
+func acquireBackend(balancer algorithms.Balancer, r *http.Request, backends []*backend.Backend) (*backend.Backend, error) {
+	if b := pinnedBackend(r, backends); b != nil && b.TryAcquire() {
+		return b, nil
+	}
+
+	candidates := make([]*backend.Backend, 0, len(backends))
+	saturated := false
+	for _, b := range backends {
+		if b.Saturated() {
+			saturated = true
+			continue
+		}
+		candidates = append(candidates, b)
+	}
+
+	for len(candidates) > 0 {
+		b, err := selectBackend(balancer, r, candidates)
+		if err != nil {
+			break
+		}
+		if b.TryAcquire() {
+			return b, nil
+		}
+		// Lost the race for the last slot; try the others.
+		saturated = true
+		candidates = slices.DeleteFunc(candidates, func(c *backend.Backend) bool { return c == b })
+	}
+
+	if saturated {
+		return nil, errAllSaturated
+	}
+	return nil, fmt.Errorf("no Backend found alive")
+}
+
+// stableTier returns every backend of snapshot in the same priority tier as
+// backends, whether or not it has been tried, so a StableListBalancer sees
+// the same list on each attempt.
+func stableTier(snapshot, backends []*backend.Backend) []*backend.Backend {
+	if len(backends) == 0 {
+		return nil
+	}
+	tier := make([]*backend.Backend, 0, len(snapshot))
+	for _, b := range snapshot {
+		if b.Priority == backends[0].Priority {
+			tier = append(tier, b)
+		}
+	}
+	return tier
+}
+
+// acquireStable is acquireBackend for a StableListBalancer. The balancer
+// always sees the whole tier and walks past backends that are saturated or
+// already tried, so a backend filling up doesn't remap every other client.
+func acquireStable(balancer algorithms.StableListBalancer, r *http.Request, backends, tier []*backend.Backend) (*backend.Backend, error) {
+	if b := pinnedBackend(r, backends); b != nil && b.TryAcquire() {
+		return b, nil
+	}
+
+	saturated := slices.ContainsFunc(backends, (*backend.Backend).Saturated)
+	for range tier {
+		b, err := balancer.SelectFor(r, tier)
+		if err != nil {
+			break
+		}
+		if b.TryAcquire() {
+			return b, nil
+		}
+		// Lost the race for the last slot; b now reads as saturated, so the
+		// balancer walks past it next time.
+		saturated = true
+	}
+
+	if saturated {
+		return nil, errAllSaturated
+	}
+	return nil, fmt.Errorf("no Backend found alive")
+}
+
+func selectBackend(balancer algorithms.Balancer, r *http.Request, backends []*backend.Backend) (*backend.Backend, error) {
+	if rb, ok := balancer.(algorithms.RequestBalancer); ok {
+		return rb.SelectFor(r, backends)
+	}
+	return balancer.Select(backends)
 }
@@ -0,0 +1,709 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/algorithms"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+	ratelimiter "github.com/soham0w0sarkar/LoadBalancerGo.git/internal/middleware/rateLimiter"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
+)
+
+func newTestBackend(t *testing.T, h http.Handler) *backend.Backend {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := backend.NewBackend(u, 3, time.Second)
+	b.SetAlive(true)
+	return b
+}
+
+// namedBackends returns n backends that answer with their own index.
+func namedBackends(t *testing.T, n int) []*backend.Backend {
+	t.Helper()
+	backends := make([]*backend.Backend, n)
+	for i := range backends {
+		backends[i] = newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, i)
+		}))
+	}
+	return backends
+}
+
+// withConnections opens n connection slots on b.
+func withConnections(b *backend.Backend, n int) {
+	for range n {
+		b.IncrementConnections()
+	}
+}
+
+// get sends a GET for target through h and returns the response body.
+func get(t *testing.T, h http.Handler, target string, header http.Header) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: status %d", target, rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func newTestProxy(backends ...*backend.Backend) *Proxy {
+	return NewProxy(&backend.ServerPool{Backends: backends}, &algorithms.RoundRobin{}, 3)
+}
+
+func TestTimeoutFailsOver(t *testing.T) {
+	slow := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	slow.Timeout = 50 * time.Millisecond
+
+	var slowActive int64 = -1
+	fast := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slowActive = slow.ActiveConnections()
+		w.Write([]byte("fast"))
+	}))
+
+	// Round robin starts with the first backend, so the slow one is tried first.
+	p := newTestProxy(slow, fast)
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "fast" {
+		t.Fatalf("got %d %q, want the fast backend's answer", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("request took %v; the slow backend was not cut off", elapsed)
+	}
+	if slowActive != 0 {
+		t.Errorf("slow backend held %d connections during failover, want 0", slowActive)
+	}
+	if d := slow.LatencyEWMA(); d != 0 {
+		t.Errorf("slow backend recorded latency %v for a failed attempt", d)
+	}
+	if fast.LatencyEWMA() == 0 {
+		t.Error("fast backend recorded no latency")
+	}
+}
+
+func TestNoFailoverForNonRetryableMethod(t *testing.T) {
+	slow := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	slow.Timeout = 50 * time.Millisecond
+	var reached bool
+	fast := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	p := newTestProxy(slow, fast)
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502", rec.Code)
+	}
+	if reached {
+		t.Error("a POST was failed over to another backend")
+	}
+	if slow.ActiveConnections() != 0 {
+		t.Errorf("slow backend still holds %d connections", slow.ActiveConnections())
+	}
+}
+
+func TestRouteStrategyOverrides(t *testing.T) {
+	backends := namedBackends(t, 3)
+	p := newTestProxy(backends...)
+	p.AddRoute(Route{PathPrefix: "/cache/", Balancer: algorithms.NewConsistentHash("header:X-Key")})
+	p.AddRoute(Route{PathPrefix: "/compute/", Balancer: &algorithms.LeastConnection{}})
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		header := http.Header{"X-Key": {key}}
+		first := get(t, p, "/cache/item", header)
+		for range 5 {
+			if got := get(t, p, "/cache/item", header); got != first {
+				t.Fatalf("key %s went to backend %s, then %s", key, first, got)
+			}
+		}
+	}
+
+	// The routes share the pool's backends, so connections held on them
+	// are what least-conn sees.
+	backends[0].IncrementConnections()
+	backends[1].IncrementConnections()
+	defer backends[0].DecrementConnections()
+	defer backends[1].DecrementConnections()
+	for range 5 {
+		if got := get(t, p, "/compute/job", nil); got != "2" {
+			t.Fatalf("/compute/ went to backend %s, want the idle backend 2", got)
+		}
+	}
+
+	// Everything else still follows the default round robin.
+	seen := map[string]bool{}
+	for range 3 {
+		seen[get(t, p, "/other", nil)] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("default route reached %d backends, want all 3", len(seen))
+	}
+}
+
+func TestBytesInFlight(t *testing.T) {
+	var during int64
+	var b *backend.Backend
+	b = newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		during = b.BytesInFlight()
+	}))
+	p := newTestProxy(b)
+
+	for name, body := range map[string]io.Reader{
+		"content-length": strings.NewReader(strings.Repeat("x", 4096)),
+		// Hides the length, so the body is counted as it is streamed.
+		"streamed": io.MultiReader(strings.NewReader(strings.Repeat("x", 4096))),
+	} {
+		during = 0
+		req := httptest.NewRequest(http.MethodPost, "/upload", body)
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d", name, rec.Code)
+		}
+		if during != 4096 {
+			t.Errorf("%s: %d bytes in flight during the upload, want 4096", name, during)
+		}
+		if got := b.BytesInFlight(); got != 0 {
+			t.Errorf("%s: %d bytes still in flight after the upload", name, got)
+		}
+	}
+}
+
+// countingBalancer counts the selections made through it.
+type countingBalancer struct {
+	algorithms.RoundRobin
+	calls int
+}
+
+func (cb *countingBalancer) Select(backends []*backend.Backend) (*backend.Backend, error) {
+	cb.calls++
+	return cb.RoundRobin.Select(backends)
+}
+
+func TestCancelledRequestSkipsSelection(t *testing.T) {
+	var reached bool
+	b := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	balancer := &countingBalancer{}
+	p := NewProxy(&backend.ServerPool{Backends: []*backend.Backend{b}}, balancer, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	if rec.Code != StatusClientClosedRequest {
+		t.Errorf("status = %d, want %d", rec.Code, StatusClientClosedRequest)
+	}
+	if balancer.calls != 0 || reached {
+		t.Errorf("a cancelled request made %d selections (reached backend: %v)", balancer.calls, reached)
+	}
+	if b.ActiveConnections() != 0 {
+		t.Errorf("backend holds %d connections", b.ActiveConnections())
+	}
+}
+
+// echoUpgrade accepts a WebSocket handshake and echoes every byte the
+// client sends afterwards.
+var echoUpgrade = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		!strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade") {
+		http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+		return
+	}
+	sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	rw.Flush()
+	io.Copy(conn, rw)
+})
+
+func TestWebSocketPassthrough(t *testing.T) {
+	b := newTestBackend(t, echoUpgrade)
+	b.Timeout = 50 * time.Millisecond
+	lb := httptest.NewUnstartedServer(newTestProxy(b))
+	lb.Config.WriteTimeout = 50 * time.Millisecond
+	lb.Config.ReadTimeout = 50 * time.Millisecond
+	lb.Start()
+	defer lb.Close()
+
+	conn, err := net.Dial("tcp", lb.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: lb\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Sec-WebSocket-Accept = %q", got)
+	}
+
+	// Each exchange outlasts the server's and the backend's timeouts, which
+	// must not apply to the upgraded connection.
+	for _, msg := range []string{"hello", "again"} {
+		time.Sleep(100 * time.Millisecond)
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+		if _, err := io.WriteString(conn, msg); err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, len(msg))
+		if _, err := io.ReadFull(br, got); err != nil {
+			t.Fatalf("reading echo of %q: %v", msg, err)
+		}
+		if string(got) != msg {
+			t.Errorf("echo = %q, want %q", got, msg)
+		}
+	}
+}
+
+func TestGroupLimit(t *testing.T) {
+	legacy := namedBackends(t, 1)[0]
+	api := namedBackends(t, 1)[0]
+	p := newTestProxy(api)
+	p.AddRoute(Route{PathPrefix: "/legacy/", Pool: &backend.ServerPool{Backends: []*backend.Backend{legacy}}, Limit: ratelimiter.NewLimit(2, 0)})
+	p.AddRoute(Route{PathPrefix: "/api/"})
+
+	codes := map[string][]int{}
+	for range 4 {
+		for _, path := range []string{"/legacy/x", "/api/x"} {
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			codes[path] = append(codes[path], rec.Code)
+		}
+	}
+
+	if want := []int{200, 200, 503, 503}; !slices.Equal(codes["/legacy/x"], want) {
+		t.Errorf("capped group got %v, want %v", codes["/legacy/x"], want)
+	}
+	if want := []int{200, 200, 200, 200}; !slices.Equal(codes["/api/x"], want) {
+		t.Errorf("uncapped group got %v, want %v", codes["/api/x"], want)
+	}
+}
+
+// deadBackend returns a backend whose address refuses connections.
+func deadBackend(t *testing.T) *backend.Backend {
+	t.Helper()
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := backend.NewBackend(u, 3, time.Second)
+	b.SetAlive(true)
+	return b
+}
+
+func TestMaxAttempts(t *testing.T) {
+	for _, tc := range []struct {
+		maxAttempts int
+		wantCode    int
+		wantReached bool
+	}{
+		{1, http.StatusBadGateway, false},
+		{2, http.StatusOK, true},
+	} {
+		var reached bool
+		healthy := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reached = true
+		}))
+		dead := deadBackend(t)
+		p := NewProxy(&backend.ServerPool{Backends: []*backend.Backend{dead, healthy}}, &algorithms.RoundRobin{}, tc.maxAttempts)
+
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != tc.wantCode || reached != tc.wantReached {
+			t.Errorf("max attempts %d: status %d, second backend reached %v; want %d, %v",
+				tc.maxAttempts, rec.Code, reached, tc.wantCode, tc.wantReached)
+		}
+	}
+}
+
+func TestNoBackendIs503(t *testing.T) {
+	down := newTestBackend(t, http.NotFoundHandler())
+	down.SetAlive(false)
+	p := NewProxy(&backend.ServerPool{Backends: []*backend.Backend{down}}, &algorithms.RoundRobin{}, 1)
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503 with no backend to select", rec.Code)
+	}
+}
+
+func TestErrorPage(t *testing.T) {
+	page := &util.ErrorPage{Body: `{"status":{{status}},"error":"{{message}}"}`, ContentType: "application/json"}
+	down := newTestBackend(t, http.NotFoundHandler())
+	down.SetAlive(false)
+	dead := deadBackend(t)
+	dead.ErrorPage = page
+
+	for _, tc := range []struct {
+		name     string
+		backend  *backend.Backend
+		wantCode int
+		wantBody string
+	}{
+		{"upstream failed", dead, http.StatusBadGateway, `{"status":502,"error":"Bad Gateway"}`},
+		{"no backend", down, http.StatusServiceUnavailable, `{"status":503,"error":"Service Unavailable"}`},
+	} {
+		p := NewProxy(&backend.ServerPool{Backends: []*backend.Backend{tc.backend}}, &algorithms.RoundRobin{}, 1)
+		p.ErrorPage = page
+
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != tc.wantCode || rec.Body.String() != tc.wantBody {
+			t.Errorf("%s: got %d %q, want %d %q", tc.name, rec.Code, rec.Body.String(), tc.wantCode, tc.wantBody)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: content type %q, want application/json", tc.name, ct)
+		}
+	}
+}
+
+func TestSaturatedBackendSkipped(t *testing.T) {
+	backends := namedBackends(t, 3)
+	backends[0].MaxConcurrent = 1
+	backends[0].IncrementConnections()
+	p := newTestProxy(backends...)
+
+	counts := map[string]int{}
+	for range 6 {
+		counts[get(t, p, "/", nil)]++
+	}
+	if counts["0"] != 0 || counts["1"] != 3 || counts["2"] != 3 {
+		t.Errorf("requests per backend = %v, want the saturated backend 0 skipped and the rest split evenly", counts)
+	}
+
+	backends[0].DecrementConnections()
+	if got := get(t, p, "/", nil); got != "0" {
+		t.Errorf("backend %s answered, want 0 once it has a free slot", got)
+	}
+}
+
+func TestAllSaturatedIs503(t *testing.T) {
+	backends := namedBackends(t, 2)
+	for _, b := range backends {
+		b.MaxConcurrent = 2
+		withConnections(b, 2)
+	}
+	p := newTestProxy(backends...)
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+	for i, b := range backends {
+		if b.ActiveConnections() != 2 {
+			t.Errorf("backend %d holds %d connections, want its 2", i, b.ActiveConnections())
+		}
+	}
+}
+
+func TestRemovalDrainsInFlightRequest(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	b := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	}))
+	p := newTestProxy(b)
+
+	rec := httptest.NewRecorder()
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	<-started
+
+	removed := make(chan struct{})
+	go func() {
+		defer close(removed)
+		p.ServerPool.RemoveBackends([]string{b.URL.String()})
+	}()
+
+	select {
+	case <-removed:
+		t.Fatal("RemoveBackends returned with a request still in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if !b.IsDraining() {
+		t.Error("backend being removed is not draining")
+	}
+
+	close(release)
+	<-served
+	if rec.Code != http.StatusOK || rec.Body.String() != "done" {
+		t.Errorf("in-flight request got %d %q, want it to complete", rec.Code, rec.Body.String())
+	}
+	select {
+	case <-removed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("RemoveBackends did not return once the request finished")
+	}
+	if p.ServerPool.Len() != 0 {
+		t.Errorf("pool still holds %d backends", p.ServerPool.Len())
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	pool := func(t *testing.T, name string) *backend.ServerPool {
+		b := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+		return &backend.ServerPool{Backends: []*backend.Backend{b}}
+	}
+	p := NewProxy(pool(t, "default"), &algorithms.RoundRobin{}, 3)
+	p.AddRoute(Route{PathPrefix: "/v2/", Pool: pool(t, "v2")})
+	p.AddRoute(Route{PathPrefix: "/v2/admin/", Pool: pool(t, "v2-admin")})
+	p.AddRoute(Route{Host: "api.example.com", Pool: pool(t, "api")})
+
+	for _, tc := range []struct{ target, want string }{
+		{"http://api.example.com/users", "api"},
+		{"http://API.example.com:8080/users", "api"},
+		// A host match outranks any path-only route.
+		{"http://api.example.com/v2/users", "api"},
+		{"http://www.example.com/v2/users", "v2"},
+		{"http://www.example.com/v2/admin/users", "v2-admin"},
+		{"http://www.example.com/v2", "default"},
+		{"http://www.example.com/users", "default"},
+	} {
+		if got := get(t, p, tc.target, nil); got != tc.want {
+			t.Errorf("%s: served by %s, want %s", tc.target, got, tc.want)
+		}
+	}
+}
+
+func TestIPHashFailsOverPastTriedBackend(t *testing.T) {
+	backends := namedBackends(t, 3)
+	p := NewProxy(&backend.ServerPool{Backends: backends}, &algorithms.IPHash{}, 3)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:40000"
+	home, err := (&algorithms.IPHash{}).SelectFor(req, backends)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The client's backend stops answering without being marked down.
+	i := slices.Index(backends, home)
+	backends[i] = deadBackend(t)
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want the request failed over", rec.Code)
+	}
+	if want := fmt.Sprint((i + 1) % len(backends)); rec.Body.String() != want {
+		t.Errorf("failed over to backend %s, want the next one in the list, %s", rec.Body.String(), want)
+	}
+}
+
+// unsized hides a body's length so it is sent without a Content-Length.
+type unsized struct{ io.Reader }
+
+func TestMaxBodyBytes(t *testing.T) {
+	var received string
+	b := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return
+		}
+		received = string(body)
+	}))
+	pool := &backend.ServerPool{Backends: []*backend.Backend{b}}
+	p := NewProxy(pool, &algorithms.RoundRobin{}, 3)
+	p.MaxBodyBytes = 8
+	p.AddRoute(Route{PathPrefix: "/upload/", Pool: pool, MaxBodyBytes: 16})
+
+	for _, tc := range []struct {
+		name     string
+		target   string
+		body     io.Reader
+		wantCode int
+	}{
+		{"under", "/", strings.NewReader("12345678"), http.StatusOK},
+		{"declared over", "/", strings.NewReader("123456789"), http.StatusRequestEntityTooLarge},
+		{"streamed over", "/", unsized{strings.NewReader("123456789")}, http.StatusRequestEntityTooLarge},
+		{"under route limit", "/upload/", strings.NewReader("0123456789abcdef"), http.StatusOK},
+		{"over route limit", "/upload/", strings.NewReader("0123456789abcdefg"), http.StatusRequestEntityTooLarge},
+	} {
+		received = ""
+		req := httptest.NewRequest(http.MethodPost, tc.target, tc.body)
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		if rec.Code != tc.wantCode {
+			t.Errorf("%s: status %d, want %d", tc.name, rec.Code, tc.wantCode)
+		}
+		if tc.wantCode == http.StatusOK && received == "" {
+			t.Errorf("%s: backend got no body", tc.name)
+		}
+		if tc.wantCode != http.StatusOK && received != "" {
+			t.Errorf("%s: backend received %q past the limit", tc.name, received)
+		}
+	}
+}
+
+func TestPostBodySurvivesFailover(t *testing.T) {
+	echo := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+
+	for _, tc := range []struct {
+		name     string
+		methods  []string
+		wantCode int
+		wantBody string
+	}{
+		{"post opted in", append(slices.Clone(DefaultRetryMethods), http.MethodPost), http.StatusOK, "payload"},
+		{"post not retried by default", DefaultRetryMethods, http.StatusBadGateway, ""},
+	} {
+		p := newTestProxy(deadBackend(t), echo)
+		p.RetryMethods = tc.methods
+		p.RetryBodyBytes = 1024
+
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("payload")))
+		if rec.Code != tc.wantCode {
+			t.Errorf("%s: status %d, want %d", tc.name, rec.Code, tc.wantCode)
+		}
+		if tc.wantBody != "" && rec.Body.String() != tc.wantBody {
+			t.Errorf("%s: second backend got %q, want the full body", tc.name, rec.Body.String())
+		}
+	}
+}
+
+func TestOversizedBodyIsNotRetried(t *testing.T) {
+	var reached bool
+	echo := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	p := newTestProxy(deadBackend(t), echo)
+	p.RetryBodyBytes = 4
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/", strings.NewReader("payload")))
+	if rec.Code != http.StatusBadGateway || reached {
+		t.Errorf("status %d, second backend reached %v; want 502 without failing over an unbuffered body", rec.Code, reached)
+	}
+}
+
+func TestReloadDuringSelection(t *testing.T) {
+	stable := namedBackends(t, 2)
+	churn := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	pool := &backend.ServerPool{Backends: stable}
+	p := NewProxy(pool, &algorithms.RoundRobin{}, 3)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			b := backend.NewBackend(churn.URL, 3, time.Second)
+			b.SetAlive(true)
+			pool.ApplyChange([]*backend.Backend{b}, nil)
+			pool.ApplyChange(nil, []string{b.URL.String()})
+		}
+	}()
+
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				rec := httptest.NewRecorder()
+				p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+				if rec.Code != http.StatusOK {
+					t.Errorf("status %d during reload", rec.Code)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := pool.Snapshot(); len(got) != 2 {
+		t.Errorf("pool holds %d backends after the reloads, want the 2 stable ones", len(got))
+	}
+}
+
+func TestBackupTier(t *testing.T) {
+	backends := namedBackends(t, 3)
+	backends[2].Priority = 1
+	p := newTestProxy(backends...)
+
+	served := func() map[string]int {
+		counts := map[string]int{}
+		for range 6 {
+			counts[get(t, p, "/", nil)]++
+		}
+		return counts
+	}
+
+	if got := served(); got["2"] != 0 || got["0"] != 3 || got["1"] != 3 {
+		t.Errorf("with the primaries up, served %v, want the backup idle", got)
+	}
+	backends[0].SetAlive(false)
+	if got := served(); got["1"] != 6 {
+		t.Errorf("with one primary down, served %v, want the other primary only", got)
+	}
+	backends[1].SetAlive(false)
+	if got := served(); got["2"] != 6 {
+		t.Errorf("with every primary down, served %v, want the backup", got)
+	}
+	backends[1].SetAlive(true)
+	if got := served(); got["1"] != 6 {
+		t.Errorf("after a primary recovered, served %v, want the backup idle again", got)
+	}
+}
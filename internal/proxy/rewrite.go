@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"net/url"
+	"strings"
+)
+
+// PathRewrite changes the path a route's requests are sent upstream with:
+// a leading StripPrefix is replaced by Replacement, so /api/users becomes
+// /users with StripPrefix "/api", or /v2/users with Replacement "/v2" too.
+// Paths without the prefix and the query string are left alone.
+type PathRewrite struct {
+	StripPrefix string
+	Replacement string
+}
+
+// apply returns u with the rewrite applied, or u itself when the path
+// doesn't start with StripPrefix.
+func (pr *PathRewrite) apply(u *url.URL) *url.URL {
+	rest, ok := strings.CutPrefix(u.Path, pr.StripPrefix)
+	if !ok {
+		return u
+	}
+
+	out := *u
+	out.Path = pr.Replacement + rest
+	if !strings.HasPrefix(out.Path, "/") {
+		out.Path = "/" + out.Path
+	}
+	// An escaped path would override Path when the URL is sent, so it is
+	// rewritten the same way, or dropped if it no longer matches.
+	out.RawPath = ""
+	if u.RawPath != "" {
+		if rawRest, ok := strings.CutPrefix(u.RawPath, pr.StripPrefix); ok {
+			out.RawPath = pr.Replacement + rawRest
+			if !strings.HasPrefix(out.RawPath, "/") {
+				out.RawPath = "/" + out.RawPath
+			}
+		}
+	}
+	return &out
+}
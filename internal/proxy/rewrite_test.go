@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestPathRewriteApply(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		rewrite PathRewrite
+		target  string
+		want    string
+	}{
+		{"strip", PathRewrite{StripPrefix: "/api"}, "/api/users", "/users"},
+		{"strip to root", PathRewrite{StripPrefix: "/api"}, "/api", "/"},
+		{"replace", PathRewrite{StripPrefix: "/api", Replacement: "/v2"}, "/api/users", "/v2/users"},
+		{"no match", PathRewrite{StripPrefix: "/api"}, "/static/app.js", "/static/app.js"},
+		{"query kept", PathRewrite{StripPrefix: "/api"}, "/api/users?page=2&sort=name", "/users?page=2&sort=name"},
+		{"escaped path", PathRewrite{StripPrefix: "/api"}, "/api/a%2Fb", "/a%2Fb"},
+	} {
+		u, err := url.Parse(tc.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tc.rewrite.apply(u).RequestURI(); got != tc.want {
+			t.Errorf("%s: %s became %s, want %s", tc.name, tc.target, got, tc.want)
+		}
+		if u.String() != tc.target {
+			t.Errorf("%s: the original URL was changed to %s", tc.name, u)
+		}
+	}
+}
+
+func TestPathRewriteUpstream(t *testing.T) {
+	b := newTestBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.RequestURI)
+	}))
+	p := newTestProxy(b)
+	p.AddRoute(Route{PathPrefix: "/api/", Rewrite: &PathRewrite{StripPrefix: "/api"}})
+
+	for target, want := range map[string]string{
+		"/api/users?id=7": "/users?id=7",
+		"/other?id=7":     "/other?id=7",
+	} {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if got := rec.Body.String(); got != want {
+			t.Errorf("%s reached the backend as %s, want %s", target, got, want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package server
+
+import (
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newACMEManager obtains certificates for the configured domains only, so
+// a client sending some other SNI name cannot trigger an issuance.
+func newACMEManager(ac config.ACMEConfig) *autocert.Manager {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(ac.Domains...),
+		Cache:      autocert.DirCache(ac.CacheDir),
+		Email:      ac.Email,
+	}
+	if ac.DirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: ac.DirectoryURL}
+	}
+	return m
+}
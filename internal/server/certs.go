@@ -0,0 +1,108 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/logger"
+)
+
+// certReloadDebounce absorbs the burst of events a certificate rotation
+// produces, such as the key and cert being written one after the other.
+const certReloadDebounce = 500 * time.Millisecond
+
+// certificate serves a listener's key pair and swaps in a new one when the
+// files change, without dropping established connections.
+type certificate struct {
+	certFile string
+	keyFile  string
+	current  atomic.Pointer[tls.Certificate]
+}
+
+func (c *certificate) load() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading tls certificate %s: %w", c.certFile, err)
+	}
+	c.current.Store(&cert)
+	return nil
+}
+
+func (c *certificate) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.current.Load(), nil
+}
+
+// certWatcher reloads certificates when their files change. It watches the
+// directories rather than the files, so replacements by rename, and the
+// symlink swaps of Kubernetes secret volumes, are seen too.
+type certWatcher struct {
+	watcher  *fsnotify.Watcher
+	reload   func()
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	once     sync.Once
+}
+
+func newCertWatcher(files []string, reload func()) (*certWatcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		if dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+		if err := w.Add(dir); err != nil {
+			_ = w.Close()
+			return nil, fmt.Errorf("watching %s: %w", dir, err)
+		}
+	}
+
+	cw := &certWatcher{watcher: w, reload: reload, stopChan: make(chan struct{})}
+	cw.wg.Add(1)
+	go cw.run()
+	return cw, nil
+}
+
+func (cw *certWatcher) run() {
+	defer cw.wg.Done()
+
+	var timerC <-chan time.Time
+	for {
+		select {
+		case _, ok := <-cw.watcher.Events:
+			if !ok {
+				return
+			}
+			if timerC == nil {
+				timerC = time.After(certReloadDebounce)
+			}
+		case err, ok := <-cw.watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Error("certificate watcher error", "error", err)
+		case <-timerC:
+			timerC = nil
+			cw.reload()
+		case <-cw.stopChan:
+			return
+		}
+	}
+}
+
+func (cw *certWatcher) stop() {
+	cw.once.Do(func() {
+		close(cw.stopChan)
+		_ = cw.watcher.Close()
+	})
+	cw.wg.Wait()
+}
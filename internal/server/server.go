@@ -2,37 +2,196 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/logger"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 type Handler interface {
 	ServeHTTP(http.ResponseWriter, *http.Request)
 }
 
+// Server serves one handler on every configured listener.
 type Server struct {
+	listeners   []*listener
+	certWatcher *certWatcher
+	stopped     bool
+	mux         sync.Mutex
+}
+
+type listener struct {
 	httpServer *http.Server
+	tls        config.TLSConfig
+	cert       *certificate
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
 }
 
+// NewServer builds a listener per http entry in cs.Listeners, or a single
+// ":port" listener using cs.TLS when none are configured.
 func NewServer(cs *config.ServerConfig, handler Handler) *Server {
-	return &Server{
-		httpServer: &http.Server{
-			Addr:         fmt.Sprintf(":%d", cs.Port),
-			Handler:      handler,
-			ReadTimeout:  cs.ReadTimeout,
-			WriteTimeout: cs.WriteTimeout,
-		},
+	listeners := cs.Listeners
+	if len(listeners) == 0 {
+		listeners = []config.ListenerConfig{{Address: fmt.Sprintf(":%d", cs.Port), TLS: cs.TLS}}
+	}
+
+	var manager *autocert.Manager
+	if cs.ACME.Enabled {
+		manager = newACMEManager(cs.ACME)
+	}
+
+	s := &Server{}
+	for _, lc := range listeners {
+		if lc.TCP() {
+			// Served by a tcpproxy.Proxy instead.
+			continue
+		}
+		httpServer := &http.Server{
+			Addr:              lc.Address,
+			Handler:           handler,
+			ReadTimeout:       cs.ReadTimeout,
+			ReadHeaderTimeout: cs.ReadHeaderTimeout,
+			WriteTimeout:      cs.WriteTimeout,
+			IdleTimeout:       cs.IdleTimeout,
+		}
+
+		l := &listener{httpServer: httpServer, tls: lc.TLS}
+		if lc.TLS.Enabled {
+			minVersion, ok := tlsVersions[lc.TLS.MinVersion]
+			if !ok {
+				minVersion = tls.VersionTLS12
+			}
+			// Validate has already rejected unknown suites.
+			suites, _ := lc.TLS.CipherSuiteIDs()
+			httpServer.TLSConfig = &tls.Config{
+				MinVersion:   minVersion,
+				CipherSuites: suites,
+			}
+			if manager != nil {
+				httpServer.TLSConfig.GetCertificate = manager.GetCertificate
+				// Lets the CA validate over TLS-ALPN-01 on this listener.
+				httpServer.TLSConfig.NextProtos = []string{acme.ALPNProto}
+			} else {
+				l.cert = &certificate{certFile: lc.TLS.CertFile, keyFile: lc.TLS.KeyFile}
+				httpServer.TLSConfig.GetCertificate = l.cert.get
+			}
+		} else if manager != nil {
+			// Answers HTTP-01 challenges and passes everything else on.
+			httpServer.Handler = manager.HTTPHandler(handler)
+		}
+
+		s.listeners = append(s.listeners, l)
+	}
+	return s
+}
+
+// Start loads the TLS certificates and serves on every listener. It returns
+// as soon as one fails with an error other than http.ErrServerClosed, and
+// otherwise blocks until every listener has been shut down, returning
+// http.ErrServerClosed. Certificates are reloaded whenever their files
+// change; see also ReloadCertificates.
+func (s *Server) Start() error {
+	var files []string
+	for _, l := range s.listeners {
+		if l.cert == nil {
+			continue
+		}
+		if err := l.cert.load(); err != nil {
+			return err
+		}
+		files = append(files, l.cert.certFile, l.cert.keyFile)
 	}
+	if len(files) > 0 {
+		cw, err := newCertWatcher(files, func() {
+			if err := s.ReloadCertificates(); err != nil {
+				logger.Error("tls certificate reload failed, keeping current certificate", "error", err)
+			}
+		})
+		if err != nil {
+			// Reloading on SIGHUP still works without the watcher.
+			logger.Error("failed to watch tls certificates", "error", err)
+		} else {
+			s.mux.Lock()
+			if s.stopped {
+				cw.stop()
+			} else {
+				s.certWatcher = cw
+			}
+			s.mux.Unlock()
+		}
+	}
+
+	errs := make(chan error, len(s.listeners))
+	for _, l := range s.listeners {
+		go func() {
+			errs <- l.serve()
+		}()
+	}
+
+	for range s.listeners {
+		if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	}
+	return http.ErrServerClosed
 }
 
-func (s *Server) Start(port int) error {
-	fmt.Printf("LoadBalancer on port: %d\n", port)
+// ReloadCertificates re-reads every listener's certificate and key. New
+// handshakes use the new pair; a listener whose files fail to load keeps
+// its current one.
+func (s *Server) ReloadCertificates() error {
+	var errs []error
+	for _, l := range s.listeners {
+		if l.cert == nil {
+			continue
+		}
+		if err := l.cert.load(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		logger.Info("tls certificate reloaded", "address", l.httpServer.Addr, "cert_file", l.cert.certFile)
+	}
+	return errors.Join(errs...)
+}
 
-	return s.httpServer.ListenAndServe()
+func (l *listener) serve() error {
+	if l.tls.Enabled {
+		logger.Info("load balancer listening", "address", l.httpServer.Addr, "tls", true)
+		// The certificate comes from TLSConfig.GetCertificate.
+		return l.httpServer.ListenAndServeTLS("", "")
+	}
+
+	logger.Info("load balancer listening", "address", l.httpServer.Addr, "tls", false)
+
+	return l.httpServer.ListenAndServe()
 }
 
+// Stop gracefully shuts down every listener.
 func (s *Server) Stop(ctx context.Context) error {
-	return s.httpServer.Shutdown(ctx)
+	s.mux.Lock()
+	s.stopped = true
+	if s.certWatcher != nil {
+		s.certWatcher.stop()
+	}
+	s.mux.Unlock()
+
+	var errs []error
+	for _, l := range s.listeners {
+		if err := l.httpServer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", l.httpServer.Addr, err))
+		}
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+)
+
+// writeCert writes a self-signed certificate for 127.0.0.1 and its key to
+// dir, returning their paths and a pool that trusts the certificate.
+func writeCert(t *testing.T, dir string) (certFile, keyFile string, roots *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots = x509.NewCertPool()
+	roots.AddCert(cert)
+	return certFile, keyFile, roots
+}
+
+// freeAddr returns a loopback address with a port nothing is listening on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// start runs s until the test ends and waits for every address to accept
+// connections.
+func start(t *testing.T, s *Server, addrs ...string) {
+	t.Helper()
+	errs := make(chan error, 1)
+	go func() { errs <- s.Start() }()
+	t.Cleanup(func() {
+		s.Stop(context.Background())
+		if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("Start returned %v, want http.ErrServerClosed", err)
+		}
+	})
+
+	for _, addr := range addrs {
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			conn, err := net.Dial("tcp", addr)
+			if err == nil {
+				conn.Close()
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s never started listening: %v", addr, err)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+func TestServeTLS(t *testing.T) {
+	certFile, keyFile, roots := writeCert(t, t.TempDir())
+	addr := freeAddr(t)
+	s := NewServer(&config.ServerConfig{Listeners: []config.ListenerConfig{{
+		Address: addr,
+		TLS:     config.TLSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3"},
+	}}}, ok)
+	start(t, s, addr)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	resp, err := client.Get("https://" + addr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if resp.TLS == nil || resp.TLS.Version != tls.VersionTLS13 {
+		t.Errorf("connection state = %+v, want TLS 1.3", resp.TLS)
+	}
+
+	old := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, MaxVersion: tls.VersionTLS12}}}
+	if _, err := old.Get("https://" + addr + "/"); err == nil {
+		t.Error("a TLS 1.2 client was accepted below min_version 1.3")
+	}
+}
+
+func TestMultipleListeners(t *testing.T) {
+	certFile, keyFile, roots := writeCert(t, t.TempDir())
+	plain, secure := freeAddr(t), freeAddr(t)
+	s := NewServer(&config.ServerConfig{Listeners: []config.ListenerConfig{
+		{Address: plain},
+		{Address: secure, TLS: config.TLSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile}},
+	}}, ok)
+	start(t, s, plain, secure)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	for _, target := range []string{"http://" + plain + "/", "https://" + secure + "/"} {
+		resp, err := client.Get(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: status %d, want 200", target, resp.StatusCode)
+		}
+	}
+
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range []string{plain, secure} {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			t.Errorf("%s still accepts connections after Stop", addr)
+		}
+	}
+}
+
+func TestServerTimeouts(t *testing.T) {
+	s := NewServer(&config.ServerConfig{
+		Listeners:         []config.ListenerConfig{{Address: "127.0.0.1:0"}, {Address: "127.0.0.1:1"}},
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      20 * time.Second,
+		ReadHeaderTimeout: 3 * time.Second,
+		IdleTimeout:       90 * time.Second,
+	}, ok)
+
+	if len(s.listeners) != 2 {
+		t.Fatalf("got %d listeners, want 2", len(s.listeners))
+	}
+	for _, l := range s.listeners {
+		hs := l.httpServer
+		if hs.ReadTimeout != 10*time.Second || hs.WriteTimeout != 20*time.Second ||
+			hs.ReadHeaderTimeout != 3*time.Second || hs.IdleTimeout != 90*time.Second {
+			t.Errorf("%s: timeouts read %v, write %v, read header %v, idle %v",
+				hs.Addr, hs.ReadTimeout, hs.WriteTimeout, hs.ReadHeaderTimeout, hs.IdleTimeout)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// Package tcpproxy forwards raw TCP connections to a pool of backends, so
+// the load balancer can front databases and other services that don't
+// speak HTTP.
+package tcpproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/algorithms"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/logger"
+)
+
+// ErrProxyClosed is returned by Serve and ListenAndServe after Shutdown.
+var ErrProxyClosed = errors.New("tcpproxy: proxy closed")
+
+// Proxy accepts connections on Addr and pipes each one to a backend of
+// the pool, chosen by fewest open connections. Backends are reached at
+// their URL's host:port and are counted as busy for as long as the
+// connection stays open.
+type Proxy struct {
+	Addr string
+	// IdleTimeout closes a connection once neither side has sent anything
+	// for this long; 0 keeps idle connections open.
+	IdleTimeout time.Duration
+
+	pool     *backend.ServerPool
+	balancer algorithms.Balancer
+
+	mux      sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+func NewProxy(addr string, pool *backend.ServerPool, idleTimeout time.Duration) *Proxy {
+	return &Proxy{
+		Addr:        addr,
+		IdleTimeout: idleTimeout,
+		pool:        pool,
+		balancer:    &algorithms.LeastConnection{},
+		conns:       make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on Addr and serves until Shutdown.
+func (p *Proxy) ListenAndServe() error {
+	ln, err := net.Listen("tcp", p.Addr)
+	if err != nil {
+		return err
+	}
+	logger.Info("tcp proxy listening", "address", p.Addr)
+	return p.Serve(ln)
+}
+
+// Serve accepts connections on ln until Shutdown, which makes it return
+// ErrProxyClosed.
+func (p *Proxy) Serve(ln net.Listener) error {
+	p.mux.Lock()
+	if p.closed {
+		p.mux.Unlock()
+		ln.Close()
+		return ErrProxyClosed
+	}
+	p.listener = ln
+	p.mux.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			p.mux.Lock()
+			closed := p.closed
+			p.mux.Unlock()
+			if closed {
+				return ErrProxyClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		if !p.track(conn) {
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer p.untrack(conn)
+			p.handle(conn)
+		}()
+	}
+}
+
+func (p *Proxy) track(conn net.Conn) bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.closed {
+		return false
+	}
+	p.conns[conn] = struct{}{}
+	p.wg.Add(1)
+	return true
+}
+
+func (p *Proxy) untrack(conn net.Conn) {
+	p.mux.Lock()
+	delete(p.conns, conn)
+	p.mux.Unlock()
+	p.wg.Done()
+}
+
+// handle dials a backend for client, failing over to the others while
+// dials fail, and pipes the two together until both sides are done.
+func (p *Proxy) handle(client net.Conn) {
+	defer client.Close()
+
+	b, upstream, err := p.dial()
+	if err != nil {
+		logger.Warn("tcp proxy could not reach a backend", "address", p.Addr, "client", client.RemoteAddr().String(), "error", err)
+		return
+	}
+	defer b.DecrementConnections()
+	defer upstream.Close()
+
+	// Connections to a backend removed from the pool end with it.
+	ctx, unbind := b.Bind(context.Background())
+	defer unbind()
+	stop := context.AfterFunc(ctx, func() {
+		if errors.Is(context.Cause(ctx), backend.ErrBackendRemoved) {
+			client.Close()
+			upstream.Close()
+		}
+	})
+	defer stop()
+
+	p.pipe(client, upstream)
+}
+
+// dial picks backends until one accepts a connection. The returned backend
+// holds a connection slot that the caller must give back.
+func (p *Proxy) dial() (*backend.Backend, net.Conn, error) {
+	candidates := backend.ActiveTier(p.pool.Snapshot())
+	for len(candidates) > 0 {
+		b, err := p.balancer.Select(candidates)
+		if err != nil {
+			break
+		}
+		candidates = slices.DeleteFunc(candidates, func(c *backend.Backend) bool { return c == b })
+		if !b.TryAcquire() {
+			continue
+		}
+		conn, err := net.DialTimeout("tcp", b.URL.Host, b.Timeout)
+		if err != nil {
+			b.DecrementConnections()
+			logger.Warn("tcp backend dial failed", "backend", b.URL.String(), "error", err)
+			continue
+		}
+		return b, conn, nil
+	}
+	return nil, nil, fmt.Errorf("no Backend found alive")
+}
+
+// pipe copies both ways between client and upstream. When one side stops
+// sending, the other is told with a half-close and may still answer; an
+// error in either direction, or IdleTimeout passing with no data, closes
+// both.
+func (p *Proxy) pipe(client, upstream net.Conn) {
+	closeBoth := func() {
+		client.Close()
+		upstream.Close()
+	}
+
+	var idle *time.Timer
+	if p.IdleTimeout > 0 {
+		idle = time.AfterFunc(p.IdleTimeout, closeBoth)
+		defer idle.Stop()
+	}
+
+	var wg sync.WaitGroup
+	copyHalf := func(dst, src net.Conn) {
+		defer wg.Done()
+		_, err := io.Copy(dst, &activityReader{r: src, idle: idle, timeout: p.IdleTimeout})
+		if err != nil {
+			closeBoth()
+			return
+		}
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		} else {
+			dst.Close()
+		}
+	}
+	wg.Add(2)
+	go copyHalf(upstream, client)
+	go copyHalf(client, upstream)
+	wg.Wait()
+}
+
+// activityReader pushes the idle timer back on every read that returns
+// data.
+type activityReader struct {
+	r       io.Reader
+	idle    *time.Timer
+	timeout time.Duration
+}
+
+func (a *activityReader) Read(b []byte) (int, error) {
+	n, err := a.r.Read(b)
+	if n > 0 && a.idle != nil {
+		a.idle.Reset(a.timeout)
+	}
+	return n, err
+}
+
+// Shutdown stops accepting connections and waits for the open ones to
+// finish. When ctx is done first the remaining connections are closed
+// and ctx's error is returned.
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	p.mux.Lock()
+	p.closed = true
+	var err error
+	if p.listener != nil {
+		err = p.listener.Close()
+	}
+	p.mux.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		p.mux.Lock()
+		for conn := range p.conns {
+			conn.Close()
+		}
+		p.mux.Unlock()
+		<-done
+		return ctx.Err()
+	}
+}
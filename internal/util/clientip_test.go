@@ -0,0 +1,47 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{"untrusted peer ignores xff", "203.0.113.9:4000", []string{"198.51.100.1"}, "203.0.113.9"},
+		{"trusted peer uses xff", "10.1.2.3:4000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"trusted single host", "192.168.1.1:4000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"trusted peer without xff", "10.1.2.3:4000", nil, "10.1.2.3"},
+		{"spoofed hop left of the real client", "10.1.2.3:4000", []string{"1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"chain of trusted proxies", "10.1.2.3:4000", []string{"198.51.100.1", "10.9.9.9"}, "198.51.100.1"},
+		{"only trusted hops", "10.1.2.3:4000", []string{"10.5.5.5, 10.6.6.6"}, "10.5.5.5"},
+		{"ipv4-mapped peer", "[::ffff:10.1.2.3]:4000", []string{"198.51.100.1"}, "198.51.100.1"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tc.remote
+		for _, v := range tc.xff {
+			req.Header.Add("X-Forwarded-For", v)
+		}
+		if got := ClientIP(req, trusted); got != tc.want {
+			t.Errorf("%s: ClientIP = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseTrustedProxiesInvalid(t *testing.T) {
+	for _, entry := range []string{"10.0.0.0/33", "not-an-ip"} {
+		if _, err := ParseTrustedProxies([]string{entry}); err == nil {
+			t.Errorf("%s: no error", entry)
+		}
+	}
+}
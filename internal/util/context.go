@@ -1,12 +1,23 @@
 package util
 
-import "net/http"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+)
 
 type ctxKey string
 
 const (
-	CtxRetryKey    ctxKey = "retry"
-	CtxAttemptsKey ctxKey = "attempts"
+	CtxRetryKey     ctxKey = "retry"
+	CtxAttemptsKey  ctxKey = "attempts"
+	CtxTriedKey     ctxKey = "tried"
+	CtxUpstreamKey  ctxKey = "upstream"
+	CtxRequestIDKey ctxKey = "request_id"
+	CtxNoReplayKey  ctxKey = "no_replay"
+	CtxStickyKey    ctxKey = "sticky"
 )
 
 func GetRetryFromContext(r *http.Request) int {
@@ -22,3 +33,88 @@ func GetAttemptsFromContext(r *http.Request) int {
 	}
 	return 0
 }
+
+func GetRequestIDFromContext(r *http.Request) string {
+	if id, ok := r.Context().Value(CtxRequestIDKey).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// IsReplayable reports whether the proxy allows r to be sent more than once.
+func IsReplayable(r *http.Request) bool {
+	noReplay, _ := r.Context().Value(CtxNoReplayKey).(bool)
+	return !noReplay
+}
+
+// HasBody reports whether r carries a request body.
+func HasBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody
+}
+
+// GetTriedFromContext returns the URLs of backends already attempted for
+// this request.
+func GetTriedFromContext(r *http.Request) []string {
+	if tried, ok := r.Context().Value(CtxTriedKey).([]string); ok {
+		return tried
+	}
+	return nil
+}
+
+// Upstream lets the proxy report which backend served a request back to
+// middleware further out, such as the access log.
+type Upstream struct {
+	mux sync.Mutex
+	url string
+}
+
+func (u *Upstream) Set(url string) {
+	u.mux.Lock()
+	u.url = url
+	u.mux.Unlock()
+}
+
+func (u *Upstream) Get() string {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+	return u.url
+}
+
+// SetUpstream records url as the backend for r if an outer middleware asked
+// for it.
+func SetUpstream(r *http.Request, url string) {
+	if u, ok := r.Context().Value(CtxUpstreamKey).(*Upstream); ok {
+		u.Set(url)
+	}
+}
+
+// GetStickyFromContext returns the StickyID of the backend the client is
+// pinned to, or "" when it isn't pinned.
+func GetStickyFromContext(r *http.Request) string {
+	id, _ := r.Context().Value(CtxStickyKey).(string)
+	return id
+}
+
+// StickyID is a short, stable id for the backend at url. Sticky session
+// cookies use it so backend addresses aren't handed out to clients, and
+// the admin API accepts it in place of the URL.
+func StickyID(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:8])
+}
+
+// IsUpgradeRequest reports whether r asks to switch protocols, as a
+// WebSocket handshake does.
+func IsUpgradeRequest(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package util
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrorPage is the body the load balancer sends with the errors it
+// generates itself. A zero ErrorPage falls back to the plain-text
+// http.Error message.
+type ErrorPage struct {
+	// Body may contain {{status}} and {{message}}, which are replaced with
+	// the status code and its text.
+	Body        string
+	ContentType string
+}
+
+// WriteError writes status with page's body, or with message when page is
+// nil or has no body.
+func WriteError(w http.ResponseWriter, page *ErrorPage, message string, status int) {
+	if page == nil || page.Body == "" {
+		http.Error(w, message, status)
+		return
+	}
+
+	contentType := page.ContentType
+	if contentType == "" {
+		contentType = "text/html; charset=utf-8"
+	}
+
+	body := strings.NewReplacer(
+		"{{status}}", strconv.Itoa(status),
+		"{{message}}", message,
+	).Replace(page.Body)
+
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", contentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(body))
+}
@@ -0,0 +1,529 @@
+// Package loadbalancer assembles the proxy, its middlewares, health checks
+// and listeners from a config, so the load balancer can be embedded in
+// another program as well as run by cmd/lb.
+package loadbalancer
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/admin"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/algorithms"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+	configs "github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/logger"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/metrics"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/middleware/accesslog"
+	apikey "github.com/soham0w0sarkar/LoadBalancerGo.git/internal/middleware/apiKey"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/middleware/cache"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/middleware/compression"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/middleware/headers"
+	loadshedder "github.com/soham0w0sarkar/LoadBalancerGo.git/internal/middleware/loadShedder"
+	ratelimiter "github.com/soham0w0sarkar/LoadBalancerGo.git/internal/middleware/rateLimiter"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/middleware/readiness"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/middleware/requestid"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/middleware/sticky"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/proxy"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/server"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/tcpproxy"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/util"
+)
+
+// Config is the load balancer's configuration, as read by LoadConfig.
+type Config = configs.Config
+
+// Change is a reload detected by the config watcher; see Apply.
+type Change = configs.BackendChange
+
+// LoadConfig reads a YAML or JSON config file and applies environment
+// overrides and defaults. The result still has to pass Validate.
+func LoadConfig(path string) (*Config, error) {
+	return configs.Load(path)
+}
+
+type LoadBalancer struct {
+	config         *Config
+	pool           *backend.ServerPool
+	groups         map[string]*backend.ServerPool
+	proxy          *proxy.Proxy
+	handler        http.Handler
+	rateLimiter    *ratelimiter.RateLimiter
+	cache          *cache.Cache
+	healthCheckers []*backend.HealthCheck
+	groupCheckers  map[string]*backend.HealthCheck
+	discoveries    []*backend.Discovery
+	healthOnce     sync.Once
+
+	srv        *server.Server
+	tcpProxies []*tcpproxy.Proxy
+	metricsSrv *http.Server
+	adminSrv   *http.Server
+}
+
+// NewLoadBalancer builds everything c describes without starting anything.
+// c must already be validated.
+func NewLoadBalancer(c *Config) (*LoadBalancer, error) {
+	pool, err := backend.NewServerPool(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build backend pool: %w", err)
+	}
+
+	// Validate has already parsed these.
+	trusted, _ := util.ParseTrustedProxies(c.Proxy.TrustedProxies)
+
+	balancer, err := newBalancer(c.LoadBalancing.Strategy, c.LoadBalancing.HashKey, trusted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build balancer: %w (available: %v)", err, algorithms.Strategies())
+	}
+
+	p := proxy.NewProxy(pool, balancer, c.Proxy.MaxAttempts)
+	if page := c.Proxy.ErrorPage; page.Body != "" {
+		p.ErrorPage = &util.ErrorPage{Body: page.Body, ContentType: page.ContentType}
+	}
+	p.MaxBodyBytes = c.Proxy.MaxBodyBytes
+	p.RetryBodyBytes = c.Proxy.RetryBodyBytes
+	p.RetryMethods = c.Proxy.RetryMethods
+	if fb := c.LoadBalancing.Fallback; fb.Enabled {
+		p.Fallback, err = newFallback(fb, c)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build fallback: %w", err)
+		}
+	}
+	if m := c.LoadBalancing.Maintenance; m.Configured() {
+		p.Maintenance, err = newFallback(m, c)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build maintenance page: %w", err)
+		}
+	}
+	p.SetMaintenance(c.LoadBalancing.Maintenance.Enabled)
+	if m := c.Proxy.Mirror; m.Enabled {
+		// Validate has already checked the URL.
+		target, _ := url.Parse(m.URL)
+		p.Mirror = proxy.NewMirror(target, m.Percent, m.Timeout)
+	}
+	p.SetManagement(c.Server.ManagementPrefix, p.ManagementHandler(c.Server.ManagementPrefix))
+	if gl := c.LoadBalancing.RateLimit; gl.Enabled {
+		p.Limit = ratelimiter.NewLimit(gl.Size, gl.Rate)
+	}
+
+	groups := make(map[string]*backend.ServerPool, len(c.BackendGroups))
+	for _, group := range c.BackendGroups {
+		gp, err := backend.NewServerPoolFor(group.Backends, c)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build backend group %q: %w", group.Name, err)
+		}
+		groups[group.Name] = gp
+	}
+
+	for _, route := range c.LoadBalancing.Routes {
+		pr := proxy.Route{Host: route.Host, PathPrefix: route.PathPrefix, Pool: groups[route.Group], MaxBodyBytes: route.MaxBodyBytes}
+		// Routes without their own strategy share the proxy's default
+		// balancer, which follows reloads.
+		if route.Strategy != "" {
+			pr.Balancer, err = newBalancer(route.Strategy, cmp.Or(route.HashKey, c.LoadBalancing.HashKey), trusted)
+			if err != nil {
+				return nil, fmt.Errorf("failed to build balancer for route %s%s: %w (available: %v)", route.Host, route.PathPrefix, err, algorithms.Strategies())
+			}
+		}
+		if rw := route.PathRewrite; rw != nil {
+			pr.Rewrite = &proxy.PathRewrite{StripPrefix: rw.StripPrefix, Replacement: rw.Replacement}
+		}
+		if route.RateLimit.Enabled {
+			pr.Limit = ratelimiter.NewLimit(route.RateLimit.Size, route.RateLimit.Rate)
+		}
+		p.AddRoute(pr)
+	}
+
+	var handler http.Handler = p
+
+	// The cache sits inside the sticky middleware, so the pin cookie is
+	// set per client and never stored with a shared response.
+	var responseCache *cache.Cache
+	if cc := c.Middlewares.Cache; cc.Enabled {
+		responseCache = cache.NewCache(cc.TTL, cc.MaxBodySize, cc.MaxEntries, handler)
+		handler = responseCache
+	}
+
+	if ss := c.LoadBalancing.StickySession; ss.Enabled {
+		handler = sticky.NewStickySession(ss.CookieName, ss.TTL, handler)
+	}
+
+	if cc := c.Middlewares.Compression; cc.Enabled {
+		handler = compression.NewCompression(cc.GzipLevel(), cc.MinSize, cc.ContentTypes, handler)
+	}
+
+	var rl *ratelimiter.RateLimiter
+	if c.Middlewares.RateLimiter.Enabled {
+		capacity := c.Middlewares.RateLimiter.Size
+		refillRate := c.Middlewares.RateLimiter.Rate
+		rl = ratelimiter.NewRateLimiter(capacity, refillRate, trusted, ratelimiter.RealClock, handler)
+		rl.SetOverrides(rateLimitTiers(c.Middlewares.RateLimiter.Overrides))
+		handler = rl
+	}
+
+	if c.Middlewares.APIKey.Enabled {
+		handler = apikey.NewAPIKey(apikey.NewAllowList(c.Middlewares.APIKey.Keys), handler)
+	}
+
+	if c.Server.Readiness.Enabled {
+		handler = readiness.NewReadiness(c.Server.Readiness.Path, pool, handler)
+	}
+
+	if c.Middlewares.AccessLog.Enabled {
+		handler = accesslog.NewAccessLog(trusted, handler)
+	}
+
+	if ls := &c.Middlewares.LoadShedder; ls.Enabled {
+		handler = loadshedder.NewLoadShedder(ls.MaxInflight, ls.RetryAfter, handler)
+	}
+
+	if c.Middlewares.RequestID.Enabled {
+		handler = requestid.NewRequestID(handler)
+	}
+
+	if rh := c.Middlewares.ResponseHeaders; rh.Enabled {
+		handler = headers.NewResponseHeaders(rh.Set, rh.Add, rh.Remove, handler)
+	}
+
+	lb := &LoadBalancer{
+		config:      c,
+		pool:        pool,
+		groups:      groups,
+		proxy:       p,
+		handler:     handler,
+		rateLimiter: rl,
+		cache:       responseCache,
+		srv:         server.NewServer(&c.Server, handler),
+	}
+
+	for _, l := range c.Server.Listeners {
+		if l.TCP() {
+			lb.tcpProxies = append(lb.tcpProxies, tcpproxy.NewProxy(l.Address, groups[l.Group], l.IdleTimeout))
+		}
+	}
+
+	lb.healthCheckers = []*backend.HealthCheck{backend.NewHealthCheck(pool, c.LoadBalancing.HealthCheck)}
+	lb.groupCheckers = make(map[string]*backend.HealthCheck, len(groups))
+	for name, group := range groups {
+		hc := backend.NewHealthCheck(group, c.GroupHealthCheck(name))
+		lb.healthCheckers = append(lb.healthCheckers, hc)
+		lb.groupCheckers[name] = hc
+	}
+
+	lb.addDiscovery(pool, c.Backends)
+	for _, group := range c.BackendGroups {
+		lb.addDiscovery(groups[group.Name], group.Backends)
+	}
+
+	if c.Metrics.Enabled {
+		lb.metricsSrv = lb.newMetricsServer()
+	}
+	if c.Admin.Enabled {
+		adminHandler := admin.NewAdmin(pool)
+		if c.Admin.Debug {
+			adminHandler.EnableDebug()
+		}
+		adminHandler.EnableMaintenance(p)
+		adminHandler.EnablePoolManagement(c)
+		lb.adminSrv = &http.Server{Addr: fmt.Sprintf(":%d", c.Admin.Port), Handler: adminHandler}
+	}
+	return lb, nil
+}
+
+func (lb *LoadBalancer) addDiscovery(pool *backend.ServerPool, bcs []configs.BackendConfig) {
+	if d := backend.NewDiscovery(pool, bcs, lb.config, nil); !d.Empty() {
+		lb.discoveries = append(lb.discoveries, d)
+	}
+}
+
+// Handler is the full middleware chain in front of the proxy, for
+// embedders that serve it themselves.
+func (lb *LoadBalancer) Handler() http.Handler {
+	return lb.handler
+}
+
+// ServerPool is the pool of top-level backends.
+func (lb *LoadBalancer) ServerPool() *backend.ServerPool {
+	return lb.pool
+}
+
+// Proxy is the proxy at the end of the handler chain.
+func (lb *LoadBalancer) Proxy() *proxy.Proxy {
+	return lb.proxy
+}
+
+// ReloadCertificates re-reads the TLS certificates of the public
+// listeners. Listeners also pick up changed certificate files on their own.
+func (lb *LoadBalancer) ReloadCertificates() error {
+	return lb.srv.ReloadCertificates()
+}
+
+// StartHealthChecks starts probing every pool, along with DNS discovery of
+// backends. Start calls it; embedders serving Handler on their own
+// listener call it instead.
+func (lb *LoadBalancer) StartHealthChecks() {
+	lb.healthOnce.Do(func() {
+		for _, d := range lb.discoveries {
+			d.Start()
+		}
+		for _, hc := range lb.healthCheckers {
+			hc.Start()
+		}
+	})
+}
+
+// Start starts the health checks and the metrics and admin listeners, then
+// serves the configured http and tcp listeners. It blocks until Stop is called, which
+// makes it return http.ErrServerClosed, or a listener fails.
+func (lb *LoadBalancer) Start() error {
+	lb.StartHealthChecks()
+
+	if lb.metricsSrv != nil {
+		go func() {
+			logger.Info("metrics server listening", "address", lb.metricsSrv.Addr)
+			if err := lb.metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("metrics server error", "error", err)
+			}
+		}()
+	}
+	if lb.adminSrv != nil {
+		go func() {
+			logger.Info("admin server listening", "address", lb.adminSrv.Addr)
+			if err := lb.adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("admin server error", "error", err)
+			}
+		}()
+	}
+
+	errs := make(chan error, 1+len(lb.tcpProxies))
+	go func() {
+		errs <- lb.srv.Start()
+	}()
+	for _, tp := range lb.tcpProxies {
+		go func() {
+			if err := tp.ListenAndServe(); !errors.Is(err, tcpproxy.ErrProxyClosed) {
+				errs <- fmt.Errorf("tcp listener %s: %w", tp.Addr, err)
+				return
+			}
+			errs <- http.ErrServerClosed
+		}()
+	}
+
+	for range 1 + len(lb.tcpProxies) {
+		if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	}
+	return http.ErrServerClosed
+}
+
+// Stop shuts down in order: the listeners stop accepting requests and
+// drain within ctx, then discovery and health checks stop, and finally the
+// rate limiter's and cache's sweepers are closed. It returns once all of
+// them have exited.
+func (lb *LoadBalancer) Stop(ctx context.Context) error {
+	var errs []error
+	if err := lb.srv.Stop(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("server: %w", err))
+	}
+	for _, tp := range lb.tcpProxies {
+		if err := tp.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("tcp listener %s: %w", tp.Addr, err))
+		}
+	}
+	if lb.metricsSrv != nil {
+		if err := lb.metricsSrv.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("metrics server: %w", err))
+		}
+	}
+	if lb.adminSrv != nil {
+		if err := lb.adminSrv.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("admin server: %w", err))
+		}
+	}
+
+	for _, d := range lb.discoveries {
+		d.Stop()
+	}
+	for _, hc := range lb.healthCheckers {
+		hc.Stop()
+	}
+
+	if lb.rateLimiter != nil {
+		if err := lb.rateLimiter.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("rate limiter: %w", err))
+		}
+	}
+	if lb.cache != nil {
+		if err := lb.cache.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("cache: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (lb *LoadBalancer) newMetricsServer() *http.Server {
+	path := lb.config.Metrics.Path
+	if path == "" {
+		path = "/metrics"
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, metrics.Handler(func() []metrics.BackendState {
+		var states []metrics.BackendState
+		backends := lb.pool.Snapshot()
+		for _, gp := range lb.groups {
+			backends = append(backends, gp.Snapshot()...)
+		}
+		seen := make(map[string]bool, len(backends))
+		for _, b := range backends {
+			// A backend listed in several pools is reported once.
+			if seen[b.URL.String()] {
+				continue
+			}
+			seen[b.URL.String()] = true
+			probes := b.ProbeMetrics()
+			states = append(states, metrics.BackendState{
+				URL:                 b.URL.String(),
+				ActiveConnections:   b.ActiveConnections(),
+				Alive:               b.IsAlive(),
+				HealthCheckDuration: probes.LastDuration,
+				ConsecutiveFailures: probes.ConsecutiveFailures,
+			})
+		}
+		return states
+	}))
+	return &http.Server{Addr: fmt.Sprintf(":%d", lb.config.Metrics.Port), Handler: mux}
+}
+
+// newBalancer builds the named strategy, handing it the trusted proxies if
+// it identifies clients by IP and hashKey if it hashes requests.
+func newBalancer(strategy configs.Strategy, hashKey string, trusted []netip.Prefix) (algorithms.Balancer, error) {
+	b, err := algorithms.SetAlgorithm(string(strategy))
+	if err != nil {
+		return nil, err
+	}
+	switch b := b.(type) {
+	case *algorithms.IPHash:
+		b.TrustedProxies = trusted
+	case *algorithms.ConsistentHash:
+		b.Key = cmp.Or(hashKey, algorithms.DefaultHashKey)
+		b.TrustedProxies = trusted
+	}
+	return b, nil
+}
+
+func rateLimitTiers(overrides map[string]configs.RateLimitTier) map[string]ratelimiter.Tier {
+	tiers := make(map[string]ratelimiter.Tier, len(overrides))
+	for key, t := range overrides {
+		tiers[key] = ratelimiter.Tier{Capacity: t.Size, RefillRate: t.Rate}
+	}
+	return tiers
+}
+
+// newFallback builds the proxy's fallback from fb: a backend outside normal
+// rotation when BackendURL is set, otherwise a static page.
+func newFallback(fb configs.MaintenanceConfig, c *configs.Config) (*proxy.Fallback, error) {
+	if fb.BackendURL != "" {
+		b, err := backend.NewBackendFromConfig(configs.BackendConfig{Url: fb.BackendURL, Timeout: fb.Timeout}, c)
+		if err != nil {
+			return nil, err
+		}
+		return &proxy.Fallback{Backend: b}, nil
+	}
+
+	body := []byte(fb.Body)
+	if fb.BodyFile != "" {
+		var err error
+		if body, err = os.ReadFile(fb.BodyFile); err != nil {
+			return nil, err
+		}
+	}
+	return &proxy.Fallback{Status: fb.Status, Body: body, ContentType: fb.ContentType}, nil
+}
+
+// Apply applies a config reload reported by a configs.Watcher. It builds
+// the new balancer and every added backend before touching the proxy or
+// pool, so a reload either applies in full or leaves the running state
+// untouched.
+func (lb *LoadBalancer) Apply(ev Change) error {
+	var balancer algorithms.Balancer
+	if ev.StrategyChanged {
+		var err error
+		trusted, _ := util.ParseTrustedProxies(ev.Config.Proxy.TrustedProxies)
+		balancer, err = newBalancer(ev.Config.LoadBalancing.Strategy, ev.Config.LoadBalancing.HashKey, trusted)
+		if err != nil {
+			return err
+		}
+	}
+
+	added, err := backend.BuildBackends(ev.Added, ev.Config)
+	if err != nil {
+		return err
+	}
+	groupAdded := make(map[string][]*backend.Backend, len(ev.Groups))
+	for name, gc := range ev.Groups {
+		if lb.groups[name] == nil {
+			continue
+		}
+		idx := slices.IndexFunc(ev.Config.BackendGroups, func(g configs.BackendGroupConfig) bool { return g.Name == name })
+		groupAdded[name], err = backend.BuildBackendsFrom(gc.Added, ev.Config.BackendGroups[idx].Backends, ev.Config)
+		if err != nil {
+			return fmt.Errorf("backend group %q: %w", name, err)
+		}
+	}
+
+	for _, reason := range ev.RestartRequired {
+		logger.Warn("config change requires a restart", "change", reason)
+	}
+
+	if len(added) > 0 {
+		// Probed before ApplyChange so they join the pool with a known
+		// state. The first checker is the default pool's.
+		lb.healthCheckers[0].CheckNow(added)
+	}
+	lb.pool.ApplyChange(added, ev.Removed)
+	if len(ev.Reweighted) > 0 {
+		lb.pool.SetWeights(ev.Reweighted)
+		logger.Info("backend weights reloaded", "weights", ev.Reweighted)
+	}
+	for name, gc := range ev.Groups {
+		gp := lb.groups[name]
+		if gp == nil {
+			logger.Warn("config change requires a restart", "change", fmt.Sprintf("backend group %q added", name))
+			continue
+		}
+		if added := groupAdded[name]; len(added) > 0 {
+			lb.groupCheckers[name].CheckNow(added)
+		}
+		gp.ApplyChange(groupAdded[name], gc.Removed)
+		if len(gc.Reweighted) > 0 {
+			gp.SetWeights(gc.Reweighted)
+		}
+		logger.Info("backend group reloaded", "group", name, "added", gc.Added, "removed", gc.Removed, "weights", gc.Reweighted)
+	}
+	if balancer != nil {
+		lb.proxy.SetBalancer(balancer)
+		logger.Info("balancing strategy reloaded", "strategy", ev.Config.LoadBalancing.Strategy)
+	}
+	if ev.RateLimiterChanged {
+		// The middleware chain is built once, so the limiter can be retuned
+		// but not switched on or off.
+		rlc := ev.Config.Middlewares.RateLimiter
+		if lb.rateLimiter != nil && rlc.Enabled {
+			lb.rateLimiter.SetLimits(rlc.Size, rlc.Rate)
+			lb.rateLimiter.SetOverrides(rateLimitTiers(rlc.Overrides))
+			logger.Info("rate limiter reloaded", "size", rlc.Size, "rate", rlc.Rate)
+		} else if (lb.rateLimiter != nil) != rlc.Enabled {
+			logger.Warn("enabling or disabling the rate limiter requires a restart")
+		}
+	}
+	return nil
+}
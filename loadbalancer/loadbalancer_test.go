@@ -0,0 +1,203 @@
+package loadbalancer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/algorithms"
+	"github.com/soham0w0sarkar/LoadBalancerGo.git/internal/backend"
+	configs "github.com/soham0w0sarkar/LoadBalancerGo.git/internal/config"
+)
+
+const testConfig = `
+server:
+  port: 8080
+  read_timeout: 10s
+  write_timeout: 10s
+backends:
+  - url: http://10.0.0.1:80
+    timeout: 5s
+backend_groups:
+  - name: api
+    backends:
+      - url: http://10.0.1.1:80
+        timeout: 5s
+load_balancing:
+  strategy: round_robin
+  health_check:
+    interval: 15s
+    timeout: 5s
+    unhealthy_threshold: 3
+    healthy_threshold: 2
+`
+
+func parseConfig(t *testing.T, body string) *Config {
+	t.Helper()
+	c, err := configs.Parse([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func newTestLoadBalancer(t *testing.T, body string) *LoadBalancer {
+	t.Helper()
+	lb, err := NewLoadBalancer(parseConfig(t, body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lb.Stop(context.Background()) })
+	return lb
+}
+
+func urls(backends []*backend.Backend) []string {
+	var out []string
+	for _, b := range backends {
+		out = append(out, b.URL.String())
+	}
+	return out
+}
+
+func TestApplyFailureKeepsState(t *testing.T) {
+	lb := newTestLoadBalancer(t, testConfig)
+	balancer := lb.Proxy().Balancer()
+
+	next := parseConfig(t, testConfig)
+	next.LoadBalancing.Strategy = configs.LeastConnection
+	next.Backends = []configs.BackendConfig{{Url: "http://10.0.0.2:80", Timeout: next.Backends[0].Timeout}}
+	// The group's new backend needs a CA file that doesn't exist, so it
+	// can't be built and the reload has to be refused as a whole.
+	group := &next.BackendGroups[0]
+	group.Backends = append(group.Backends, configs.BackendConfig{
+		Url:     "https://10.0.1.2:443",
+		Timeout: group.Backends[0].Timeout,
+		TLS:     &configs.UpstreamTLSConfig{CAFile: t.TempDir() + "/missing.pem"},
+	})
+
+	err := lb.Apply(Change{
+		Added:           []string{"http://10.0.0.2:80"},
+		Removed:         []string{"http://10.0.0.1:80"},
+		StrategyChanged: true,
+		Groups:          map[string]configs.GroupChange{"api": {Added: []string{"https://10.0.1.2:443"}}},
+		Config:          next,
+	})
+	if err == nil {
+		t.Fatal("Apply succeeded with an unbuildable backend")
+	}
+
+	if got := urls(lb.ServerPool().Snapshot()); !slices.Equal(got, []string{"http://10.0.0.1:80"}) {
+		t.Errorf("default pool = %v, want it untouched", got)
+	}
+	if got := urls(lb.groups["api"].Snapshot()); !slices.Equal(got, []string{"http://10.0.1.1:80"}) {
+		t.Errorf("api group = %v, want it untouched", got)
+	}
+	if lb.ServerPool().Snapshot()[0].IsDraining() {
+		t.Error("the backend the reload would have removed is draining")
+	}
+	if lb.Proxy().Balancer() != balancer {
+		t.Error("the balancer was swapped by a failed reload")
+	}
+	if _, ok := lb.Proxy().Balancer().(*algorithms.RoundRobin); !ok {
+		t.Errorf("balancer = %T, want the original round robin", lb.Proxy().Balancer())
+	}
+}
+
+func TestDebugEndpoints(t *testing.T) {
+	for _, debug := range []bool{false, true} {
+		body := testConfig + fmt.Sprintf("admin:\n  enabled: true\n  port: 9090\n  debug: %v\n", debug)
+		lb := newTestLoadBalancer(t, body)
+
+		want := http.StatusNotFound
+		if debug {
+			want = http.StatusOK
+		}
+		for _, path := range []string{"/debug/pprof/", "/debug/vars"} {
+			rec := httptest.NewRecorder()
+			lb.adminSrv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			if rec.Code != want {
+				t.Errorf("debug %v: admin %s: status %d, want %d", debug, path, rec.Code, want)
+			}
+
+			// The proxy port passes the path to the backends like any other.
+			rec = httptest.NewRecorder()
+			lb.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			if rec.Code == http.StatusOK {
+				t.Errorf("debug %v: proxy served %s itself", debug, path)
+			}
+		}
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "embedded")
+	}))
+	defer upstream.Close()
+
+	lb, err := NewLoadBalancer(parseConfig(t, fmt.Sprintf(`
+server:
+  port: 8080
+  read_timeout: 10s
+  write_timeout: 10s
+backends:
+  - url: %s
+    timeout: 5s
+load_balancing:
+  strategy: round_robin
+  health_check:
+    interval: 15s
+    timeout: 5s
+    unhealthy_threshold: 3
+    healthy_threshold: 1
+  sticky_session:
+    enabled: true
+    cookie_name: lb_backend
+    ttl: 1h
+middlewares:
+  rate_limiter:
+    enabled: true
+    rate: 100
+    size: 100
+`, upstream.URL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The embedder serves Handler itself, so only the health checks run.
+	lb.StartHealthChecks()
+
+	var rec *httptest.ResponseRecorder
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		rec = httptest.NewRecorder()
+		lb.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code == http.StatusOK {
+			break
+		}
+	}
+	if rec.Code != http.StatusOK || rec.Body.String() != "embedded" {
+		t.Fatalf("status %d, body %q, want the upstream's 200", rec.Code, rec.Body)
+	}
+	if !slices.ContainsFunc(rec.Result().Cookies(), func(c *http.Cookie) bool { return c.Name == "lb_backend" }) {
+		t.Error("the sticky session middleware set no cookie")
+	}
+	if got := urls(lb.ServerPool().Snapshot()); !slices.Equal(got, []string{upstream.URL}) {
+		t.Errorf("pool = %v, want the configured backend", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := lb.Stop(ctx); err != nil {
+		t.Errorf("Stop: %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Error("Stop ran out its deadline")
+	}
+}